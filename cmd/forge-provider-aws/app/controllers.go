@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	"github.com/forge-build/forge-provider-aws/cmd/forge-provider-aws/app/options"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
 	awsbuildcontroller "github.com/forge-build/forge-provider-aws/pkg/controllers/awsbuild"
 )
 
@@ -43,5 +44,13 @@ func createAllControllers(ctrlCtx *options.ControllerContext) error {
 }
 
 func createAWSBuildController(ctrlCtx *options.ControllerContext) error {
-	return awsbuildcontroller.Add(ctrlCtx.Ctx, ctrlCtx.Mgr, 1, &ctrlCtx.Log)
+	options := awsbuildcontroller.Options{
+		RateLimits: awsforge.RateLimitOptions{
+			QPS:        ctrlCtx.RunOptions.AWSAPIQPS,
+			Burst:      ctrlCtx.RunOptions.AWSAPIBurst,
+			MaxRetries: ctrlCtx.RunOptions.AWSAPIMaxRetries,
+		},
+		EC2Endpoint: ctrlCtx.RunOptions.AWSEC2Endpoint,
+	}
+	return awsbuildcontroller.Add(ctrlCtx.Ctx, ctrlCtx.Mgr, 1, options, &ctrlCtx.Log)
 }
@@ -20,8 +20,10 @@ import (
 	"context"
 	"flag"
 
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
 	"github.com/forge-build/forge/pkg/log"
 	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
 
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
@@ -33,6 +35,10 @@ type ControllerManagerRunOptions struct {
 	LogLevel             log.LogLevel
 	LogFormat            log.Format
 	WorkerName           string
+	AWSAPIQPS            float64
+	AWSAPIBurst          int
+	AWSAPIMaxRetries     int
+	AWSEC2Endpoint       string
 }
 
 type ControllerContext struct {
@@ -49,4 +55,16 @@ func (o *ControllerManagerRunOptions) AddFlags(fs *flag.FlagSet) {
 	fs.StringVar(&o.MetricsBindAddress, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	fs.StringVar(&o.WorkerName, "worker-name", "", "The name of the worker that will only processes resources with label=worker-name.")
 	fs.Var(&o.LogFormat, "log-format", "Log format, one of [Console, Json]")
+	fs.Float64Var(&o.AWSAPIQPS, "aws-api-qps", awsforge.DefaultAPIQPS, "The sustained rate of AWS API calls per service, account and region, 0 disables rate limiting.")
+	fs.IntVar(&o.AWSAPIBurst, "aws-api-burst", awsforge.DefaultAPIBurst, "The number of AWS API calls allowed above aws-api-qps.")
+	fs.IntVar(&o.AWSAPIMaxRetries, "aws-api-max-retries", awsforge.DefaultAPIMaxRetries, "The number of retries of throttled and failed AWS API calls.")
+	fs.StringVar(&o.AWSEC2Endpoint, "aws-ec2-endpoint", "", "The URL EC2 API calls are sent to instead of the endpoint of the region, e.g., a local stand-in of the EC2 API.")
+}
+
+// Validate checks the options set with the flags.
+func (o *ControllerManagerRunOptions) Validate() error {
+	if o.AWSAPIQPS > 0 && o.AWSAPIBurst < 1 {
+		return errors.Errorf("aws-api-burst must be at least 1 when aws-api-qps is set, got %d", o.AWSAPIBurst)
+	}
+	return nil
 }
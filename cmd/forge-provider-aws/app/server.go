@@ -53,6 +53,9 @@ func NewControllerManagerCommand() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Parse the flags from the FlagSet
 			fs.Parse(args)
+			if err := opts.Validate(); err != nil {
+				return err
+			}
 			return runControllerManager(opts)
 		},
 	}
@@ -0,0 +1,303 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	"github.com/forge-build/forge-provider-aws/cmd/forge-provider-aws/app/options"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	"github.com/forge-build/forge-provider-aws/pkg/aws/fake"
+	buildv1 "github.com/forge-build/forge/pkg/api/v1alpha1"
+)
+
+const (
+	testNamespace = "default"
+	// testTimeout bounds each phase of the build, the controller requeues every 5 to 10 seconds while it waits on AWS.
+	testTimeout = 2 * time.Minute
+)
+
+// TestAWSBuild drives a Build and its AWSBuild through the creation of the instance, the capture of the AMI
+// and the cleanup of the build resources, with the controller talking to the EC2 stand-in over the Query API.
+// It runs against the API server and etcd binaries of KUBEBUILDER_ASSETS, e.g., with make test.
+func TestAWSBuild(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set, run the test with make test")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	ec2Client := fake.New()
+	baseAMI := ec2Client.AddImage(&ec2.Image{Name: aws.String("base")})
+	server := httptest.NewServer(fake.NewEC2Server(ec2Client))
+	t.Cleanup(server.Close)
+
+	k8sClient := startControllerManager(ctx, t, server.URL)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws-credentials", Namespace: testNamespace},
+		StringData: map[string]string{
+			"aws_access_key_id":     "AKIDEXAMPLE",
+			"aws_secret_access_key": "secret",
+		},
+	}
+	if err := k8sClient.Create(ctx, secret); err != nil {
+		t.Fatalf("Failed to create the credentials secret: %v", err)
+	}
+
+	// The Build is created unstructured, its typed spec belongs to the forge API and isn't used by the provider.
+	build := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": buildv1.GroupVersion.String(),
+		"kind":       "Build",
+		"metadata":   map[string]any{"name": "build", "namespace": testNamespace},
+		"spec": map[string]any{
+			"connector": map[string]any{"type": "ssh"},
+			"infrastructureRef": map[string]any{
+				"apiVersion": infrav1.GroupVersion.String(),
+				"kind":       "AWSBuild",
+				"name":       "build",
+				"namespace":  testNamespace,
+			},
+		},
+	}}
+	if err := k8sClient.Create(ctx, build); err != nil {
+		t.Fatalf("Failed to create the build: %v", err)
+	}
+
+	awsBuild := &infrav1.AWSBuild{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "build",
+			Namespace: testNamespace,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: buildv1.GroupVersion.String(),
+				Kind:       "Build",
+				Name:       build.GetName(),
+				UID:        build.GetUID(),
+			}},
+		},
+		Spec: infrav1.AWSBuildSpec{
+			Region:         fake.DefaultRegion,
+			InstanceType:   "t3.micro",
+			AMI:            aws.String(baseAMI),
+			PublicIP:       aws.Bool(true),
+			CredentialsRef: &corev1.SecretReference{Name: secret.Name},
+			Network: infrav1.NetworkSpec{
+				// The egress IP of the controller is not detected, the test doesn't reach out to the internet.
+				SSHIngress: &infrav1.SecurityGroupRulePeers{CIDRBlocks: []string{"192.0.2.0/24"}},
+			},
+		},
+	}
+	awsBuild.Spec.Username = "forge"
+	awsBuild.Spec.GenerateSSHKey = true
+	if err := k8sClient.Create(ctx, awsBuild); err != nil {
+		t.Fatalf("Failed to create the AWS build: %v", err)
+	}
+	key := client.ObjectKeyFromObject(awsBuild)
+
+	// Creation: the instance is launched from the base AMI in a VPC created for the build.
+	var instanceID, vpcID string
+	eventually(t, "the instance to be launched", func() (bool, error) {
+		if err := k8sClient.Get(ctx, key, awsBuild); err != nil {
+			return false, err
+		}
+		if awsBuild.Spec.InstanceID == nil || awsBuild.Spec.Network.VPCID == nil {
+			return false, nil
+		}
+		instanceID, vpcID = *awsBuild.Spec.InstanceID, *awsBuild.Spec.Network.VPCID
+		return true, nil
+	})
+	instance := ec2Client.Instance(instanceID)
+	if instance == nil {
+		t.Fatalf("Instance %s does not exist in EC2", instanceID)
+	}
+	if got := aws.StringValue(instance.ImageId); got != baseAMI {
+		t.Errorf("Instance was launched from %s, want %s", got, baseAMI)
+	}
+	if ec2Client.VPC(vpcID) == nil {
+		t.Errorf("VPC %s does not exist in EC2", vpcID)
+	}
+
+	// AMI capture: the AMI is created once the provisioners of the build are done.
+	patch := []byte(`{"status":{"provisionersReady":true}}`)
+	if err := k8sClient.Status().Patch(ctx, build, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		t.Fatalf("Failed to mark the provisioners of the build as ready: %v", err)
+	}
+	eventually(t, "the AMI to be captured", func() (bool, error) {
+		if err := k8sClient.Get(ctx, key, awsBuild); err != nil {
+			return false, err
+		}
+		return awsBuild.Status.Ready && awsBuild.Status.ArtifactRef != nil, nil
+	})
+	image := ec2Client.Image(*awsBuild.Status.ArtifactRef)
+	if image == nil {
+		t.Fatalf("AMI %s does not exist in EC2", *awsBuild.Status.ArtifactRef)
+	}
+	if got := aws.StringValue(image.State); got != ec2.ImageStateAvailable {
+		t.Errorf("AMI state = %s, want %s", got, ec2.ImageStateAvailable)
+	}
+
+	// Cleanup: the instance and the network of the build are deleted, the AMI is kept.
+	eventually(t, "the build resources to be cleaned up", func() (bool, error) {
+		if err := k8sClient.Get(ctx, key, awsBuild); err != nil {
+			return false, err
+		}
+		return awsBuild.Status.CleanedUP, nil
+	})
+	if instance := ec2Client.Instance(instanceID); instance == nil {
+		t.Errorf("Instance %s does not exist in EC2", instanceID)
+	} else if got := aws.StringValue(instance.State.Name); got != ec2.InstanceStateNameTerminated {
+		t.Errorf("Instance state = %s, want %s", got, ec2.InstanceStateNameTerminated)
+	}
+	if ec2Client.VPC(vpcID) != nil {
+		t.Errorf("VPC %s was not deleted", vpcID)
+	}
+	if ec2Client.Image(*awsBuild.Status.ArtifactRef) == nil {
+		t.Errorf("AMI %s was deleted with the build resources", *awsBuild.Status.ArtifactRef)
+	}
+
+	// Deletion: the AWS build is gone once the finalizer is removed.
+	if err := k8sClient.Delete(ctx, awsBuild); err != nil {
+		t.Fatalf("Failed to delete the AWS build: %v", err)
+	}
+	eventually(t, "the AWS build to be deleted", func() (bool, error) {
+		err := k8sClient.Get(ctx, key, &infrav1.AWSBuild{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+}
+
+// startControllerManager starts the API server and a manager running the AWS build controller, configured with
+// the --aws-ec2-endpoint flag, and returns a client of the API server.
+func startControllerManager(ctx context.Context, t *testing.T, ec2Endpoint string) client.Client {
+	t.Helper()
+
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths: []string{
+			filepath.Join("..", "..", "..", "config", "crd", "bases"),
+			// forge is checked out next to the repository, see the replace directive of go.mod.
+			filepath.Join("..", "..", "..", "..", "forge", "config", "crd", "bases"),
+		},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := testEnv.Start()
+	if err != nil {
+		t.Fatalf("Failed to start the test environment: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := testEnv.Stop(); err != nil {
+			t.Errorf("Failed to stop the test environment: %v", err)
+		}
+	})
+
+	scheme := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		infrav1.AddToScheme,
+		buildv1.AddToScheme,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			t.Fatalf("Failed to register scheme: %v", err)
+		}
+	}
+
+	mgr, err := manager.New(cfg, manager.Options{
+		Scheme:  scheme,
+		Metrics: metricsserver.Options{BindAddress: "0"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create the manager: %v", err)
+	}
+
+	opts := &options.ControllerManagerRunOptions{}
+	fs := flag.NewFlagSet(controllerName, flag.ContinueOnError)
+	opts.AddFlags(fs)
+	if err := fs.Parse([]string{fmt.Sprintf("--aws-ec2-endpoint=%s", ec2Endpoint)}); err != nil {
+		t.Fatalf("Failed to parse the flags: %v", err)
+	}
+
+	ctrlCtx := &options.ControllerContext{
+		Ctx:        ctx,
+		RunOptions: opts,
+		Mgr:        mgr,
+		Log:        logr.Discard(),
+	}
+	if err := createAWSBuildController(ctrlCtx); err != nil {
+		t.Fatalf("Failed to create the AWS build controller: %v", err)
+	}
+
+	mgrCtx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := mgr.Start(mgrCtx); err != nil {
+			t.Errorf("Failed to run the manager: %v", err)
+		}
+	}()
+	// The manager is stopped before the API server, cleanups run in reverse order.
+	t.Cleanup(func() {
+		stop()
+		<-done
+	})
+
+	k8sClient, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		t.Fatalf("Failed to create the client: %v", err)
+	}
+	return k8sClient
+}
+
+// eventually polls condition until it returns true or testTimeout expires.
+func eventually(t *testing.T, what string, condition func() (bool, error)) {
+	t.Helper()
+
+	var lastErr error
+	deadline := time.Now().Add(testTimeout)
+	for time.Now().Before(deadline) {
+		done, err := condition()
+		if done {
+			return
+		}
+		lastErr = err
+		time.Sleep(time.Second)
+	}
+	t.Fatalf("Timed out waiting for %s, last error: %v", what, lastErr)
+}
@@ -39,33 +39,299 @@ type DiskType string
 const (
 	// GeneralPurposeSSD represents gp2/gp3 volumes.
 	GeneralPurposeSSD DiskType = "gp2"
+	// GeneralPurposeSSDv3 represents gp3 volumes.
+	GeneralPurposeSSDv3 DiskType = "gp3"
 	// ProvisionedIOPS represents io1/io2 volumes.
 	ProvisionedIOPS DiskType = "io1"
+	// ProvisionedIOPSv2 represents io2 volumes.
+	ProvisionedIOPSv2 DiskType = "io2"
 	// Magnetic represents st1/sc1 volumes.
 	Magnetic DiskType = "st1"
 )
 
+// DefaultVolumeSize is the size in GB used for additional volumes that don't set one.
+const DefaultVolumeSize int64 = 30
+
 // AttachedVolumeSpec defines AWS machine volumes.
 type AttachedVolumeSpec struct {
+	// DeviceName is the device name exposed to the instance (e.g., /dev/sdf).
+	// It is ignored for the root volume, which always uses the root device of the source AMI.
+	// Defaults to the next free /dev/sd[b-z] name for additional volumes.
+	// +optional
+	DeviceName *string `json:"deviceName,omitempty"`
+
 	// VolumeType specifies the type of volume (e.g., gp2, io1).
+	// Defaults to gp3.
 	// +optional
 	VolumeType *DiskType `json:"volumeType,omitempty"`
 
 	// Size is the size of the volume in GB.
-	// Defaults to 30GB.
+	// Defaults to 30GB for additional volumes, the root volume keeps the size of the snapshot of the source AMI.
 	// +optional
 	Size *int64 `json:"size,omitempty"`
 
-	// IOPS specifies the IOPS for provisioned IOPS volumes.
+	// IOPS specifies the IOPS for provisioned IOPS (io1/io2) and gp3 volumes.
 	// +optional
 	IOPS *int64 `json:"iops,omitempty"`
 
+	// Throughput specifies the throughput in MiB/s, only supported by gp3 volumes.
+	// +optional
+	Throughput *int64 `json:"throughput,omitempty"`
+
+	// Encrypted specifies whether the volume should be encrypted.
+	// Setting EncryptionKey implies encryption.
+	// +optional
+	Encrypted *bool `json:"encrypted,omitempty"`
+
 	// EncryptionKey defines the KMS key to be used to encrypt the volume.
 	// +optional
 	EncryptionKey *string `json:"encryptionKey,omitempty"`
 }
 
+// AMISelector selects the source AMI by image filters or an SSM parameter instead of a literal ID.
+// The newest matching image is used.
+type AMISelector struct {
+	// Name is the image name to match, wildcards (*) are allowed.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Owners are the account IDs or aliases (e.g., amazon, self) owning the image.
+	// Required when selecting by name.
+	// +optional
+	Owners []string `json:"owners,omitempty"`
+
+	// Architecture is the image architecture (e.g., x86_64, arm64).
+	// +optional
+	Architecture string `json:"architecture,omitempty"`
+
+	// SSMParameter is the path of an SSM parameter holding the AMI ID
+	// (e.g., /aws/service/canonical/ubuntu/server/22.04/stable/current/amd64/hvm/ebs-gp2/ami-id).
+	// It takes precedence over Name.
+	// +optional
+	SSMParameter string `json:"ssmParameter,omitempty"`
+}
+
+// MarketType describes the market used to purchase the build instance.
+type MarketType string
+
+const (
+	// MarketTypeOnDemand requests on-demand capacity.
+	MarketTypeOnDemand MarketType = "OnDemand"
+	// MarketTypeSpot requests Spot capacity.
+	MarketTypeSpot MarketType = "Spot"
+)
+
+// SpotOptions defines the options used to request Spot capacity for the build instance.
+type SpotOptions struct {
+	// MaxPrice is the maximum hourly price to pay for the Spot instance.
+	// Defaults to the on-demand price.
+	// +optional
+	MaxPrice *string `json:"maxPrice,omitempty"`
+
+	// InterruptionBehavior specifies what happens to the instance when it is interrupted.
+	// Stop and hibernate use a persistent Spot request, which is cancelled when the instance is deleted.
+	// A terminated instance is replaced while it is provisioned, the build fails once it was provisioned.
+	// +kubebuilder:validation:Enum=terminate;stop;hibernate
+	// +kubebuilder:default=terminate
+	// +optional
+	InterruptionBehavior string `json:"interruptionBehavior,omitempty"`
+
+	// FallbackInstanceTypes are tried in order, still on Spot, when there is no Spot capacity for InstanceType
+	// or the instance is interrupted.
+	// +optional
+	FallbackInstanceTypes []string `json:"fallbackInstanceTypes,omitempty"`
+
+	// FallbackToOnDemand specifies whether an on-demand instance of InstanceType is launched once
+	// all Spot instance types are exhausted.
+	// +optional
+	FallbackToOnDemand bool `json:"fallbackToOnDemand,omitempty"`
+}
+
+// AssumeRoleSpec defines the IAM role assumed to manage the resources of the build.
+type AssumeRoleSpec struct {
+	// RoleARN is the ARN of the role to assume.
+	RoleARN string `json:"roleARN"`
+
+	// ExternalID is passed to AWS STS when the trust policy of the role requires it.
+	// +optional
+	ExternalID string `json:"externalID,omitempty"`
+
+	// SessionName is the name of the role session, recorded in CloudTrail.
+	// Defaults to forge-provider-aws.
+	// +kubebuilder:validation:MaxLength=64
+	// +optional
+	SessionName string `json:"sessionName,omitempty"`
+}
+
+// EndpointsSpec configures how the AWS APIs are reached, e.g., in GovCloud or from an air-gapped partition.
+type EndpointsSpec struct {
+	// Services override the endpoints of AWS services, e.g., with the private DNS name of a VPC endpoint.
+	// The overrides apply to the region of the build, the AMI can't be copied to other regions when the EC2
+	// endpoint is overridden.
+	// +listType=map
+	// +listMapKey=service
+	// +optional
+	Services []ServiceEndpoint `json:"services,omitempty"`
+
+	// UseFIPS uses the FIPS endpoints of the services that are not overridden.
+	// +optional
+	UseFIPS bool `json:"useFIPS,omitempty"`
+
+	// UseDualStack uses the dual-stack (IPv4 and IPv6) endpoints of the services that are not overridden.
+	// +optional
+	UseDualStack bool `json:"useDualStack,omitempty"`
+
+	// CABundleRef is a Secret holding PEM-encoded CA certificates under its ca.crt key.
+	// They are trusted for the AWS API calls in addition to the system roots.
+	// In an AWSBuild, it must be in the namespace of the AWSBuild, which is used when the namespace is empty.
+	// In an AWSIdentity, its namespace must be set.
+	// +optional
+	CABundleRef *corev1.SecretReference `json:"caBundleRef,omitempty"`
+
+	// ProxyURL is the URL of the HTTP proxy of the AWS API calls.
+	// Defaults to the proxy of the environment of the controller.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +optional
+	ProxyURL string `json:"proxyURL,omitempty"`
+}
+
+// ServiceEndpoint overrides the endpoint of an AWS service.
+type ServiceEndpoint struct {
+	// Service is the endpoint ID of the service.
+	// +kubebuilder:validation:Enum=ec2;iam;ssm;sts;s3
+	Service string `json:"service"`
+
+	// URL is the endpoint of the service, e.g., https://vpce-0123456789abcdef0-abcdefgh.ec2.us-gov-west-1.vpce.amazonaws.com.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+}
+
+// ImageSpec configures the AMI produced by the build.
+type ImageSpec struct {
+	// Tags are added to the AMI and the snapshots of its volumes.
+	// The tags set by the controller, e.g., forge-build-name or forge-source-ami, take precedence over them.
+	// +kubebuilder:validation:MaxProperties=30
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// BuildLabelPrefix selects the labels of the Build added as tags to the AMI and its snapshots,
+	// e.g., builds.example.com/ to record the git revision of the Build. No label is added when it is empty.
+	// +optional
+	BuildLabelPrefix string `json:"buildLabelPrefix,omitempty"`
+
+	// NameTemplate is the Go template of the name of the AMI, e.g., {{.BuildName}}-{{.Timestamp}}-{{.SourceAMI}}.
+	// It is rendered with the BuildName, Namespace, BuildUID, SourceAMI and Timestamp, the creation time of the build
+	// as YYYYMMDDhhmmss. Characters AWS does not allow in AMI names are replaced with dashes and names longer
+	// than 128 characters are shortened with a hash. Defaults to {{.BuildName}}.
+	// +optional
+	NameTemplate string `json:"nameTemplate,omitempty"`
+
+	// DeregisterPrevious deregisters the AMIs of the account with the same name created before the build.
+	// Otherwise the name of the AMI is suffixed with the UID of the build when it is already used by another AMI.
+	// +optional
+	DeregisterPrevious bool `json:"deregisterPrevious,omitempty"`
+
+	// Retention removes the previous AMIs selected by the policy once, when the AMI of the build is available.
+	// +optional
+	Retention *ImageRetentionPolicy `json:"retention,omitempty"`
+
+	// CopyToRegions are the regions the AMI is copied to once it is available.
+	// The build is ready when all the copies are available.
+	// +listType=map
+	// +listMapKey=region
+	// +optional
+	CopyToRegions []ImageCopy `json:"copyToRegions,omitempty"`
+
+	// LaunchPermissions share the AMI and its copies once they are available.
+	// They are revoked before the retention policy removes an AMI.
+	// +optional
+	LaunchPermissions *LaunchPermissions `json:"launchPermissions,omitempty"`
+}
+
+// LaunchPermissions are the accounts and organizations allowed to launch instances from an AMI.
+type LaunchPermissions struct {
+	// AccountIDs are the AWS accounts allowed to launch the AMI. They are also allowed to create volumes
+	// from its encrypted snapshots, the KMS keys of the snapshots must allow them too.
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^[0-9]{12}$`
+	// +optional
+	AccountIDs []string `json:"accountIDs,omitempty"`
+
+	// OrganizationARNs are the AWS Organizations whose accounts are allowed to launch the AMI.
+	// +listType=set
+	// +optional
+	OrganizationARNs []string `json:"organizationARNs,omitempty"`
+
+	// OrganizationalUnitARNs are the organizational units whose accounts are allowed to launch the AMI.
+	// +listType=set
+	// +optional
+	OrganizationalUnitARNs []string `json:"organizationalUnitARNs,omitempty"`
+
+	// Public allows all AWS accounts to launch the AMI.
+	// +optional
+	Public bool `json:"public,omitempty"`
+}
+
+// ImageCopy configures the copy of the AMI to another region.
+type ImageCopy struct {
+	// Region is the region of the copy.
+	// +kubebuilder:validation:MinLength=1
+	Region string `json:"region"`
+
+	// KMSKeyID is the KMS key of the region encrypting the snapshots of the copy.
+	// The snapshots keep the encryption of the source AMI when it is not set.
+	// +optional
+	KMSKeyID string `json:"kmsKeyID,omitempty"`
+}
+
+// ImageCopyStatus is the state of the copy of the AMI to a region.
+type ImageCopyStatus struct {
+	// Region is the region of the copy.
+	Region string `json:"region"`
+
+	// ImageID is the ID of the copy in the region.
+	// +optional
+	ImageID string `json:"imageID,omitempty"`
+
+	// State is the state of the copy, e.g., pending, available or failed.
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// FailureMessage is the reason AWS gives for a failed copy.
+	// +optional
+	FailureMessage string `json:"failureMessage,omitempty"`
+}
+
+// ImageRetentionPolicy selects previous AMIs of the account and removes them with their EBS snapshots.
+// An AMI is removed when none of the keep rules keeps it, the AMI of the build and the newer AMIs are always kept.
+// +kubebuilder:validation:XValidation:rule="has(self.keepLast) || has(self.keepNewerThan)",message="keepLast or keepNewerThan is required"
+type ImageRetentionPolicy struct {
+	// MatchTags select the AMIs the policy applies to among the available AMIs managed by Forge in the account,
+	// in the region of the build and in each region of CopyToRegions, whichever build created them, e.g., a tag set
+	// on all the AMIs of an image family with Tags.
+	// +kubebuilder:validation:MinProperties=1
+	MatchTags map[string]string `json:"matchTags"`
+
+	// KeepLast keeps the newest AMIs, the AMI of the build included.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	KeepLast *int32 `json:"keepLast,omitempty"`
+
+	// KeepNewerThan keeps the AMIs created within the duration, e.g., 720h.
+	// +optional
+	KeepNewerThan *metav1.Duration `json:"keepNewerThan,omitempty"`
+
+	// KeepTags keeps the AMIs having all the tags, e.g., pinned: "true".
+	// +optional
+	KeepTags map[string]string `json:"keepTags,omitempty"`
+
+	// DryRun only reports the AMIs that would be removed, in the ExpiredImages of the status.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
 // AWSBuildSpec defines the desired state of AWSBuild.
+// +kubebuilder:validation:XValidation:rule="!(has(self.credentialsRef) && has(self.identityRef))",message="credentialsRef and identityRef are mutually exclusive"
 type AWSBuildSpec struct {
 	// Embedded ConnectionSpec to define default connection credentials.
 	buildv1.ConnectionSpec `json:",inline"`
@@ -76,6 +342,22 @@ type AWSBuildSpec struct {
 	// InstanceType is the EC2 instance type (e.g., t2.micro, m5.large).
 	InstanceType string `json:"instanceType"`
 
+	// MarketType is the market used to purchase the instance.
+	// +kubebuilder:validation:Enum=OnDemand;Spot
+	// +kubebuilder:default=OnDemand
+	// +optional
+	MarketType MarketType `json:"marketType,omitempty"`
+
+	// SpotOptions configures the Spot request when MarketType is Spot.
+	// +optional
+	SpotOptions *SpotOptions `json:"spotOptions,omitempty"`
+
+	// AvailabilityZones are the preferred availability zones of the instance, in order.
+	// The first zone offering the instance type is used, the next ones are tried when it runs out of capacity.
+	// Defaults to the zones of the region offering the instance type. Ignored when Network.SubnetID is set.
+	// +optional
+	AvailabilityZones []string `json:"availabilityZones,omitempty"`
+
 	// VPCName encapsultes all the things related to AWS VPC
 	// +optional
 	Network NetworkSpec `json:"network"`
@@ -84,6 +366,10 @@ type AWSBuildSpec struct {
 	// +optional
 	AMI *string `json:"ami,omitempty"`
 
+	// AMISelector selects the newest matching AMI when AMI is not set.
+	// +optional
+	AMISelector *AMISelector `json:"amiSelector,omitempty"`
+
 	// RootVolume specifies the root volume configuration.
 	// +optional
 	RootVolume *AttachedVolumeSpec `json:"rootVolume,omitempty"`
@@ -100,14 +386,45 @@ type AWSBuildSpec struct {
 	// +optional
 	IAMRole *string `json:"iamRole,omitempty"`
 
+	// IAMInstanceProfile is the name of the instance profile to associate with the instance.
+	// If not set, it is resolved from IAMRole.
+	// +optional
+	IAMInstanceProfile *string `json:"iamInstanceProfile,omitempty"`
+
+	// CreateInstanceProfile specifies whether a temporary instance profile should be created for IAMRole
+	// instead of using an existing one. The created profile is deleted when the build is cleaned up.
+	// +optional
+	CreateInstanceProfile bool `json:"createInstanceProfile,omitempty"`
+
 	// InstanceID is the unique identifier as specified by the cloud provider.
 	// +optional
 	InstanceID *string `json:"instanceID,omitempty"`
 
-	// CredentialsRef is a reference to a Secret that contains the credentials to use for provisioning this cluster. If not
-	// supplied then the credentials of the controller will be used.
+	// CredentialsRef is a reference to a Secret that contains the credentials to use for provisioning this cluster.
+	// Either CredentialsRef or IdentityRef is required, the credentials of the controller are only used through
+	// an AWSIdentity of type Controller.
+	// The Secret must be in the namespace of the AWSBuild, which is used when the namespace is empty.
 	// +optional
 	CredentialsRef *corev1.SecretReference `json:"credentialsRef,omitempty"`
+
+	// IdentityRef is a reference to the cluster-scoped AWSIdentity to use instead of CredentialsRef.
+	// The namespace of the AWSBuild must be allowed by the identity.
+	// +optional
+	IdentityRef *AWSIdentityReference `json:"identityRef,omitempty"`
+
+	// AssumeRole is assumed with the credentials of CredentialsRef, or of an AWSIdentity allowing it, for cross-account builds.
+	// +optional
+	AssumeRole *AssumeRoleSpec `json:"assumeRole,omitempty"`
+
+	// Endpoints configures how the AWS APIs are reached, it takes precedence over the endpoints of the identity.
+	// Services, CABundleRef and ProxyURL require CredentialsRef or an identity allowing endpoint overrides,
+	// the credentials of the controller are never sent to endpoints chosen by an AWSBuild.
+	// +optional
+	Endpoints *EndpointsSpec `json:"endpoints,omitempty"`
+
+	// Image configures the AMI produced by the build.
+	// +optional
+	Image *ImageSpec `json:"image,omitempty"`
 }
 
 // AWSBuildStatus defines the observed state of AWSBuild.
@@ -131,11 +448,57 @@ type AWSBuildStatus struct {
 	// +optional
 	InstanceStatus *InstanceStatus `json:"instanceState,omitempty"`
 
+	// AdditionalSecurityGroupIDs are the resolved IDs of the additional security groups attached to the instance.
+	// +optional
+	AdditionalSecurityGroupIDs []string `json:"additionalSecurityGroupIDs,omitempty"`
+
+	// EgressIP is the public IP of the controller detected for the SSH rule when spec.network.sshIngress is not set.
+	// It is detected once so that the rule doesn't change between reconciles.
+	// +optional
+	EgressIP *string `json:"egressIP,omitempty"`
+
+	// RouteTableID is the ID of the route table created by Forge for the build subnet.
+	// +optional
+	RouteTableID *string `json:"routeTableID,omitempty"`
+
+	// SourceAMI is the AMI ID the instance was launched from.
+	// +optional
+	SourceAMI *string `json:"sourceAMI,omitempty"`
+
+	// LaunchAttempt is the index of the launch option used for the instance.
+	// It advances when there is no Spot capacity or the Spot instance is interrupted.
+	// +optional
+	LaunchAttempt int32 `json:"launchAttempt,omitempty"`
+
+	// AvailabilityZone is the availability zone of the subnet and the instance.
+	// +optional
+	AvailabilityZone *string `json:"availabilityZone,omitempty"`
+
+	// ImageName is the name of the AMI produced by the build, rendered once from Image.NameTemplate.
+	// +optional
+	ImageName *string `json:"imageName,omitempty"`
+
+	// ImageCopies are the copies of the AMI to the regions of Image.CopyToRegions.
+	// +listType=map
+	// +listMapKey=region
+	// +optional
+	ImageCopies []ImageCopyStatus `json:"imageCopies,omitempty"`
+
+	// SharedImages are the IDs of the AMI and the copies shared with Image.LaunchPermissions.
+	// +optional
+	SharedImages []string `json:"sharedImages,omitempty"`
+
+	// ExpiredImages are the IDs of the previous AMIs and of their copies removed by the retention policy,
+	// or that would be removed when it is a dry run.
+	// +optional
+	ExpiredImages []string `json:"expiredImages,omitempty"`
+
 	// ArtifactRef is the reference to the built artifact.
 	// +optional
 	ArtifactRef *string `json:"artifactRef,omitempty"`
 
-	// FailureReason describes why the build failed, if applicable.
+	// FailureReason describes why the build failed, if applicable, e.g., with the code of the AWS error that failed it.
+	// A failed build is not retried, its resources are cleaned up.
 	// +optional
 	FailureReason *string `json:"failureReason,omitempty"`
 
@@ -164,6 +527,16 @@ type AWSBuild struct {
 	Status AWSBuildStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the observations of the operational state of the AWSBuild resource.
+func (r *AWSBuild) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the AWSBuild to the predescribed clusterv1.Conditions.
+func (r *AWSBuild) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // AWSBuildList contains a list of AWSBuilds.
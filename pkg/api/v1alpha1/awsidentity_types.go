@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The Forge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AWSIdentityKind is the kind of an AWSIdentity Object.
+const AWSIdentityKind string = "AWSIdentity"
+
+// AWSIdentityType is the source of the credentials of an AWSIdentity.
+type AWSIdentityType string
+
+const (
+	// AWSIdentityTypeStatic uses the keys of a Secret.
+	AWSIdentityTypeStatic AWSIdentityType = "Static"
+	// AWSIdentityTypeAssumeRole assumes a role with the credentials of the controller.
+	AWSIdentityTypeAssumeRole AWSIdentityType = "AssumeRole"
+	// AWSIdentityTypeController uses the credentials of the controller.
+	AWSIdentityTypeController AWSIdentityType = "Controller"
+)
+
+// AWSIdentitySpec defines the credentials of an AWSIdentity and who may use them.
+type AWSIdentitySpec struct {
+	// Type is the source of the credentials.
+	// +kubebuilder:validation:Enum=Static;AssumeRole;Controller
+	Type AWSIdentityType `json:"type"`
+
+	// SecretRef is the Secret holding the credentials of a Static identity, in the format of AWSBuild credentialsRef.
+	// Its namespace must be set.
+	// +optional
+	SecretRef *corev1.SecretReference `json:"secretRef,omitempty"`
+
+	// AssumeRole is the role assumed by an AssumeRole identity.
+	// +optional
+	AssumeRole *AssumeRoleSpec `json:"assumeRole,omitempty"`
+
+	// Endpoints configures how the AWS APIs are reached with the identity.
+	// The AWSBuilds may only replace its FIPS and dual-stack settings, unless AllowEndpointOverrides is set.
+	// +optional
+	Endpoints *EndpointsSpec `json:"endpoints,omitempty"`
+
+	// AllowEndpointOverrides allows the AWSBuilds to set their own endpoints, proxy and CA bundle.
+	// The credentials of the identity are then sent to endpoints chosen by the AWSBuilds,
+	// only enable it when all the allowed namespaces are trusted with the credentials.
+	// +optional
+	AllowEndpointOverrides bool `json:"allowEndpointOverrides,omitempty"`
+
+	// AllowAssumeRole allows the AWSBuilds to assume the role of their spec.assumeRole with the credentials of the identity.
+	// The AWSBuilds may then reach any role trusting the identity, only enable it when all the allowed namespaces
+	// are trusted with these roles.
+	// +optional
+	AllowAssumeRole bool `json:"allowAssumeRole,omitempty"`
+
+	// AllowedNamespaces are the namespaces of the AWSBuilds allowed to use the identity.
+	// No namespace is allowed when it is not set.
+	// +optional
+	AllowedNamespaces *AllowedNamespaces `json:"allowedNamespaces,omitempty"`
+}
+
+// AllowedNamespaces selects namespaces by name or by labels.
+// An empty AllowedNamespaces allows all namespaces.
+type AllowedNamespaces struct {
+	// NamespaceList are the names of the allowed namespaces.
+	// +optional
+	NamespaceList []string `json:"list,omitempty"`
+
+	// Selector selects the allowed namespaces by labels, an empty selector selects all namespaces.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// AWSIdentityReference references an AWSIdentity.
+type AWSIdentityReference struct {
+	// Name is the name of the AWSIdentity.
+	Name string `json:"name"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=awsidentities,scope=Cluster,categories=forge;aws,singular=awsidentity
+// +kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.type",description="Type of the identity"
+
+// AWSIdentity is the Schema for the awsidentities API.
+// It lets platform admins own AWS credentials that AWSBuilds of the allowed namespaces reference by name.
+type AWSIdentity struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AWSIdentitySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AWSIdentityList contains a list of AWSIdentities.
+type AWSIdentityList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AWSIdentity `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AWSIdentity{}, &AWSIdentityList{})
+}
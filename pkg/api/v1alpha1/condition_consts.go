@@ -0,0 +1,40 @@
+/*
+Copyright 2024 The Forge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+const (
+	// SpotInstanceCondition reports on the Spot capacity used for the build instance.
+	SpotInstanceCondition clusterv1.ConditionType = "SpotInstance"
+
+	// SpotCapacityUnavailableReason used when there is no Spot capacity for the requested instance type.
+	SpotCapacityUnavailableReason = "SpotCapacityUnavailable"
+
+	// SpotInstanceInterruptedReason used when the Spot instance was interrupted by AWS.
+	SpotInstanceInterruptedReason = "SpotInstanceInterrupted"
+
+	// FallbackToOnDemandReason used when the instance was launched on-demand after Spot failed.
+	FallbackToOnDemandReason = "FallbackToOnDemand"
+
+	// RegionalEndpointUnavailableReason used when the EC2 endpoint of the build only serves its region and can't reach
+	// the other regions of the build, e.g., to copy its AMI.
+	RegionalEndpointUnavailableReason = "RegionalEndpointUnavailable"
+
+	// ImageRetentionCondition reports that the retention policy was applied once the AMI of the build was available.
+	ImageRetentionCondition clusterv1.ConditionType = "ImageRetention"
+)
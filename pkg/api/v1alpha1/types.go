@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 // NetworkSpec encapsulates all things related to an AWS network.
+// +kubebuilder:validation:XValidation:rule="!has(self.private) || !has(self.vpcID)",message="private networks require a VPC created by Forge"
 type NetworkSpec struct {
 
 	// Name specifies the Name of the Virtual Private Cloud (VPC) for the instance.
@@ -27,17 +28,149 @@ type NetworkSpec struct {
 	// +optional
 	VPCID *string `json:"vpcID,omitempty"`
 
+	// VPCCIDR is the IPv4 CIDR block of the VPC created by Forge.
+	// Defaults to 10.0.0.0/16.
+	// +optional
+	VPCCIDR string `json:"vpcCIDR,omitempty"`
+
 	// SubnetID specifies the ID of the subnet for the instance.
 	// +optional
 	SubnetID *string `json:"subnetID,omitempty"`
 
+	// SubnetPrefixLength is the prefix length of the subnet created by Forge, used to
+	// find a free range in the VPC when SubnetCIDR is not set.
+	// Defaults to 24.
+	// +kubebuilder:validation:Minimum=16
+	// +kubebuilder:validation:Maximum=28
+	// +optional
+	SubnetPrefixLength *int32 `json:"subnetPrefixLength,omitempty"`
+
+	// SubnetCIDR is the IPv4 CIDR block of the subnet created by Forge.
+	// It must be within the VPC CIDR block and must not overlap existing subnets.
+	// +optional
+	SubnetCIDR *string `json:"subnetCIDR,omitempty"`
+
 	// SecurityGroupID list the security group to associate with the instance.
 	// +optional
 	SecurityGroupID *string `json:"securityGroup,omitempty"`
 
+	// AdditionalSecurityGroups are attached to the instance alongside the managed security group.
+	// They must belong to the VPC of the instance and are never deleted by Forge.
+	// +optional
+	AdditionalSecurityGroups []SecurityGroupReference `json:"additionalSecurityGroups,omitempty"`
+
+	// Private launches the instance without a public IP, PublicIP is ignored.
+	// Egress goes through a NAT gateway or VPC endpoints created in the managed VPC,
+	// private networks are not supported in VPCs that were not created by Forge.
+	// +optional
+	Private *PrivateNetworkSpec `json:"private,omitempty"`
+
 	// AssignPublicIP specifies whether to assign a public IP to the instance.
 	// +optional
 	AssignPublicIP *bool `json:"assignPublicIP,omitempty"`
+
+	// SSHIngress restricts the peers allowed to connect to the instance over SSH.
+	// Defaults to the CIDR block of the VPC for private networks, the controller reaches them on their private IP.
+	// Defaults to the public egress IP of the controller otherwise, it must be set when the IP can't be detected.
+	// +optional
+	SSHIngress *SecurityGroupRulePeers `json:"sshIngress,omitempty"`
+
+	// AdditionalIngressRules are added to the managed security group next to the SSH rule (e.g., WinRM on 5986).
+	// +optional
+	AdditionalIngressRules []SecurityGroupRule `json:"additionalIngressRules,omitempty"`
+
+	// EgressRules replace the default allow-all egress rule of the managed security group when set.
+	// +optional
+	EgressRules []SecurityGroupRule `json:"egressRules,omitempty"`
+}
+
+// PrivateEgressMode is the way instances in a private subnet reach AWS and the internet.
+type PrivateEgressMode string
+
+const (
+	// PrivateEgressNATGateway routes egress through a managed NAT gateway with an Elastic IP.
+	PrivateEgressNATGateway PrivateEgressMode = "NATGateway"
+	// PrivateEgressVPCEndpoints only allows to reach AWS services through VPC endpoints.
+	PrivateEgressVPCEndpoints PrivateEgressMode = "VPCEndpoints"
+)
+
+// PrivateNetworkSpec defines the egress of a private build instance.
+type PrivateNetworkSpec struct {
+	// Egress is the way the instance reaches AWS and the internet.
+	// The egress resources are only created in VPCs managed by Forge.
+	// +kubebuilder:validation:Enum=NATGateway;VPCEndpoints
+	// +kubebuilder:default=NATGateway
+	// +optional
+	Egress PrivateEgressMode `json:"egress,omitempty"`
+
+	// VPCEndpoints are the AWS services reachable through VPC endpoints when Egress is VPCEndpoints.
+	// s3 uses a gateway endpoint, the other services use interface endpoints with private DNS.
+	// Defaults to s3, ssm, ssmmessages and ec2messages.
+	// +optional
+	VPCEndpoints []string `json:"vpcEndpoints,omitempty"`
+}
+
+// SecurityGroupReference references security groups either by ID or by tags.
+type SecurityGroupReference struct {
+	// ID is the ID of the security group.
+	// +optional
+	ID *string `json:"id,omitempty"`
+
+	// Tags selects the security groups of the VPC that have all the given tags.
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// SecurityGroupRule defines a rule of the managed security group.
+type SecurityGroupRule struct {
+	// Description is the description of the rule.
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Protocol is the IP protocol of the rule (tcp, udp, icmp or -1 for all protocols).
+	// +kubebuilder:default=tcp
+	// +optional
+	Protocol string `json:"protocol,omitempty"`
+
+	// FromPort is the start of the port range, ignored for protocol -1.
+	// +kubebuilder:validation:Minimum=-1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	FromPort int64 `json:"fromPort,omitempty"`
+
+	// ToPort is the end of the port range. Defaults to FromPort.
+	// +kubebuilder:validation:Minimum=-1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	ToPort *int64 `json:"toPort,omitempty"`
+
+	// SecurityGroupRulePeers are the peers of the rule.
+	// Ingress rules without peers default to the SSH ingress peers.
+	SecurityGroupRulePeers `json:",inline"`
+}
+
+// SecurityGroupRulePeers defines the peers a security group rule applies to.
+type SecurityGroupRulePeers struct {
+	// CIDRBlocks are the IPv4 CIDR blocks of the rule.
+	// +optional
+	CIDRBlocks []string `json:"cidrBlocks,omitempty"`
+
+	// IPv6CIDRBlocks are the IPv6 CIDR blocks of the rule.
+	// +optional
+	IPv6CIDRBlocks []string `json:"ipv6CidrBlocks,omitempty"`
+
+	// PrefixListIDs are the IDs of the managed prefix lists of the rule.
+	// +optional
+	PrefixListIDs []string `json:"prefixListIDs,omitempty"`
+
+	// SecurityGroupIDs are the IDs of the security groups of the rule.
+	// +optional
+	SecurityGroupIDs []string `json:"securityGroupIDs,omitempty"`
+}
+
+// IsEmpty returns true if no peer is set.
+func (p *SecurityGroupRulePeers) IsEmpty() bool {
+	return p == nil || len(p.CIDRBlocks)+len(p.IPv6CIDRBlocks)+len(p.PrefixListIDs)+len(p.SecurityGroupIDs) == 0
 }
 
 // InstanceStatus describes the state of an EC2 instance.
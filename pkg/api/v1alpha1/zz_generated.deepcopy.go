@@ -22,10 +22,31 @@ package v1alpha1
 
 import (
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AMISelector) DeepCopyInto(out *AMISelector) {
+	*out = *in
+	if in.Owners != nil {
+		in, out := &in.Owners, &out.Owners
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AMISelector.
+func (in *AMISelector) DeepCopy() *AMISelector {
+	if in == nil {
+		return nil
+	}
+	out := new(AMISelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AWSBuild) DeepCopyInto(out *AWSBuild) {
 	*out = *in
@@ -89,12 +110,27 @@ func (in *AWSBuildList) DeepCopyObject() runtime.Object {
 func (in *AWSBuildSpec) DeepCopyInto(out *AWSBuildSpec) {
 	*out = *in
 	in.ConnectionSpec.DeepCopyInto(&out.ConnectionSpec)
+	if in.SpotOptions != nil {
+		in, out := &in.SpotOptions, &out.SpotOptions
+		*out = new(SpotOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AvailabilityZones != nil {
+		in, out := &in.AvailabilityZones, &out.AvailabilityZones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	in.Network.DeepCopyInto(&out.Network)
 	if in.AMI != nil {
 		in, out := &in.AMI, &out.AMI
 		*out = new(string)
 		**out = **in
 	}
+	if in.AMISelector != nil {
+		in, out := &in.AMISelector, &out.AMISelector
+		*out = new(AMISelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.RootVolume != nil {
 		in, out := &in.RootVolume, &out.RootVolume
 		*out = new(AttachedVolumeSpec)
@@ -117,6 +153,11 @@ func (in *AWSBuildSpec) DeepCopyInto(out *AWSBuildSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.IAMInstanceProfile != nil {
+		in, out := &in.IAMInstanceProfile, &out.IAMInstanceProfile
+		*out = new(string)
+		**out = **in
+	}
 	if in.InstanceID != nil {
 		in, out := &in.InstanceID, &out.InstanceID
 		*out = new(string)
@@ -127,6 +168,26 @@ func (in *AWSBuildSpec) DeepCopyInto(out *AWSBuildSpec) {
 		*out = new(v1.SecretReference)
 		**out = **in
 	}
+	if in.IdentityRef != nil {
+		in, out := &in.IdentityRef, &out.IdentityRef
+		*out = new(AWSIdentityReference)
+		**out = **in
+	}
+	if in.AssumeRole != nil {
+		in, out := &in.AssumeRole, &out.AssumeRole
+		*out = new(AssumeRoleSpec)
+		**out = **in
+	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = new(EndpointsSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(ImageSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSBuildSpec.
@@ -147,6 +208,51 @@ func (in *AWSBuildStatus) DeepCopyInto(out *AWSBuildStatus) {
 		*out = new(InstanceStatus)
 		**out = **in
 	}
+	if in.AdditionalSecurityGroupIDs != nil {
+		in, out := &in.AdditionalSecurityGroupIDs, &out.AdditionalSecurityGroupIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EgressIP != nil {
+		in, out := &in.EgressIP, &out.EgressIP
+		*out = new(string)
+		**out = **in
+	}
+	if in.RouteTableID != nil {
+		in, out := &in.RouteTableID, &out.RouteTableID
+		*out = new(string)
+		**out = **in
+	}
+	if in.SourceAMI != nil {
+		in, out := &in.SourceAMI, &out.SourceAMI
+		*out = new(string)
+		**out = **in
+	}
+	if in.AvailabilityZone != nil {
+		in, out := &in.AvailabilityZone, &out.AvailabilityZone
+		*out = new(string)
+		**out = **in
+	}
+	if in.ImageName != nil {
+		in, out := &in.ImageName, &out.ImageName
+		*out = new(string)
+		**out = **in
+	}
+	if in.ImageCopies != nil {
+		in, out := &in.ImageCopies, &out.ImageCopies
+		*out = make([]ImageCopyStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.SharedImages != nil {
+		in, out := &in.SharedImages, &out.SharedImages
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExpiredImages != nil {
+		in, out := &in.ExpiredImages, &out.ExpiredImages
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.ArtifactRef != nil {
 		in, out := &in.ArtifactRef, &out.ArtifactRef
 		*out = new(string)
@@ -181,9 +287,162 @@ func (in *AWSBuildStatus) DeepCopy() *AWSBuildStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSIdentity) DeepCopyInto(out *AWSIdentity) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSIdentity.
+func (in *AWSIdentity) DeepCopy() *AWSIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AWSIdentity) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSIdentityList) DeepCopyInto(out *AWSIdentityList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AWSIdentity, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSIdentityList.
+func (in *AWSIdentityList) DeepCopy() *AWSIdentityList {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSIdentityList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AWSIdentityList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSIdentityReference) DeepCopyInto(out *AWSIdentityReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSIdentityReference.
+func (in *AWSIdentityReference) DeepCopy() *AWSIdentityReference {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSIdentityReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSIdentitySpec) DeepCopyInto(out *AWSIdentitySpec) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+	if in.AssumeRole != nil {
+		in, out := &in.AssumeRole, &out.AssumeRole
+		*out = new(AssumeRoleSpec)
+		**out = **in
+	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = new(EndpointsSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = new(AllowedNamespaces)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSIdentitySpec.
+func (in *AWSIdentitySpec) DeepCopy() *AWSIdentitySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSIdentitySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AllowedNamespaces) DeepCopyInto(out *AllowedNamespaces) {
+	*out = *in
+	if in.NamespaceList != nil {
+		in, out := &in.NamespaceList, &out.NamespaceList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AllowedNamespaces.
+func (in *AllowedNamespaces) DeepCopy() *AllowedNamespaces {
+	if in == nil {
+		return nil
+	}
+	out := new(AllowedNamespaces)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AssumeRoleSpec) DeepCopyInto(out *AssumeRoleSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AssumeRoleSpec.
+func (in *AssumeRoleSpec) DeepCopy() *AssumeRoleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AssumeRoleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AttachedVolumeSpec) DeepCopyInto(out *AttachedVolumeSpec) {
 	*out = *in
+	if in.DeviceName != nil {
+		in, out := &in.DeviceName, &out.DeviceName
+		*out = new(string)
+		**out = **in
+	}
 	if in.VolumeType != nil {
 		in, out := &in.VolumeType, &out.VolumeType
 		*out = new(DiskType)
@@ -199,6 +458,16 @@ func (in *AttachedVolumeSpec) DeepCopyInto(out *AttachedVolumeSpec) {
 		*out = new(int64)
 		**out = **in
 	}
+	if in.Throughput != nil {
+		in, out := &in.Throughput, &out.Throughput
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Encrypted != nil {
+		in, out := &in.Encrypted, &out.Encrypted
+		*out = new(bool)
+		**out = **in
+	}
 	if in.EncryptionKey != nil {
 		in, out := &in.EncryptionKey, &out.EncryptionKey
 		*out = new(string)
@@ -238,6 +507,137 @@ func (in *BuildParams) DeepCopy() *BuildParams {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointsSpec) DeepCopyInto(out *EndpointsSpec) {
+	*out = *in
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = make([]ServiceEndpoint, len(*in))
+		copy(*out, *in)
+	}
+	if in.CABundleRef != nil {
+		in, out := &in.CABundleRef, &out.CABundleRef
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointsSpec.
+func (in *EndpointsSpec) DeepCopy() *EndpointsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageCopy) DeepCopyInto(out *ImageCopy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageCopy.
+func (in *ImageCopy) DeepCopy() *ImageCopy {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageCopy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageCopyStatus) DeepCopyInto(out *ImageCopyStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageCopyStatus.
+func (in *ImageCopyStatus) DeepCopy() *ImageCopyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageCopyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageRetentionPolicy) DeepCopyInto(out *ImageRetentionPolicy) {
+	*out = *in
+	if in.MatchTags != nil {
+		in, out := &in.MatchTags, &out.MatchTags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.KeepLast != nil {
+		in, out := &in.KeepLast, &out.KeepLast
+		*out = new(int32)
+		**out = **in
+	}
+	if in.KeepNewerThan != nil {
+		in, out := &in.KeepNewerThan, &out.KeepNewerThan
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.KeepTags != nil {
+		in, out := &in.KeepTags, &out.KeepTags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRetentionPolicy.
+func (in *ImageRetentionPolicy) DeepCopy() *ImageRetentionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageRetentionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageSpec) DeepCopyInto(out *ImageSpec) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(ImageRetentionPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CopyToRegions != nil {
+		in, out := &in.CopyToRegions, &out.CopyToRegions
+		*out = make([]ImageCopy, len(*in))
+		copy(*out, *in)
+	}
+	if in.LaunchPermissions != nil {
+		in, out := &in.LaunchPermissions, &out.LaunchPermissions
+		*out = new(LaunchPermissions)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSpec.
+func (in *ImageSpec) DeepCopy() *ImageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in Labels) DeepCopyInto(out *Labels) {
 	{
@@ -259,6 +659,36 @@ func (in Labels) DeepCopy() Labels {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LaunchPermissions) DeepCopyInto(out *LaunchPermissions) {
+	*out = *in
+	if in.AccountIDs != nil {
+		in, out := &in.AccountIDs, &out.AccountIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.OrganizationARNs != nil {
+		in, out := &in.OrganizationARNs, &out.OrganizationARNs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.OrganizationalUnitARNs != nil {
+		in, out := &in.OrganizationalUnitARNs, &out.OrganizationalUnitARNs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LaunchPermissions.
+func (in *LaunchPermissions) DeepCopy() *LaunchPermissions {
+	if in == nil {
+		return nil
+	}
+	out := new(LaunchPermissions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkSpec) DeepCopyInto(out *NetworkSpec) {
 	*out = *in
@@ -272,16 +702,57 @@ func (in *NetworkSpec) DeepCopyInto(out *NetworkSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.SubnetPrefixLength != nil {
+		in, out := &in.SubnetPrefixLength, &out.SubnetPrefixLength
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SubnetCIDR != nil {
+		in, out := &in.SubnetCIDR, &out.SubnetCIDR
+		*out = new(string)
+		**out = **in
+	}
 	if in.SecurityGroupID != nil {
 		in, out := &in.SecurityGroupID, &out.SecurityGroupID
 		*out = new(string)
 		**out = **in
 	}
+	if in.AdditionalSecurityGroups != nil {
+		in, out := &in.AdditionalSecurityGroups, &out.AdditionalSecurityGroups
+		*out = make([]SecurityGroupReference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Private != nil {
+		in, out := &in.Private, &out.Private
+		*out = new(PrivateNetworkSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.AssignPublicIP != nil {
 		in, out := &in.AssignPublicIP, &out.AssignPublicIP
 		*out = new(bool)
 		**out = **in
 	}
+	if in.SSHIngress != nil {
+		in, out := &in.SSHIngress, &out.SSHIngress
+		*out = new(SecurityGroupRulePeers)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AdditionalIngressRules != nil {
+		in, out := &in.AdditionalIngressRules, &out.AdditionalIngressRules
+		*out = make([]SecurityGroupRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EgressRules != nil {
+		in, out := &in.EgressRules, &out.EgressRules
+		*out = make([]SecurityGroupRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkSpec.
@@ -293,3 +764,146 @@ func (in *NetworkSpec) DeepCopy() *NetworkSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkSpec) DeepCopyInto(out *PrivateNetworkSpec) {
+	*out = *in
+	if in.VPCEndpoints != nil {
+		in, out := &in.VPCEndpoints, &out.VPCEndpoints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkSpec.
+func (in *PrivateNetworkSpec) DeepCopy() *PrivateNetworkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecurityGroupReference) DeepCopyInto(out *SecurityGroupReference) {
+	*out = *in
+	if in.ID != nil {
+		in, out := &in.ID, &out.ID
+		*out = new(string)
+		**out = **in
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecurityGroupReference.
+func (in *SecurityGroupReference) DeepCopy() *SecurityGroupReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SecurityGroupReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecurityGroupRule) DeepCopyInto(out *SecurityGroupRule) {
+	*out = *in
+	if in.ToPort != nil {
+		in, out := &in.ToPort, &out.ToPort
+		*out = new(int64)
+		**out = **in
+	}
+	in.SecurityGroupRulePeers.DeepCopyInto(&out.SecurityGroupRulePeers)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecurityGroupRule.
+func (in *SecurityGroupRule) DeepCopy() *SecurityGroupRule {
+	if in == nil {
+		return nil
+	}
+	out := new(SecurityGroupRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecurityGroupRulePeers) DeepCopyInto(out *SecurityGroupRulePeers) {
+	*out = *in
+	if in.CIDRBlocks != nil {
+		in, out := &in.CIDRBlocks, &out.CIDRBlocks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPv6CIDRBlocks != nil {
+		in, out := &in.IPv6CIDRBlocks, &out.IPv6CIDRBlocks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PrefixListIDs != nil {
+		in, out := &in.PrefixListIDs, &out.PrefixListIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurityGroupIDs != nil {
+		in, out := &in.SecurityGroupIDs, &out.SecurityGroupIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecurityGroupRulePeers.
+func (in *SecurityGroupRulePeers) DeepCopy() *SecurityGroupRulePeers {
+	if in == nil {
+		return nil
+	}
+	out := new(SecurityGroupRulePeers)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceEndpoint) DeepCopyInto(out *ServiceEndpoint) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceEndpoint.
+func (in *ServiceEndpoint) DeepCopy() *ServiceEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpotOptions) DeepCopyInto(out *SpotOptions) {
+	*out = *in
+	if in.MaxPrice != nil {
+		in, out := &in.MaxPrice, &out.MaxPrice
+		*out = new(string)
+		**out = **in
+	}
+	if in.FallbackInstanceTypes != nil {
+		in, out := &in.FallbackInstanceTypes, &out.FallbackInstanceTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpotOptions.
+func (in *SpotOptions) DeepCopy() *SpotOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(SpotOptions)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,362 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
+	"github.com/pkg/errors"
+)
+
+// ResolveAMI returns the ID of the newest AMI matching the selector.
+// An SSM parameter takes precedence over the image filters.
+func (s *AWSClient) ResolveAMI(ctx context.Context, selector infrav1.AMISelector) (string, error) {
+	if selector.SSMParameter != "" {
+		return s.resolveAMIFromSSMParameter(ctx, selector.SSMParameter)
+	}
+
+	if selector.Name == "" {
+		return "", errors.New("AMI selector requires either an SSM parameter or a name")
+	}
+	if len(selector.Owners) == 0 {
+		return "", errors.New("AMI selector requires at least one owner when selecting by name")
+	}
+
+	filters := []*ec2.Filter{
+		{Name: aws.String("name"), Values: aws.StringSlice([]string{selector.Name})},
+		{Name: aws.String("state"), Values: aws.StringSlice([]string{ec2.ImageStateAvailable})},
+	}
+	if selector.Architecture != "" {
+		filters = append(filters, &ec2.Filter{Name: aws.String("architecture"), Values: aws.StringSlice([]string{selector.Architecture})})
+	}
+
+	output, err := s.EC2.DescribeImagesWithContext(ctx, &ec2.DescribeImagesInput{
+		Owners:  aws.StringSlice(selector.Owners),
+		Filters: filters,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to describe AMIs matching selector")
+	}
+
+	if len(output.Images) == 0 {
+		return "", errors.Errorf("no AMI found matching name %q for owners %v", selector.Name, selector.Owners)
+	}
+
+	return aws.StringValue(newestImage(output.Images).ImageId), nil
+}
+
+func (s *AWSClient) resolveAMIFromSSMParameter(ctx context.Context, name string) (string, error) {
+	output, err := s.SSM.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+		Name: aws.String(name),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get SSM parameter %s", name)
+	}
+
+	amiID := aws.StringValue(output.Parameter.Value)
+	if amiID == "" {
+		return "", errors.Errorf("SSM parameter %s has no value", name)
+	}
+
+	return amiID, nil
+}
+
+// ListManagedAMIs returns the available AMIs of the account managed by Forge having all the tags in the region,
+// the region of the client when empty.
+func (s *AWSClient) ListManagedAMIs(ctx context.Context, region string, tags map[string]string) ([]*ec2.Image, error) {
+	client, err := s.regionalEC2(region)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := []*ec2.Filter{
+		{Name: aws.String("tag:forge-managed"), Values: aws.StringSlice([]string{"true"})},
+		{Name: aws.String("state"), Values: aws.StringSlice([]string{ec2.ImageStateAvailable})},
+	}
+	for _, key := range slices.Sorted(maps.Keys(tags)) {
+		filters = append(filters, &ec2.Filter{Name: aws.String("tag:" + key), Values: aws.StringSlice([]string{tags[key]})})
+	}
+
+	output, err := client.DescribeImagesWithContext(ctx, &ec2.DescribeImagesInput{
+		Owners:  aws.StringSlice([]string{"self"}),
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe managed AMIs in region %s", aws.StringValue(client.Config.Region))
+	}
+	return output.Images, nil
+}
+
+// DeleteAMI deregisters the AMI of the region, the region of the client when empty, and deletes the EBS snapshots
+// backing it. AMIs and snapshots that are already gone are ignored.
+func (s *AWSClient) DeleteAMI(ctx context.Context, region string, image *ec2.Image) error {
+	client, err := s.regionalEC2(region)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.DeregisterImageWithContext(ctx, &ec2.DeregisterImageInput{
+		ImageId: image.ImageId,
+	})
+	if awserrors.IgnoreNotFound(err) != nil {
+		return errors.Wrapf(err, "failed to deregister AMI %s", aws.StringValue(image.ImageId))
+	}
+
+	for _, device := range image.BlockDeviceMappings {
+		if device.Ebs == nil || device.Ebs.SnapshotId == nil {
+			continue
+		}
+		_, err := client.DeleteSnapshotWithContext(ctx, &ec2.DeleteSnapshotInput{
+			SnapshotId: device.Ebs.SnapshotId,
+		})
+		if awserrors.IgnoreNotFound(err) != nil {
+			return errors.Wrapf(err, "failed to delete snapshot %s of AMI %s", aws.StringValue(device.Ebs.SnapshotId), aws.StringValue(image.ImageId))
+		}
+	}
+	return nil
+}
+
+// CopyAMI copies the AMI to the region and returns the ID of the copy.
+// Retrying a copy returns the same copy rather than creating another one.
+func (s *AWSClient) CopyAMI(ctx context.Context, params CopyAMIParams) (string, error) {
+	input := &ec2.CopyImageInput{
+		SourceImageId: aws.String(params.SourceImageID),
+		SourceRegion:  s.EC2.Config.Region,
+		Name:          aws.String(params.Name),
+		Description:   aws.String(fmt.Sprintf("Copy of AMI %s from %s", params.SourceImageID, aws.StringValue(s.EC2.Config.Region))),
+		ClientToken:   aws.String(fmt.Sprintf("%s-%s", params.SourceImageID, params.Region)),
+	}
+	if params.KMSKeyID != "" {
+		input.Encrypted = aws.Bool(true)
+		input.KmsKeyId = aws.String(params.KMSKeyID)
+	}
+	if len(params.Tags) > 0 {
+		input.TagSpecifications = imageTagSpecifications(params.Tags)
+	}
+
+	client, err := s.regionalEC2(params.Region)
+	if err != nil {
+		return "", err
+	}
+	output, err := client.CopyImageWithContext(ctx, input)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to copy AMI %s to region %s", params.SourceImageID, params.Region)
+	}
+	return aws.StringValue(output.ImageId), nil
+}
+
+// FindRegionalAMI returns the AMI with the ID in the region, or nil if there is none.
+func (s *AWSClient) FindRegionalAMI(ctx context.Context, region, imageID string) (*ec2.Image, error) {
+	client, err := s.regionalEC2(region)
+	if err != nil {
+		return nil, err
+	}
+	output, err := client.DescribeImagesWithContext(ctx, &ec2.DescribeImagesInput{
+		ImageIds: aws.StringSlice([]string{imageID}),
+	})
+	if awserrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe AMI %s in region %s", imageID, region)
+	}
+	if len(output.Images) == 0 {
+		return nil, nil
+	}
+	return output.Images[0], nil
+}
+
+// ShareAMI grants the launch permissions on the AMI of the region, the region of the client when empty.
+// The accounts of the permissions are also allowed to create volumes from the encrypted snapshots of the AMI.
+func (s *AWSClient) ShareAMI(ctx context.Context, region, imageID string, permissions infrav1.LaunchPermissions) error {
+	client, err := s.regionalEC2(region)
+	if err != nil {
+		return err
+	}
+
+	var launchPermissions []*ec2.LaunchPermission
+	for _, accountID := range permissions.AccountIDs {
+		launchPermissions = append(launchPermissions, &ec2.LaunchPermission{UserId: aws.String(accountID)})
+	}
+	for _, arn := range permissions.OrganizationARNs {
+		launchPermissions = append(launchPermissions, &ec2.LaunchPermission{OrganizationArn: aws.String(arn)})
+	}
+	for _, arn := range permissions.OrganizationalUnitARNs {
+		launchPermissions = append(launchPermissions, &ec2.LaunchPermission{OrganizationalUnitArn: aws.String(arn)})
+	}
+	if permissions.Public {
+		launchPermissions = append(launchPermissions, &ec2.LaunchPermission{Group: aws.String(ec2.PermissionGroupAll)})
+	}
+	if len(launchPermissions) == 0 {
+		return nil
+	}
+
+	_, err = client.ModifyImageAttributeWithContext(ctx, &ec2.ModifyImageAttributeInput{
+		ImageId:          aws.String(imageID),
+		LaunchPermission: &ec2.LaunchPermissionModifications{Add: launchPermissions},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to grant launch permissions on AMI %s", imageID)
+	}
+
+	if len(permissions.AccountIDs) == 0 {
+		return nil
+	}
+	snapshots, err := imageSnapshots(ctx, client, imageID)
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		if !aws.BoolValue(snapshot.Encrypted) {
+			continue
+		}
+		var volumePermissions []*ec2.CreateVolumePermission
+		for _, accountID := range permissions.AccountIDs {
+			volumePermissions = append(volumePermissions, &ec2.CreateVolumePermission{UserId: aws.String(accountID)})
+		}
+		_, err := client.ModifySnapshotAttributeWithContext(ctx, &ec2.ModifySnapshotAttributeInput{
+			SnapshotId:             snapshot.SnapshotId,
+			Attribute:              aws.String(ec2.SnapshotAttributeNameCreateVolumePermission),
+			CreateVolumePermission: &ec2.CreateVolumePermissionModifications{Add: volumePermissions},
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to grant create volume permissions on snapshot %s of AMI %s", aws.StringValue(snapshot.SnapshotId), imageID)
+		}
+	}
+	return nil
+}
+
+// UnshareAMI revokes all the launch permissions on the AMI of the region, the region of the client when empty,
+// and the create volume permissions on its snapshots. AMIs and snapshots that are already gone are ignored.
+func (s *AWSClient) UnshareAMI(ctx context.Context, region string, image *ec2.Image) error {
+	client, err := s.regionalEC2(region)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.ResetImageAttributeWithContext(ctx, &ec2.ResetImageAttributeInput{
+		ImageId:   image.ImageId,
+		Attribute: aws.String(ec2.ResetImageAttributeNameLaunchPermission),
+	})
+	if awserrors.IgnoreNotFound(err) != nil {
+		return errors.Wrapf(err, "failed to revoke launch permissions on AMI %s", aws.StringValue(image.ImageId))
+	}
+
+	for _, device := range image.BlockDeviceMappings {
+		if device.Ebs == nil || device.Ebs.SnapshotId == nil {
+			continue
+		}
+		_, err := client.ResetSnapshotAttributeWithContext(ctx, &ec2.ResetSnapshotAttributeInput{
+			SnapshotId: device.Ebs.SnapshotId,
+			Attribute:  aws.String(ec2.SnapshotAttributeNameCreateVolumePermission),
+		})
+		if awserrors.IgnoreNotFound(err) != nil {
+			return errors.Wrapf(err, "failed to revoke create volume permissions on snapshot %s", aws.StringValue(device.Ebs.SnapshotId))
+		}
+	}
+	return nil
+}
+
+// imageSnapshots returns the EBS snapshots backing the AMI.
+func imageSnapshots(ctx context.Context, client *ec2.EC2, imageID string) ([]*ec2.Snapshot, error) {
+	output, err := client.DescribeImagesWithContext(ctx, &ec2.DescribeImagesInput{
+		ImageIds: aws.StringSlice([]string{imageID}),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe AMI %s", imageID)
+	}
+
+	var snapshotIDs []*string
+	for _, image := range output.Images {
+		for _, device := range image.BlockDeviceMappings {
+			if device.Ebs != nil && device.Ebs.SnapshotId != nil {
+				snapshotIDs = append(snapshotIDs, device.Ebs.SnapshotId)
+			}
+		}
+	}
+	if len(snapshotIDs) == 0 {
+		return nil, nil
+	}
+
+	snapshots, err := client.DescribeSnapshotsWithContext(ctx, &ec2.DescribeSnapshotsInput{
+		SnapshotIds: snapshotIDs,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe snapshots of AMI %s", imageID)
+	}
+	return snapshots.Snapshots, nil
+}
+
+// regionalEC2 returns an EC2 client of the region with the credentials, the endpoints, the proxy and the CA bundle
+// of the client. The EC2 endpoint of the endpoints spec is a URL of the region of the client, the calls in other regions
+// fail with a terminal error rather than reaching the default endpoints of their region.
+func (s *AWSClient) regionalEC2(region string) (*ec2.EC2, error) {
+	if region == "" || region == s.Region() {
+		return s.EC2, nil
+	}
+	if s.regionEC2Endpoint != "" {
+		return nil, awserrors.NewTerminalError(infrav1.RegionalEndpointUnavailableReason,
+			errors.Errorf("EC2 endpoint %s serves region %s, it can't reach region %s", s.regionEC2Endpoint, s.Region(), region))
+	}
+
+	client := ec2.New(s.session, aws.NewConfig().WithRegion(region))
+	installCallTimeout(client.Client, DefaultAPICallTimeout)
+	if s.limit != nil {
+		s.limit(client.Client, region)
+	}
+	return client, nil
+}
+
+// imageTagSpecifications tags an AMI and the snapshots of its volumes with the tags.
+func imageTagSpecifications(tags map[string]string) []*ec2.TagSpecification {
+	ec2Tags := make([]*ec2.Tag, 0, len(tags))
+	for _, key := range slices.Sorted(maps.Keys(tags)) {
+		ec2Tags = append(ec2Tags, &ec2.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	return []*ec2.TagSpecification{
+		{ResourceType: aws.String(ec2.ResourceTypeImage), Tags: ec2Tags},
+		{ResourceType: aws.String(ec2.ResourceTypeSnapshot), Tags: ec2Tags},
+	}
+}
+
+// newestImage returns the image with the latest creation date.
+func newestImage(images []*ec2.Image) *ec2.Image {
+	sorted := make([]*ec2.Image, len(images))
+	copy(sorted, images)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return imageCreationTime(sorted[i]).After(imageCreationTime(sorted[j]))
+	})
+	return sorted[0]
+}
+
+func imageCreationTime(image *ec2.Image) time.Time {
+	creationTime, err := time.Parse(time.RFC3339, aws.StringValue(image.CreationDate))
+	if err != nil {
+		return time.Time{}
+	}
+	return creationTime
+}
@@ -22,46 +22,97 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
+	awsclient "github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
 	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
 	"github.com/pkg/errors"
-
-	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 type AWSClient struct {
 	EC2 *ec2.EC2
+	IAM *iam.IAM
+	SSM *ssm.SSM
+
+	// session creates the EC2 clients of the other regions.
+	session *session.Session
+	// regionEC2Endpoint is the EC2 endpoint of the endpoints spec, it only serves the region of the client.
+	regionEC2Endpoint string
+	// limit installs the rate limiter and the retryer of the session cache on the clients of the region, if any.
+	limit func(c *awsclient.Client, region string)
 }
 
 var _ Interface = &AWSClient{}
 
-// NewAWSServices initializes AWS SDK clients based on the provided region.
-func NewAWSClient(ctx context.Context, region string, credentialsRef *corev1.SecretReference, crClient client.Client) (AWSClient, error) {
+// NewAWSServices initializes AWS SDK clients based on the provided region and credentials.
+// The Secret of the credentials is read each time so that rotated keys are used by the next client.
+func NewAWSClient(ctx context.Context, params ClientParams, crClient client.Client) (AWSClient, error) {
+	var creds *secretCredentials
+	if params.CredentialsRef != nil {
+		secretCreds, err := getAWSCredentialsFromSecret(ctx, params.CredentialsRef, crClient)
+		if err != nil {
+			return AWSClient{}, err
+		}
+		creds = &secretCreds
+	}
 
-	accessKey, secretKey, err := getAWSCredentialsFromSecret(ctx, credentialsRef, crClient)
-	if err != nil {
-		return AWSClient{}, err
+	var bundle *caBundle
+	if params.Endpoints != nil && params.Endpoints.CABundleRef != nil {
+		var err error
+		bundle, err = getCABundleFromSecret(ctx, params.Endpoints.CABundleRef, crClient)
+		if err != nil {
+			return AWSClient{}, err
+		}
 	}
-	sess, err := session.NewSession(&aws.Config{
-		Region:      aws.String(region),
-		Credentials: credentials.NewStaticCredentials(accessKey, secretKey, ""),
-	})
+
+	if params.Sessions != nil {
+		return params.Sessions.client(params, creds, bundle)
+	}
+
+	sess, err := newSession(params, creds, bundle)
 	if err != nil {
 		return AWSClient{}, err
 	}
+	return newAWSClient(sess, params), nil
+}
 
-	return AWSClient{
-		EC2: ec2.New(sess),
-	}, nil
+// newAWSClient returns the AWS SDK clients of the session.
+// Their calls are bounded by DefaultAPICallTimeout and aborted when the context they are made with is canceled.
+func newAWSClient(sess *session.Session, params ClientParams) AWSClient {
+	c := AWSClient{
+		EC2:     ec2.New(sess),
+		IAM:     iam.New(sess),
+		SSM:     ssm.New(sess),
+		session: sess,
+	}
+	// EC2Endpoint serves all the regions, e.g., a local stand-in of the EC2 API, and wins over the endpoints spec
+	if params.EC2Endpoint == "" && params.Endpoints != nil {
+		for _, service := range params.Endpoints.Services {
+			if service.Service == endpoints.Ec2ServiceID {
+				c.regionEC2Endpoint = service.URL
+			}
+		}
+	}
+	installCallTimeout(c.EC2.Client, DefaultAPICallTimeout)
+	installCallTimeout(c.IAM.Client, DefaultAPICallTimeout)
+	installCallTimeout(c.SSM.Client, DefaultAPICallTimeout)
+	return c
 }
 
-func (s *AWSClient) getVPCCIDR(_ context.Context, vpcID string) (string, error) {
+// Region returns the region the client makes its calls in, the region of the credentials Secret overrides the region of the build.
+func (s *AWSClient) Region() string {
+	return aws.StringValue(s.EC2.Config.Region)
+}
+
+func (s *AWSClient) getVPCCIDR(ctx context.Context, vpcID string) (string, error) {
 	// Use DescribeVpcs to fetch details of the VPC by ID
-	output, err := s.EC2.DescribeVpcs(&ec2.DescribeVpcsInput{
+	output, err := s.EC2.DescribeVpcsWithContext(ctx, &ec2.DescribeVpcsInput{
 		VpcIds: []*string{aws.String(vpcID)},
 	})
 	if err != nil {
@@ -81,8 +132,8 @@ func (s *AWSClient) getVPCCIDR(_ context.Context, vpcID string) (string, error)
 	return *vpc.CidrBlock, nil
 }
 
-func (s *AWSClient) FindSubnetByID(_ context.Context, subnetID string) (*ec2.Subnet, error) {
-	output, err := s.EC2.DescribeSubnets(&ec2.DescribeSubnetsInput{
+func (s *AWSClient) FindSubnetByID(ctx context.Context, subnetID string) (*ec2.Subnet, error) {
+	output, err := s.EC2.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
 		SubnetIds: []*string{aws.String(subnetID)},
 	})
 	if err != nil {
@@ -100,7 +151,7 @@ func (s *AWSClient) IsManagedSubnet(ctx context.Context, subnetID string) (bool,
 	log.Info("Checking if subnet is managed by forge", "SubnetID", subnetID)
 
 	// Describe the subnet by ID
-	output, err := s.EC2.DescribeSubnets(&ec2.DescribeSubnetsInput{
+	output, err := s.EC2.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
 		SubnetIds: []*string{aws.String(subnetID)},
 	})
 	if err != nil {
@@ -122,8 +173,9 @@ func (s *AWSClient) IsManagedSubnet(ctx context.Context, subnetID string) (bool,
 	return false, nil
 }
 
-func (s *AWSClient) CreateSubnet(ctx context.Context, vpcName string, vpcID *string) (*ec2.Subnet, error) {
+func (s *AWSClient) CreateSubnet(ctx context.Context, params CreateSubnetParams) (*ec2.Subnet, error) {
 	// Retrieve the VPC CIDR dynamically
+	vpcID := params.VPCID
 	if vpcID == nil {
 		return nil, errors.New("VPC ID is not set in scope")
 	}
@@ -133,7 +185,7 @@ func (s *AWSClient) CreateSubnet(ctx context.Context, vpcName string, vpcID *str
 		return nil, errors.Wrap(err, "failed to retrieve VPC CIDR")
 	}
 	// Retrieve existing subnets in the VPC
-	output, err := s.EC2.DescribeSubnets(&ec2.DescribeSubnetsInput{
+	output, err := s.EC2.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
 		Filters: []*ec2.Filter{
 			{Name: aws.String("vpc-id"), Values: []*string{vpcID}},
 		},
@@ -147,26 +199,56 @@ func (s *AWSClient) CreateSubnet(ctx context.Context, vpcName string, vpcID *str
 	for _, subnet := range output.Subnets {
 		usedCIDRs = append(usedCIDRs, aws.StringValue(subnet.CidrBlock))
 	}
+	usedCIDRs = append(usedCIDRs, params.ReservedCIDRs...)
 
-	// Find an available CIDR
-	subnetMask := 24 // Example: Create /24 subnets
-	cidrBlock, err := findAvailableCIDR(vpcCIDR, usedCIDRs, subnetMask)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to find available CIDR block")
+	cidrBlock := params.CIDRBlock
+	if cidrBlock != "" {
+		// Use the requested CIDR as long as it fits the VPC
+		if err := ValidateSubnetInVPC(vpcCIDR, cidrBlock); err != nil {
+			return nil, err
+		}
+		inUse, err := isCIDRInUse(cidrBlock, usedCIDRs)
+		if err != nil {
+			return nil, err
+		}
+		if inUse {
+			return nil, errors.Errorf("subnet CIDR %s overlaps with an existing subnet in VPC %s", cidrBlock, *vpcID)
+		}
+	} else {
+		// Find an available CIDR
+		prefixLength := params.PrefixLength
+		if prefixLength == 0 {
+			prefixLength = DefaultSubnetPrefixLength
+		}
+		cidrBlock, err = findAvailableCIDR(vpcCIDR, usedCIDRs, prefixLength)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to find available CIDR block")
+		}
+	}
+
+	name := params.Name
+	if name == "" {
+		name = fmt.Sprintf("%s-subnet", params.VPCName)
 	}
 
 	// Create the subnet
 	log := log.FromContext(ctx)
-	log.Info("Creating subnet", "CIDRBlock", cidrBlock)
+	log.Info("Creating subnet", "Name", name, "CIDRBlock", cidrBlock, "AvailabilityZone", params.AvailabilityZone)
+
+	var availabilityZone *string
+	if params.AvailabilityZone != "" {
+		availabilityZone = aws.String(params.AvailabilityZone)
+	}
 
-	createOutput, err := s.EC2.CreateSubnet(&ec2.CreateSubnetInput{
-		VpcId:     vpcID,
-		CidrBlock: aws.String(cidrBlock),
+	createOutput, err := s.EC2.CreateSubnetWithContext(ctx, &ec2.CreateSubnetInput{
+		VpcId:            vpcID,
+		CidrBlock:        aws.String(cidrBlock),
+		AvailabilityZone: availabilityZone,
 		TagSpecifications: []*ec2.TagSpecification{
 			{
 				ResourceType: aws.String(ec2.ResourceTypeSubnet),
 				Tags: []*ec2.Tag{
-					{Key: aws.String("Name"), Value: aws.String(fmt.Sprintf("%s-subnet", vpcName))},
+					{Key: aws.String("Name"), Value: aws.String(name)},
 					{Key: aws.String("forge-managed"), Value: aws.String("true")},
 				},
 			},
@@ -180,7 +262,7 @@ func (s *AWSClient) CreateSubnet(ctx context.Context, vpcName string, vpcID *str
 }
 
 func (s *AWSClient) DeleteSubnet(ctx context.Context, subnetID *string) error {
-	_, err := s.EC2.DeleteSubnet(&ec2.DeleteSubnetInput{
+	_, err := s.EC2.DeleteSubnetWithContext(ctx, &ec2.DeleteSubnetInput{
 		SubnetId: subnetID,
 	})
 	if err != nil {
@@ -190,8 +272,8 @@ func (s *AWSClient) DeleteSubnet(ctx context.Context, subnetID *string) error {
 }
 
 // createSecurityGroup creates a new Security Group in the specified VPC.
-func (s *AWSClient) CreateSecurityGroup(vpcID, sgName *string) (*ec2.CreateSecurityGroupOutput, error) {
-	output, err := s.EC2.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
+func (s *AWSClient) CreateSecurityGroup(ctx context.Context, vpcID, sgName *string) (*ec2.CreateSecurityGroupOutput, error) {
+	output, err := s.EC2.CreateSecurityGroupWithContext(ctx, &ec2.CreateSecurityGroupInput{
 		GroupName:   sgName,
 		Description: aws.String("Security Group managed by Forge"),
 		VpcId:       vpcID,
@@ -211,34 +293,110 @@ func (s *AWSClient) CreateSecurityGroup(vpcID, sgName *string) (*ec2.CreateSecur
 	return output, nil
 }
 
-// authorizeSecurityGroupIngress adds an SSH ingress rule to the specified Security Group.
-func (s *AWSClient) AuthorizeSecurityGroupIngress(sgID string) error {
-	_, err := s.EC2.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
-		GroupId: aws.String(sgID),
-		IpPermissions: []*ec2.IpPermission{
-			{
-				IpProtocol: aws.String("tcp"),
-				FromPort:   aws.Int64(22),
-				ToPort:     aws.Int64(22),
-				IpRanges: []*ec2.IpRange{
-					{CidrIp: aws.String("0.0.0.0/0"), Description: aws.String("Allow SSH from anywhere")},
-				},
-			},
-		},
+// FindSecurityGroupByID returns the Security Group with the given ID.
+func (s *AWSClient) FindSecurityGroupByID(ctx context.Context, sgID string) (*ec2.SecurityGroup, error) {
+	output, err := s.EC2.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{
+		GroupIds: []*string{aws.String(sgID)},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe Security Group")
+	}
+	if len(output.SecurityGroups) == 0 {
+		return nil, errors.Errorf("Security Group %s not found", sgID)
+	}
+	return output.SecurityGroups[0], nil
+}
+
+// FindSecurityGroupsInVPC returns the Security Groups of the VPC that have the given IDs or all of the given tags.
+// It fails if a Security Group given by ID belongs to another VPC.
+func (s *AWSClient) FindSecurityGroupsInVPC(ctx context.Context, vpcID string, sgIDs []string, tags map[string]string) ([]*ec2.SecurityGroup, error) {
+	input := &ec2.DescribeSecurityGroupsInput{}
+	if len(sgIDs) > 0 {
+		input.GroupIds = aws.StringSlice(sgIDs)
+	} else {
+		input.Filters = []*ec2.Filter{
+			{Name: aws.String("vpc-id"), Values: []*string{aws.String(vpcID)}},
+		}
+		for key, value := range tags {
+			input.Filters = append(input.Filters, &ec2.Filter{
+				Name:   aws.String(fmt.Sprintf("tag:%s", key)),
+				Values: []*string{aws.String(value)},
+			})
+		}
+	}
+
+	output, err := s.EC2.DescribeSecurityGroupsWithContext(ctx, input)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe Security Groups")
+	}
+
+	for _, sg := range output.SecurityGroups {
+		if aws.StringValue(sg.VpcId) != vpcID {
+			return nil, errors.Errorf("Security Group %s belongs to VPC %s instead of %s", aws.StringValue(sg.GroupId), aws.StringValue(sg.VpcId), vpcID)
+		}
+	}
+
+	return output.SecurityGroups, nil
+}
+
+// AuthorizeSecurityGroupIngress adds ingress rules to the specified Security Group.
+func (s *AWSClient) AuthorizeSecurityGroupIngress(ctx context.Context, sgID string, permissions []*ec2.IpPermission) error {
+	_, err := s.EC2.AuthorizeSecurityGroupIngressWithContext(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(sgID),
+		IpPermissions: permissions,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to add ingress rules to Security Group")
+	}
+	return nil
+}
+
+// RevokeSecurityGroupIngress removes ingress rules from the specified Security Group.
+func (s *AWSClient) RevokeSecurityGroupIngress(ctx context.Context, sgID string, permissions []*ec2.IpPermission) error {
+	_, err := s.EC2.RevokeSecurityGroupIngressWithContext(ctx, &ec2.RevokeSecurityGroupIngressInput{
+		GroupId:       aws.String(sgID),
+		IpPermissions: permissions,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to remove ingress rules from Security Group")
+	}
+	return nil
+}
+
+// AuthorizeSecurityGroupEgress adds egress rules to the specified Security Group.
+func (s *AWSClient) AuthorizeSecurityGroupEgress(ctx context.Context, sgID string, permissions []*ec2.IpPermission) error {
+	_, err := s.EC2.AuthorizeSecurityGroupEgressWithContext(ctx, &ec2.AuthorizeSecurityGroupEgressInput{
+		GroupId:       aws.String(sgID),
+		IpPermissions: permissions,
 	})
 	if err != nil {
-		return errors.Wrap(err, "failed to add ingress rule to Security Group")
+		return errors.Wrap(err, "failed to add egress rules to Security Group")
+	}
+	return nil
+}
+
+// RevokeSecurityGroupEgress removes egress rules from the specified Security Group.
+func (s *AWSClient) RevokeSecurityGroupEgress(ctx context.Context, sgID string, permissions []*ec2.IpPermission) error {
+	_, err := s.EC2.RevokeSecurityGroupEgressWithContext(ctx, &ec2.RevokeSecurityGroupEgressInput{
+		GroupId:       aws.String(sgID),
+		IpPermissions: permissions,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to remove egress rules from Security Group")
 	}
 	return nil
 }
 
 // isManagedSecurityGroup checks if the Security Group is managed by Forge.
-func (s *AWSClient) IsManagedSecurityGroup(sgID string) (bool, error) {
+func (s *AWSClient) IsManagedSecurityGroup(ctx context.Context, sgID string) (bool, error) {
 	// Describe the Security Group to check its tags
-	output, err := s.EC2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+	output, err := s.EC2.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{
 		GroupIds: []*string{aws.String(sgID)},
 	})
 	if err != nil {
+		if awserrors.IsNotFound(err) {
+			return false, nil
+		}
 		return false, errors.Wrap(err, "failed to describe Security Group")
 	}
 
@@ -254,8 +412,8 @@ func (s *AWSClient) IsManagedSecurityGroup(sgID string) (bool, error) {
 }
 
 // DeleteSecurityGroup delete the Security Group
-func (s *AWSClient) DeleteSecurityGroup(sgID *string) error {
-	_, err := s.EC2.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{
+func (s *AWSClient) DeleteSecurityGroup(ctx context.Context, sgID *string) error {
+	_, err := s.EC2.DeleteSecurityGroupWithContext(ctx, &ec2.DeleteSecurityGroupInput{
 		GroupId: sgID,
 	})
 	if err != nil {
@@ -264,46 +422,9 @@ func (s *AWSClient) DeleteSecurityGroup(sgID *string) error {
 	return nil
 }
 
-func (s *AWSClient) configureRouteTable(vpcID, igwID string) error {
-	// Find the main route table for the VPC
-	output, err := s.EC2.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
-		Filters: []*ec2.Filter{
-			{
-				Name:   aws.String("vpc-id"),
-				Values: []*string{aws.String(vpcID)},
-			},
-			{
-				Name:   aws.String("association.main"),
-				Values: []*string{aws.String("true")},
-			},
-		},
-	})
-	if err != nil {
-		return errors.Wrap(err, "failed to describe route tables")
-	}
-
-	if len(output.RouteTables) == 0 {
-		return errors.New("no main route table found for VPC")
-	}
-
-	routeTableID := aws.StringValue(output.RouteTables[0].RouteTableId)
-
-	// Add a route to the Internet Gateway
-	_, err = s.EC2.CreateRoute(&ec2.CreateRouteInput{
-		RouteTableId:         aws.String(routeTableID),
-		DestinationCidrBlock: aws.String("0.0.0.0/0"),
-		GatewayId:            aws.String(igwID),
-	})
-	if err != nil {
-		return errors.Wrap(err, "failed to add route to Internet Gateway")
-	}
-
-	return nil
-}
-
-func (s *AWSClient) IsManagedVPC(vpcID *string) (bool, error) {
+func (s *AWSClient) IsManagedVPC(ctx context.Context, vpcID *string) (bool, error) {
 	// Describe the VPC to get its tags
-	output, err := s.EC2.DescribeVpcs(&ec2.DescribeVpcsInput{
+	output, err := s.EC2.DescribeVpcsWithContext(ctx, &ec2.DescribeVpcsInput{
 		VpcIds: []*string{vpcID},
 	})
 	if err != nil {
@@ -322,9 +443,9 @@ func (s *AWSClient) IsManagedVPC(vpcID *string) (bool, error) {
 }
 
 // DetachAndDeleteInternetGateway detaches and deletes the Internet Gateway attached to the VPC.
-func (s *AWSClient) DetachAndDeleteInternetGateway(vpcID *string) error {
+func (s *AWSClient) DetachAndDeleteInternetGateway(ctx context.Context, vpcID *string) error {
 	// Describe Internet Gateways attached to the VPC
-	output, err := s.EC2.DescribeInternetGateways(&ec2.DescribeInternetGatewaysInput{
+	output, err := s.EC2.DescribeInternetGatewaysWithContext(ctx, &ec2.DescribeInternetGatewaysInput{
 		Filters: []*ec2.Filter{
 			{
 				Name:   aws.String("attachment.vpc-id"),
@@ -340,7 +461,7 @@ func (s *AWSClient) DetachAndDeleteInternetGateway(vpcID *string) error {
 		igwID := aws.StringValue(igw.InternetGatewayId)
 
 		// Detach the IGW from the VPC
-		_, err := s.EC2.DetachInternetGateway(&ec2.DetachInternetGatewayInput{
+		_, err := s.EC2.DetachInternetGatewayWithContext(ctx, &ec2.DetachInternetGatewayInput{
 			InternetGatewayId: igw.InternetGatewayId,
 			VpcId:             vpcID,
 		})
@@ -349,7 +470,7 @@ func (s *AWSClient) DetachAndDeleteInternetGateway(vpcID *string) error {
 		}
 
 		// Delete the IGW
-		_, err = s.EC2.DeleteInternetGateway(&ec2.DeleteInternetGatewayInput{
+		_, err = s.EC2.DeleteInternetGatewayWithContext(ctx, &ec2.DeleteInternetGatewayInput{
 			InternetGatewayId: igw.InternetGatewayId,
 		})
 		if err != nil {
@@ -360,9 +481,9 @@ func (s *AWSClient) DetachAndDeleteInternetGateway(vpcID *string) error {
 	return nil
 }
 
-func (s *AWSClient) findInternetGateway(vpcID string) (*ec2.InternetGateway, error) {
+func (s *AWSClient) findInternetGateway(ctx context.Context, vpcID string) (*ec2.InternetGateway, error) {
 	// Describe Internet Gateways attached to the VPC
-	output, err := s.EC2.DescribeInternetGateways(&ec2.DescribeInternetGatewaysInput{
+	output, err := s.EC2.DescribeInternetGatewaysWithContext(ctx, &ec2.DescribeInternetGatewaysInput{
 		Filters: []*ec2.Filter{
 			{
 				Name:   aws.String("attachment.vpc-id"),
@@ -381,10 +502,10 @@ func (s *AWSClient) findInternetGateway(vpcID string) (*ec2.InternetGateway, err
 	return nil, nil
 }
 
-func (s *AWSClient) FindVPCByIDOrName(vpcID, vpcName *string) (*ec2.Vpc, error) {
+func (s *AWSClient) FindVPCByIDOrName(ctx context.Context, vpcID, vpcName *string) (*ec2.Vpc, error) {
 	// Check if the VPC exists by ID
 	if vpcID != nil {
-		output, err := s.EC2.DescribeVpcs(&ec2.DescribeVpcsInput{
+		output, err := s.EC2.DescribeVpcsWithContext(ctx, &ec2.DescribeVpcsInput{
 			VpcIds: []*string{vpcID},
 		})
 		if err == nil && len(output.Vpcs) > 0 {
@@ -396,7 +517,7 @@ func (s *AWSClient) FindVPCByIDOrName(vpcID, vpcName *string) (*ec2.Vpc, error)
 
 	// Check if the VPC exists by Name
 	if vpcName != nil {
-		output, err := s.EC2.DescribeVpcs(&ec2.DescribeVpcsInput{
+		output, err := s.EC2.DescribeVpcsWithContext(ctx, &ec2.DescribeVpcsInput{
 			Filters: []*ec2.Filter{
 				{
 					Name:   aws.String("tag:Name"),
@@ -416,8 +537,8 @@ func (s *AWSClient) FindVPCByIDOrName(vpcID, vpcName *string) (*ec2.Vpc, error)
 	return nil, nil
 }
 
-func (s *AWSClient) DeleteVPC(vpcID *string) error {
-	_, err := s.EC2.DeleteVpc(&ec2.DeleteVpcInput{
+func (s *AWSClient) DeleteVPC(ctx context.Context, vpcID *string) error {
+	_, err := s.EC2.DeleteVpcWithContext(ctx, &ec2.DeleteVpcInput{
 		VpcId: vpcID,
 	})
 	if err != nil {
@@ -426,39 +547,34 @@ func (s *AWSClient) DeleteVPC(vpcID *string) error {
 	return nil
 }
 
-func (s *AWSClient) CreateVPC(input *ec2.CreateVpcInput) (*ec2.Vpc, error) {
-	createOutput, err := s.EC2.CreateVpc(input)
+func (s *AWSClient) CreateVPC(ctx context.Context, input *ec2.CreateVpcInput) (*ec2.Vpc, error) {
+	createOutput, err := s.EC2.CreateVpcWithContext(ctx, input)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create VPC")
 	}
 	return createOutput.Vpc, nil
 }
 
-// createOrGetInternetGateway creates an Internet Gateway if it doesn't exist and configures the route table.
+// CreateOrGetInternetGateway creates an Internet Gateway if it doesn't exist.
+// Routes to it are added to the route table created by Forge, the main route table of the VPC is never changed.
 func (s *AWSClient) CreateOrGetInternetGateway(ctx context.Context, vpcID string) (*ec2.InternetGateway, error) {
 	// Check if an Internet Gateway already exists for the VPC
-	igw, err := s.findInternetGateway(vpcID)
+	igw, err := s.findInternetGateway(ctx, vpcID)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to find Internet Gateway")
 	}
 	if igw != nil {
-		err := s.configureRouteTable(vpcID, aws.StringValue(igw.InternetGatewayId))
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to configure route table for Internet Gateway")
-		}
 		return igw, nil
 	}
 
 	// Create a new Internet Gateway
-	createOutput, err := s.EC2.CreateInternetGateway(&ec2.CreateInternetGatewayInput{})
+	createOutput, err := s.EC2.CreateInternetGatewayWithContext(ctx, &ec2.CreateInternetGatewayInput{})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create Internet Gateway")
 	}
 
-	igwID := aws.StringValue(createOutput.InternetGateway.InternetGatewayId)
-
 	// Attach the Internet Gateway to the VPC
-	_, err = s.EC2.AttachInternetGateway(&ec2.AttachInternetGatewayInput{
+	_, err = s.EC2.AttachInternetGatewayWithContext(ctx, &ec2.AttachInternetGatewayInput{
 		InternetGatewayId: createOutput.InternetGateway.InternetGatewayId,
 		VpcId:             aws.String(vpcID),
 	})
@@ -466,17 +582,11 @@ func (s *AWSClient) CreateOrGetInternetGateway(ctx context.Context, vpcID string
 		return nil, errors.Wrap(err, "failed to attach Internet Gateway to VPC")
 	}
 
-	// Configure the Route Table
-	err = s.configureRouteTable(vpcID, igwID)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to configure route table for Internet Gateway")
-	}
-
 	return createOutput.InternetGateway, nil
 }
 
-func (s *AWSClient) IsManagedInstance(instanceID *string) (bool, error) {
-	output, err := s.EC2.DescribeInstances(&ec2.DescribeInstancesInput{
+func (s *AWSClient) IsManagedInstance(ctx context.Context, instanceID *string) (bool, error) {
+	output, err := s.EC2.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{
 		InstanceIds: []*string{instanceID},
 	})
 	if err != nil {
@@ -500,8 +610,8 @@ func (s *AWSClient) IsManagedInstance(instanceID *string) (bool, error) {
 	return false, nil
 }
 
-func (s *AWSClient) FindInstanceByID(instanceID *string) (*ec2.Instance, error) {
-	output, err := s.EC2.DescribeInstances(&ec2.DescribeInstancesInput{
+func (s *AWSClient) FindInstanceByID(ctx context.Context, instanceID *string) (*ec2.Instance, error) {
+	output, err := s.EC2.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{
 		InstanceIds: []*string{instanceID},
 	})
 	if err != nil {
@@ -522,7 +632,7 @@ func (s *AWSClient) FindInstanceByID(instanceID *string) (*ec2.Instance, error)
 	return nil, nil
 }
 
-func (s *AWSClient) CreateInstance(input CreateInstanceParams) (*ec2.Instance, error) {
+func (s *AWSClient) CreateInstance(ctx context.Context, input CreateInstanceParams) (*ec2.Instance, error) {
 	// Check parmars
 	if input.AmiID == "" {
 		return nil, errors.New("AMI ID not provided")
@@ -545,6 +655,7 @@ func (s *AWSClient) CreateInstance(input CreateInstanceParams) (*ec2.Instance, e
 	if input.SecurityGroupID != "" {
 		networkInterface.Groups = aws.StringSlice([]string{input.SecurityGroupID})
 	}
+	networkInterface.Groups = append(networkInterface.Groups, aws.StringSlice(input.AdditionalSecurityGroupIDs)...)
 
 	// Build tags
 	tags := []*ec2.Tag{
@@ -568,9 +679,29 @@ func (s *AWSClient) CreateInstance(input CreateInstanceParams) (*ec2.Instance, e
 		NetworkInterfaces: []*ec2.InstanceNetworkInterfaceSpecification{networkInterface},
 	}
 
-	// Block devices, networking config can be added here as needed.
+	if input.SpotOptions != nil {
+		runInput.InstanceMarketOptions = spotMarketOptions(input.SpotOptions)
+	}
+
+	if input.AvailabilityZone != "" {
+		runInput.Placement = &ec2.Placement{
+			AvailabilityZone: &input.AvailabilityZone,
+		}
+	}
+
+	if input.IAMInstanceProfile != "" {
+		runInput.IamInstanceProfile = &ec2.IamInstanceProfileSpecification{
+			Name: &input.IAMInstanceProfile,
+		}
+	}
+
+	blockDeviceMappings, err := s.buildBlockDeviceMappings(ctx, input)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build block device mappings")
+	}
+	runInput.BlockDeviceMappings = blockDeviceMappings
 
-	runOutput, err := s.EC2.RunInstances(runInput)
+	runOutput, err := s.EC2.RunInstancesWithContext(ctx, runInput)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to run EC2 instance")
 	}
@@ -582,8 +713,8 @@ func (s *AWSClient) CreateInstance(input CreateInstanceParams) (*ec2.Instance, e
 	return runOutput.Instances[0], nil
 }
 
-func (s *AWSClient) TerminateInstance(instanceID *string) error {
-	_, err := s.EC2.TerminateInstances(&ec2.TerminateInstancesInput{
+func (s *AWSClient) TerminateInstance(ctx context.Context, instanceID *string) error {
+	_, err := s.EC2.TerminateInstancesWithContext(ctx, &ec2.TerminateInstancesInput{
 		InstanceIds: []*string{instanceID},
 	})
 	if err != nil {
@@ -592,6 +723,39 @@ func (s *AWSClient) TerminateInstance(instanceID *string) error {
 	return nil
 }
 
+// CancelSpotInstanceRequest cancels a Spot request so that a persistent request does not launch a new instance.
+func (s *AWSClient) CancelSpotInstanceRequest(ctx context.Context, requestID *string) error {
+	_, err := s.EC2.CancelSpotInstanceRequestsWithContext(ctx, &ec2.CancelSpotInstanceRequestsInput{
+		SpotInstanceRequestIds: []*string{requestID},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to cancel Spot instance request")
+	}
+	return nil
+}
+
+// spotMarketOptions converts the Spot options into the market options of RunInstances.
+// Only terminate can be used with one-time requests, other behaviors need a persistent request.
+func spotMarketOptions(options *infrav1.SpotOptions) *ec2.InstanceMarketOptionsRequest {
+	spotOptions := &ec2.SpotMarketOptions{
+		SpotInstanceType: aws.String(ec2.SpotInstanceTypeOneTime),
+	}
+
+	if options.MaxPrice != nil {
+		spotOptions.MaxPrice = options.MaxPrice
+	}
+
+	if options.InterruptionBehavior != "" && options.InterruptionBehavior != ec2.InstanceInterruptionBehaviorTerminate {
+		spotOptions.SpotInstanceType = aws.String(ec2.SpotInstanceTypePersistent)
+		spotOptions.InstanceInterruptionBehavior = aws.String(options.InterruptionBehavior)
+	}
+
+	return &ec2.InstanceMarketOptionsRequest{
+		MarketType:  aws.String(ec2.MarketTypeSpot),
+		SpotOptions: spotOptions,
+	}
+}
+
 // CheckAMIStatus checks if an AMI exists by name and returns its ID and state.
 func (s *AWSClient) CheckAMIStatus(ctx context.Context, imageName string) (string, string, error) {
 	output, err := s.EC2.DescribeImagesWithContext(ctx, &ec2.DescribeImagesInput{
@@ -613,12 +777,16 @@ func (s *AWSClient) CheckAMIStatus(ctx context.Context, imageName string) (strin
 }
 
 // CreateAMI creates a new AMI from the instance's root volume.
-func (s *AWSClient) CreateAMI(ctx context.Context, instanceID, imageName string) error {
+func (s *AWSClient) CreateAMI(ctx context.Context, params CreateAMIParams) error {
 	input := &ec2.CreateImageInput{
-		InstanceId:  aws.String(instanceID),
-		Name:        aws.String(imageName),
+		InstanceId:  aws.String(params.InstanceID),
+		Name:        aws.String(params.Name),
 		NoReboot:    aws.Bool(true), // Avoid rebooting the instance
-		Description: aws.String(fmt.Sprintf("AMI created from instance %s", instanceID)),
+		Description: aws.String(fmt.Sprintf("AMI created from instance %s", params.InstanceID)),
+	}
+
+	if len(params.Tags) > 0 {
+		input.TagSpecifications = imageTagSpecifications(params.Tags)
 	}
 
 	_, err := s.EC2.CreateImageWithContext(ctx, input)
@@ -643,7 +811,7 @@ func (s *AWSClient) ListAMIs(ctx context.Context, imageName string) ([]*ec2.Imag
 	return output.Images, nil
 }
 
-// EnsureAMIDoesNotExist checks if an AMI exists and deletes it if its creation date is older than the Build's creation date.
+// EnsureAMIDoesNotExist checks if an AMI exists and deletes it with its snapshots if its creation date is older than the Build's creation date.
 func (s *AWSClient) EnsureAMIDoesNotExist(ctx context.Context, imageName, creationDate string) error {
 	Images, err := s.ListAMIs(ctx, imageName)
 	if err != nil {
@@ -666,11 +834,8 @@ func (s *AWSClient) EnsureAMIDoesNotExist(ctx context.Context, imageName, creati
 
 		// Compare AMI creation date with Build's creation date
 		if amiCreationDate.Before(buildCreationTime) {
-			_, err := s.EC2.DeregisterImageWithContext(ctx, &ec2.DeregisterImageInput{
-				ImageId: image.ImageId,
-			})
-			if err != nil {
-				return errors.Wrapf(err, "failed to deregister outdated AMI %s", amiID)
+			if err := s.DeleteAMI(ctx, "", image); err != nil {
+				return errors.Wrapf(err, "failed to delete outdated AMI %s", amiID)
 			}
 		}
 	}
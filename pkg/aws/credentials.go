@@ -18,14 +18,106 @@ package aws
 
 import (
 	"context"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func getAWSCredentialsFromSecret(ctx context.Context, credentialsRef *corev1.SecretReference, kubeClient client.Client) (string, string, error) {
+// Keys of the credentials Secret.
+const (
+	accessKeyIDKey     = "aws_access_key_id"
+	secretAccessKeyKey = "aws_secret_access_key"
+	sessionTokenKey    = "aws_session_token"
+	roleARNKey         = "role_arn"
+	regionKey          = "region"
+	credentialsFileKey = "credentials"
+	profileKey         = "profile"
+
+	defaultProfile = "default"
+
+	// assumeRoleExpiryWindow is how long before their expiry the credentials of assumed roles are refreshed.
+	assumeRoleExpiryWindow = 5 * time.Minute
+)
+
+// secretCredentials are the credentials read from a credentials Secret.
+type secretCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	// roleARN is assumed with the keys before the role of the build, if any.
+	roleARN string
+	// region overrides the region of the build.
+	region string
+	// secret and resourceVersion identify the Secret and the version the credentials were read from.
+	secret          types.NamespacedName
+	resourceVersion string
+}
+
+// newSession returns a session with the credentials of the Secret, or with the default credential chain
+// of the controller (environment, web identity token, shared config, instance role) when there are none.
+// The role of the Secret, if any, is assumed before the roles of the params.
+func newSession(params ClientParams, creds *secretCredentials, bundle *caBundle) (*session.Session, error) {
+	config, err := endpointsConfig(params, bundle)
+	if err != nil {
+		return nil, err
+	}
+	config = config.
+		WithRegion(params.Region).
+		WithSTSRegionalEndpoint(endpoints.RegionalSTSEndpoint)
+
+	var roles []infrav1.AssumeRoleSpec
+	if creds != nil {
+		config = config.WithCredentials(credentials.NewStaticCredentials(creds.accessKeyID, creds.secretAccessKey, creds.sessionToken))
+		if creds.region != "" {
+			config = config.WithRegion(creds.region)
+		}
+		if creds.roleARN != "" {
+			roles = append(roles, infrav1.AssumeRoleSpec{RoleARN: creds.roleARN})
+		}
+	}
+	roles = append(roles, params.AssumeRoles...)
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create AWS session")
+	}
+
+	for _, role := range roles {
+		sess = assumeRole(sess, role)
+	}
+	return sess, nil
+}
+
+// assumeRole returns a copy of the session using the credentials of the role, assumed with the credentials of the session.
+func assumeRole(sess *session.Session, role infrav1.AssumeRoleSpec) *session.Session {
+	roleCredentials := stscreds.NewCredentials(sess, role.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = DefaultRoleSessionName
+		if role.SessionName != "" {
+			p.RoleSessionName = role.SessionName
+		}
+		if role.ExternalID != "" {
+			p.ExternalID = aws.String(role.ExternalID)
+		}
+		// Refresh the credentials before they expire rather than failing a call with them
+		p.ExpiryWindow = assumeRoleExpiryWindow
+	})
+
+	return sess.Copy(&aws.Config{Credentials: roleCredentials})
+}
+
+// getAWSCredentialsFromSecret reads the credentials of the Secret.
+// When the Secret has a credentials file, the values of its profile are used and the other keys of the Secret override them.
+func getAWSCredentialsFromSecret(ctx context.Context, credentialsRef *corev1.SecretReference, kubeClient client.Client) (secretCredentials, error) {
 	secretRef := types.NamespacedName{
 		Name:      credentialsRef.Name,
 		Namespace: credentialsRef.Namespace,
@@ -33,18 +125,78 @@ func getAWSCredentialsFromSecret(ctx context.Context, credentialsRef *corev1.Sec
 
 	secret := &corev1.Secret{}
 	if err := kubeClient.Get(ctx, secretRef, secret); err != nil {
-		return "", "", errors.Wrapf(err, "failed to fetch AWS credentials secret %s/%s", secretRef.Namespace, secretRef.Name)
+		return secretCredentials{}, errors.Wrapf(err, "failed to fetch AWS credentials secret %s/%s", secretRef.Namespace, secretRef.Name)
+	}
+
+	values := map[string]string{}
+	if file, ok := secret.Data[credentialsFileKey]; ok {
+		profile := defaultProfile
+		if name, ok := secret.Data[profileKey]; ok {
+			profile = strings.TrimSpace(string(name))
+		}
+
+		var err error
+		values, err = parseCredentialsFile(string(file), profile)
+		if err != nil {
+			return secretCredentials{}, errors.Wrapf(err, "invalid credentials file in secret %s/%s", secretRef.Namespace, secretRef.Name)
+		}
+	}
+	for _, key := range []string{accessKeyIDKey, secretAccessKeyKey, sessionTokenKey, roleARNKey, regionKey} {
+		if value, ok := secret.Data[key]; ok {
+			values[key] = strings.TrimSpace(string(value))
+		}
 	}
 
-	accessKey, ok := secret.Data["aws_access_key_id"]
-	if !ok {
-		return "", "", errors.New("aws_access_key_id key missing in secret")
+	if values[accessKeyIDKey] == "" {
+		return secretCredentials{}, errors.New("aws_access_key_id key missing in secret")
+	}
+	if values[secretAccessKeyKey] == "" {
+		return secretCredentials{}, errors.New("aws_secret_access_key key missing in secret")
 	}
 
-	secretKey, ok := secret.Data["aws_secret_access_key"]
-	if !ok {
-		return "", "", errors.New("aws_secret_access_key key missing in secret")
+	return secretCredentials{
+		accessKeyID:     values[accessKeyIDKey],
+		secretAccessKey: values[secretAccessKeyKey],
+		sessionToken:    values[sessionTokenKey],
+		roleARN:         values[roleARNKey],
+		region:          values[regionKey],
+		secret:          secretRef,
+		resourceVersion: secret.ResourceVersion,
+	}, nil
+}
+
+// parseCredentialsFile returns the values of the profile in an AWS credentials or config file.
+// Profiles of config files are also matched by their "profile <name>" section.
+func parseCredentialsFile(file, profile string) (map[string]string, error) {
+	values := map[string]string{}
+	found := false
+	inProfile := false
+	for _, line := range strings.Split(file, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section := strings.TrimSpace(line[1 : len(line)-1])
+			section = strings.TrimSpace(strings.TrimPrefix(section, "profile "))
+			inProfile = section == profile
+			found = found || inProfile
+			continue
+		}
+		if !inProfile {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, errors.Errorf("invalid line in profile %s", profile)
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
 	}
 
-	return string(accessKey), string(secretKey), nil
+	if !found {
+		return nil, errors.Errorf("profile %s not found", profile)
+	}
+	return values, nil
 }
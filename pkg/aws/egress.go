@@ -0,0 +1,305 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// vpcIDTagKey tags the resources created by Forge with their VPC, Elastic IPs can't be filtered by VPC otherwise.
+const vpcIDTagKey = "forge-vpc-id"
+
+// DefaultVPCEndpointServices are the services reachable from private instances when no endpoint is requested.
+var DefaultVPCEndpointServices = []string{"s3", "ssm", "ssmmessages", "ec2messages"}
+
+// gatewayEndpointServices are the services reached through gateway endpoints instead of interface endpoints.
+var gatewayEndpointServices = map[string]bool{"s3": true, "dynamodb": true}
+
+// vpcResourceTags returns the tags of a resource created by Forge in the VPC.
+func vpcResourceTags(resourceType, name, vpcID string) []*ec2.TagSpecification {
+	return []*ec2.TagSpecification{
+		{
+			ResourceType: aws.String(resourceType),
+			Tags: []*ec2.Tag{
+				{Key: aws.String("Name"), Value: aws.String(name)},
+				{Key: aws.String("forge-managed"), Value: aws.String("true")},
+				{Key: aws.String(vpcIDTagKey), Value: aws.String(vpcID)},
+			},
+		},
+	}
+}
+
+// FindSubnetByName returns the subnet of the VPC with the given Name tag, or nil if there is none.
+func (s *AWSClient) FindSubnetByName(ctx context.Context, vpcID, name string) (*ec2.Subnet, error) {
+	output, err := s.EC2.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("vpc-id"), Values: []*string{aws.String(vpcID)}},
+			{Name: aws.String("tag:Name"), Values: []*string{aws.String(name)}},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe subnets")
+	}
+	if len(output.Subnets) == 0 {
+		return nil, nil
+	}
+	return output.Subnets[0], nil
+}
+
+// EnableVPCDNSHostnames enables DNS hostnames in the VPC, which private DNS of interface endpoints requires.
+func (s *AWSClient) EnableVPCDNSHostnames(ctx context.Context, vpcID string) error {
+	_, err := s.EC2.ModifyVpcAttributeWithContext(ctx, &ec2.ModifyVpcAttributeInput{
+		VpcId:              aws.String(vpcID),
+		EnableDnsHostnames: &ec2.AttributeBooleanValue{Value: aws.Bool(true)},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to enable DNS hostnames for VPC")
+	}
+	return nil
+}
+
+// CreateOrGetNATGateway returns the NAT gateway of the VPC, creating one with a new Elastic IP in the subnet if needed.
+func (s *AWSClient) CreateOrGetNATGateway(ctx context.Context, params NATGatewayParams) (*ec2.NatGateway, error) {
+	output, err := s.EC2.DescribeNatGatewaysWithContext(ctx, &ec2.DescribeNatGatewaysInput{
+		Filter: []*ec2.Filter{
+			{Name: aws.String("vpc-id"), Values: []*string{aws.String(params.VPCID)}},
+			{Name: aws.String("state"), Values: aws.StringSlice([]string{ec2.NatGatewayStatePending, ec2.NatGatewayStateAvailable})},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe NAT gateways")
+	}
+	if len(output.NatGateways) > 0 {
+		return output.NatGateways[0], nil
+	}
+
+	allocationID, err := s.createOrGetAddress(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	log := log.FromContext(ctx)
+	log.Info("Creating NAT gateway", "SubnetID", params.SubnetID, "AllocationID", allocationID)
+
+	createOutput, err := s.EC2.CreateNatGatewayWithContext(ctx, &ec2.CreateNatGatewayInput{
+		SubnetId:          aws.String(params.SubnetID),
+		AllocationId:      aws.String(allocationID),
+		TagSpecifications: vpcResourceTags(ec2.ResourceTypeNatgateway, params.Name, params.VPCID),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create NAT gateway")
+	}
+
+	return createOutput.NatGateway, nil
+}
+
+// createOrGetAddress returns the allocation ID of the unassociated Elastic IP of the VPC, allocating one if needed.
+// Reusing it avoids leaking addresses when the NAT gateway could not be created.
+func (s *AWSClient) createOrGetAddress(ctx context.Context, params NATGatewayParams) (string, error) {
+	output, err := s.EC2.DescribeAddressesWithContext(ctx, &ec2.DescribeAddressesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String(fmt.Sprintf("tag:%s", vpcIDTagKey)), Values: []*string{aws.String(params.VPCID)}},
+		},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to describe Elastic IPs")
+	}
+	for _, address := range output.Addresses {
+		if address.AssociationId == nil {
+			return aws.StringValue(address.AllocationId), nil
+		}
+	}
+
+	log := log.FromContext(ctx)
+	log.Info("Allocating Elastic IP for NAT gateway", "VPCID", params.VPCID)
+
+	allocateOutput, err := s.EC2.AllocateAddressWithContext(ctx, &ec2.AllocateAddressInput{
+		Domain:            aws.String(ec2.DomainTypeVpc),
+		TagSpecifications: vpcResourceTags(ec2.ResourceTypeElasticIp, params.Name, params.VPCID),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to allocate Elastic IP")
+	}
+
+	return aws.StringValue(allocateOutput.AllocationId), nil
+}
+
+// CreateOrGetVPCEndpoints creates the missing VPC endpoints of the requested services.
+// Gateway endpoints are added to the route tables, interface endpoints are placed in the subnet with private DNS.
+func (s *AWSClient) CreateOrGetVPCEndpoints(ctx context.Context, params VPCEndpointsParams) ([]*ec2.VpcEndpoint, error) {
+	output, err := s.EC2.DescribeVpcEndpointsWithContext(ctx, &ec2.DescribeVpcEndpointsInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("vpc-id"), Values: []*string{aws.String(params.VPCID)}},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe VPC endpoints")
+	}
+
+	existing := map[string]*ec2.VpcEndpoint{}
+	for _, endpoint := range output.VpcEndpoints {
+		switch strings.ToLower(aws.StringValue(endpoint.State)) {
+		case "pending", "pendingacceptance", "available":
+			existing[aws.StringValue(endpoint.ServiceName)] = endpoint
+		}
+	}
+
+	log := log.FromContext(ctx)
+	endpoints := make([]*ec2.VpcEndpoint, 0, len(params.Services))
+	for _, service := range params.Services {
+		serviceName := fmt.Sprintf("com.amazonaws.%s.%s", params.Region, service)
+		if endpoint, ok := existing[serviceName]; ok {
+			endpoints = append(endpoints, endpoint)
+			continue
+		}
+
+		input := &ec2.CreateVpcEndpointInput{
+			VpcId:             aws.String(params.VPCID),
+			ServiceName:       aws.String(serviceName),
+			TagSpecifications: vpcResourceTags(ec2.ResourceTypeVpcEndpoint, fmt.Sprintf("%s-%s", params.Name, service), params.VPCID),
+		}
+		if gatewayEndpointServices[service] {
+			input.VpcEndpointType = aws.String(ec2.VpcEndpointTypeGateway)
+			input.RouteTableIds = aws.StringSlice(params.RouteTableIDs)
+		} else {
+			input.VpcEndpointType = aws.String(ec2.VpcEndpointTypeInterface)
+			input.SubnetIds = []*string{aws.String(params.SubnetID)}
+			input.SecurityGroupIds = []*string{aws.String(params.SecurityGroupID)}
+			input.PrivateDnsEnabled = aws.Bool(true)
+		}
+
+		log.Info("Creating VPC endpoint", "ServiceName", serviceName, "Type", aws.StringValue(input.VpcEndpointType))
+		createOutput, err := s.EC2.CreateVpcEndpointWithContext(ctx, input)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create VPC endpoint for %s", serviceName)
+		}
+		endpoints = append(endpoints, createOutput.VpcEndpoint)
+	}
+
+	return endpoints, nil
+}
+
+// DeleteVPCEndpoints deletes the VPC endpoints created by Forge in the VPC and reports whether they are all gone.
+func (s *AWSClient) DeleteVPCEndpoints(ctx context.Context, vpcID string) (bool, error) {
+	output, err := s.EC2.DescribeVpcEndpointsWithContext(ctx, &ec2.DescribeVpcEndpointsInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("vpc-id"), Values: []*string{aws.String(vpcID)}},
+			{Name: aws.String("tag:forge-managed"), Values: []*string{aws.String("true")}},
+		},
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to describe VPC endpoints")
+	}
+
+	deleted := true
+	var endpointIDs []*string
+	for _, endpoint := range output.VpcEndpoints {
+		switch strings.ToLower(aws.StringValue(endpoint.State)) {
+		case "deleted":
+			continue
+		case "deleting":
+			deleted = false
+		default:
+			deleted = false
+			endpointIDs = append(endpointIDs, endpoint.VpcEndpointId)
+		}
+	}
+
+	if len(endpointIDs) > 0 {
+		deleteOutput, err := s.EC2.DeleteVpcEndpointsWithContext(ctx, &ec2.DeleteVpcEndpointsInput{
+			VpcEndpointIds: endpointIDs,
+		})
+		if err != nil {
+			return false, errors.Wrap(err, "failed to delete VPC endpoints")
+		}
+		if len(deleteOutput.Unsuccessful) > 0 {
+			item := deleteOutput.Unsuccessful[0]
+			return false, errors.Errorf("failed to delete VPC endpoint %s: %s", aws.StringValue(item.ResourceId), aws.StringValue(item.Error.Message))
+		}
+	}
+
+	return deleted, nil
+}
+
+// DeleteNATGateways deletes the NAT gateways created by Forge in the VPC and reports whether they are all gone.
+func (s *AWSClient) DeleteNATGateways(ctx context.Context, vpcID string) (bool, error) {
+	output, err := s.EC2.DescribeNatGatewaysWithContext(ctx, &ec2.DescribeNatGatewaysInput{
+		Filter: []*ec2.Filter{
+			{Name: aws.String("vpc-id"), Values: []*string{aws.String(vpcID)}},
+			{Name: aws.String("tag:forge-managed"), Values: []*string{aws.String("true")}},
+		},
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to describe NAT gateways")
+	}
+
+	deleted := true
+	for _, natGateway := range output.NatGateways {
+		switch aws.StringValue(natGateway.State) {
+		case ec2.NatGatewayStateDeleted, ec2.NatGatewayStateFailed:
+			continue
+		case ec2.NatGatewayStateDeleting:
+			deleted = false
+		default:
+			deleted = false
+			_, err := s.EC2.DeleteNatGatewayWithContext(ctx, &ec2.DeleteNatGatewayInput{
+				NatGatewayId: natGateway.NatGatewayId,
+			})
+			if err != nil {
+				return false, errors.Wrapf(err, "failed to delete NAT gateway %s", aws.StringValue(natGateway.NatGatewayId))
+			}
+		}
+	}
+
+	return deleted, nil
+}
+
+// ReleaseAddresses releases the Elastic IPs allocated for the VPC and reports whether they are all released.
+// Addresses still associated with a deleting NAT gateway are released later.
+func (s *AWSClient) ReleaseAddresses(ctx context.Context, vpcID string) (bool, error) {
+	output, err := s.EC2.DescribeAddressesWithContext(ctx, &ec2.DescribeAddressesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String(fmt.Sprintf("tag:%s", vpcIDTagKey)), Values: []*string{aws.String(vpcID)}},
+		},
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to describe Elastic IPs")
+	}
+
+	released := true
+	for _, address := range output.Addresses {
+		if address.AssociationId != nil {
+			released = false
+			continue
+		}
+		_, err := s.EC2.ReleaseAddressWithContext(ctx, &ec2.ReleaseAddressInput{
+			AllocationId: address.AllocationId,
+		})
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to release Elastic IP %s", aws.StringValue(address.AllocationId))
+		}
+	}
+
+	return released, nil
+}
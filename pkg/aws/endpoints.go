@@ -0,0 +1,146 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// caBundleKey is the key of the CA bundle Secret holding the certificates.
+const caBundleKey = "ca.crt"
+
+// caBundle are the CA certificates read from a CA bundle Secret.
+type caBundle struct {
+	pem []byte
+	// resourceVersion is the version of the Secret the certificates were read from.
+	resourceVersion string
+}
+
+// getCABundleFromSecret reads the CA certificates of the Secret.
+func getCABundleFromSecret(ctx context.Context, ref *corev1.SecretReference, kubeClient client.Client) (*caBundle, error) {
+	secretRef := types.NamespacedName{
+		Name:      ref.Name,
+		Namespace: ref.Namespace,
+	}
+
+	secret := &corev1.Secret{}
+	if err := kubeClient.Get(ctx, secretRef, secret); err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch CA bundle secret %s/%s", secretRef.Namespace, secretRef.Name)
+	}
+	pem, ok := secret.Data[caBundleKey]
+	if !ok || len(pem) == 0 {
+		return nil, errors.Errorf("%s key missing in CA bundle secret %s/%s", caBundleKey, secretRef.Namespace, secretRef.Name)
+	}
+
+	return &caBundle{pem: pem, resourceVersion: secret.ResourceVersion}, nil
+}
+
+// endpointsConfig returns the configuration of the endpoints of the params.
+// The EC2 endpoint of the params takes precedence over the EC2 endpoint of the endpoints spec.
+func endpointsConfig(params ClientParams, bundle *caBundle) (*aws.Config, error) {
+	config := aws.NewConfig()
+	spec := params.Endpoints
+	if spec == nil {
+		spec = &infrav1.EndpointsSpec{}
+	}
+
+	overrides := map[string]string{}
+	for _, service := range spec.Services {
+		overrides[service.Service] = service.URL
+	}
+	if params.EC2Endpoint != "" {
+		overrides[endpoints.Ec2ServiceID] = params.EC2Endpoint
+	}
+	if len(overrides) > 0 {
+		config = config.WithEndpointResolver(overrideResolver(overrides))
+	}
+
+	if spec.UseFIPS {
+		config.UseFIPSEndpoint = endpoints.FIPSEndpointStateEnabled
+	}
+	if spec.UseDualStack {
+		config.UseDualStackEndpoint = endpoints.DualStackEndpointStateEnabled
+	}
+
+	if spec.ProxyURL != "" || bundle != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if spec.ProxyURL != "" {
+			proxyURL, err := url.Parse(spec.ProxyURL)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid proxy URL %s", spec.ProxyURL)
+			}
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+		if bundle != nil {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(bundle.pem) {
+				return nil, errors.New("CA bundle contains no valid PEM certificate")
+			}
+			transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+		}
+		config = config.WithHTTPClient(&http.Client{Transport: transport})
+	}
+
+	return config, nil
+}
+
+// overrideResolver resolves the overridden services to their URL, with the signing region and name of AWS,
+// and the other services with the default resolver.
+func overrideResolver(overrides map[string]string) endpoints.ResolverFunc {
+	return func(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		resolved, err := endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+		overrideURL, ok := overrides[service]
+		if !ok {
+			return resolved, err
+		}
+
+		if err != nil {
+			resolved = endpoints.ResolvedEndpoint{SigningRegion: region, SigningName: service}
+		}
+		resolved.URL = overrideURL
+		resolved.SigningMethod = "v4"
+		return resolved, nil
+	}
+}
+
+// endpointsKey identifies the endpoints configuration of the params.
+func endpointsKey(params ClientParams) string {
+	key := params.EC2Endpoint
+	if spec := params.Endpoints; spec != nil {
+		key += fmt.Sprintf("|%v|%t|%t|%s", spec.Services, spec.UseFIPS, spec.UseDualStack, spec.ProxyURL)
+		if spec.CABundleRef != nil {
+			key += fmt.Sprintf("|%s/%s", spec.CABundleRef.Namespace, spec.CABundleRef.Name)
+		}
+	}
+	return key
+}
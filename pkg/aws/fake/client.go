@@ -0,0 +1,440 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake provides an in-memory implementation of the AWS client of the services.
+// It keeps the VPCs, subnets, Security Groups, gateways, instances, AMIs and instance profiles it creates,
+// enforces the dependencies AWS enforces between them and moves them through their states like AWS does.
+package fake
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+)
+
+const (
+	// DefaultRegion is the region of the clients created by New.
+	DefaultRegion = "us-east-1"
+	// DefaultAccountID is the account owning the resources created by the clients.
+	DefaultAccountID = "123456789012"
+	// DefaultEgressIP is the public IP of the controller detected by the clients created by New.
+	DefaultEgressIP = "203.0.113.10"
+
+	managedTagKey = "forge-managed"
+	vpcIDTagKey   = "forge-vpc-id"
+)
+
+// AWSClient is an in-memory AWS account and region.
+//
+// Resources move to their next state each time they are described: instances go from pending to running and
+// from shutting-down to terminated, AMIs, NAT gateways and VPC endpoints become available or deleted.
+type AWSClient struct {
+	mu sync.Mutex
+
+	// Region is the region of the resources, the zones of the region are <Region>a to <Region>c.
+	Region string
+	// AccountID owns the resources created with the client, AMIs are resolved with the "self" owner against it.
+	AccountID string
+	// Offerings are the zones offering each instance type, instance types missing from it are offered in every zone.
+	Offerings map[string][]string
+	// SSMParameters are the values of the SSM parameters AMIs are resolved from.
+	SSMParameters map[string]string
+	// Errors are returned by the methods named by their keys instead of calling AWS, e.g., to simulate a lack of capacity.
+	Errors map[string]error
+	// Now returns the current time, it defaults to time.Now.
+	Now func() time.Time
+	// EgressIP is the public IP of the controller returned by DetectEgressIP.
+	EgressIP string
+
+	nextID int
+
+	vpcs             map[string]*ec2.Vpc
+	subnets          map[string]*ec2.Subnet
+	internetGateways map[string]*ec2.InternetGateway
+	routeTables      map[string]*ec2.RouteTable
+	natGateways      map[string]*ec2.NatGateway
+	addresses        map[string]*ec2.Address
+	vpcEndpoints     map[string]*ec2.VpcEndpoint
+	securityGroups   map[string]*ec2.SecurityGroup
+	instances        map[string]*ec2.Instance
+	spotRequests     map[string]string
+	images           map[string]*ec2.Image
+	snapshots        map[string]*ec2.Snapshot
+	// launchPermissions and volumePermissions are the permissions granted on AMIs and snapshots by their ID.
+	launchPermissions map[string][]*ec2.LaunchPermission
+	volumePermissions map[string][]*ec2.CreateVolumePermission
+	instanceProfiles  map[string]*iam.InstanceProfile
+	profileTags       map[string][]*iam.Tag
+
+	// regions are the other regions of the account, AMIs are copied to them.
+	regions map[string]*AWSClient
+	// copies are the IDs of the copies of AMIs by the client token of their copy.
+	copies map[string]string
+}
+
+var _ awsforge.Interface = &AWSClient{}
+
+// New returns an empty AWSClient in DefaultRegion.
+func New() *AWSClient {
+	return &AWSClient{
+		Region:            DefaultRegion,
+		AccountID:         DefaultAccountID,
+		Offerings:         map[string][]string{},
+		SSMParameters:     map[string]string{},
+		Errors:            map[string]error{},
+		Now:               time.Now,
+		EgressIP:          DefaultEgressIP,
+		vpcs:              map[string]*ec2.Vpc{},
+		subnets:           map[string]*ec2.Subnet{},
+		internetGateways:  map[string]*ec2.InternetGateway{},
+		routeTables:       map[string]*ec2.RouteTable{},
+		natGateways:       map[string]*ec2.NatGateway{},
+		addresses:         map[string]*ec2.Address{},
+		vpcEndpoints:      map[string]*ec2.VpcEndpoint{},
+		securityGroups:    map[string]*ec2.SecurityGroup{},
+		instances:         map[string]*ec2.Instance{},
+		spotRequests:      map[string]string{},
+		images:            map[string]*ec2.Image{},
+		snapshots:         map[string]*ec2.Snapshot{},
+		launchPermissions: map[string][]*ec2.LaunchPermission{},
+		volumePermissions: map[string][]*ec2.CreateVolumePermission{},
+		instanceProfiles:  map[string]*iam.InstanceProfile{},
+		profileTags:       map[string][]*iam.Tag{},
+		regions:           map[string]*AWSClient{},
+		copies:            map[string]string{},
+	}
+}
+
+// Regional returns the client of another region of the account, the AMIs copied to the region are kept by it.
+func (c *AWSClient) Regional(region string) *AWSClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.regional(region)
+}
+
+func (c *AWSClient) regional(region string) *AWSClient {
+	if region == "" || region == c.Region {
+		return c
+	}
+	regional, ok := c.regions[region]
+	if !ok {
+		regional = New()
+		regional.Region = region
+		regional.AccountID = c.AccountID
+		regional.Now = c.Now
+		c.regions[region] = regional
+	}
+	return regional
+}
+
+// Zones returns the availability zones of the region.
+func (c *AWSClient) Zones() []string {
+	return []string{c.Region + "a", c.Region + "b", c.Region + "c"}
+}
+
+// VPC returns the VPC with the ID, or nil if there is none.
+func (c *AWSClient) VPC(id string) *ec2.Vpc {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyOf(c.vpcs[id])
+}
+
+// Subnet returns the subnet with the ID, or nil if there is none.
+func (c *AWSClient) Subnet(id string) *ec2.Subnet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyOf(c.subnets[id])
+}
+
+// SecurityGroup returns the Security Group with the ID, or nil if there is none.
+func (c *AWSClient) SecurityGroup(id string) *ec2.SecurityGroup {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyOf(c.securityGroups[id])
+}
+
+// NATGateway returns the NAT gateway with the ID without moving it to its next state, or nil if there is none.
+func (c *AWSClient) NATGateway(id string) *ec2.NatGateway {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyOf(c.natGateways[id])
+}
+
+// VPCEndpoint returns the VPC endpoint with the ID without moving it to its next state, or nil if there is none.
+func (c *AWSClient) VPCEndpoint(id string) *ec2.VpcEndpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyOf(c.vpcEndpoints[id])
+}
+
+// Instance returns the instance with the ID without moving it to its next state, or nil if there is none.
+func (c *AWSClient) Instance(id string) *ec2.Instance {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyOf(c.instances[id])
+}
+
+// Image returns the AMI with the ID without moving it to its next state, or nil if there is none.
+func (c *AWSClient) Image(id string) *ec2.Image {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyOf(c.images[id])
+}
+
+// Snapshot returns the EBS snapshot with the ID, or nil if there is none.
+func (c *AWSClient) Snapshot(id string) *ec2.Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyOf(c.snapshots[id])
+}
+
+// LaunchPermissions returns the launch permissions granted on the AMI.
+func (c *AWSClient) LaunchPermissions(imageID string) []*ec2.LaunchPermission {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	permissions := c.launchPermissions[imageID]
+	return *copyOf(&permissions)
+}
+
+// CreateVolumePermissions returns the create volume permissions granted on the snapshot.
+func (c *AWSClient) CreateVolumePermissions(snapshotID string) []*ec2.CreateVolumePermission {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	permissions := c.volumePermissions[snapshotID]
+	return *copyOf(&permissions)
+}
+
+// InstanceProfile returns the instance profile with the name, or nil if there is none.
+func (c *AWSClient) InstanceProfile(name string) *iam.InstanceProfile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyOf(c.instanceProfiles[name])
+}
+
+// RouteTables returns the route tables of the VPC.
+func (c *AWSClient) RouteTables(vpcID string) []*ec2.RouteTable {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var routeTables []*ec2.RouteTable
+	for _, routeTable := range c.routeTables {
+		if aws.StringValue(routeTable.VpcId) == vpcID {
+			routeTables = append(routeTables, copyOf(routeTable))
+		}
+	}
+	return routeTables
+}
+
+// InternetGateways returns the Internet Gateways attached to the VPC.
+func (c *AWSClient) InternetGateways(vpcID string) []*ec2.InternetGateway {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var internetGateways []*ec2.InternetGateway
+	for _, igw := range c.internetGateways {
+		for _, attachment := range igw.Attachments {
+			if aws.StringValue(attachment.VpcId) == vpcID {
+				internetGateways = append(internetGateways, copyOf(igw))
+				break
+			}
+		}
+	}
+	return internetGateways
+}
+
+// AddImage registers an AMI, e.g., the public image builds are launched from.
+// The AMI is owned by the account when it has no owner and available when it has no state,
+// the snapshots of its block devices are added when they don't exist.
+func (c *AWSClient) AddImage(image *ec2.Image) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	image = copyOf(image)
+	if image.ImageId == nil {
+		image.ImageId = aws.String(c.newID("ami"))
+	}
+	if image.OwnerId == nil {
+		image.OwnerId = aws.String(c.AccountID)
+	}
+	if image.State == nil {
+		image.State = aws.String(ec2.ImageStateAvailable)
+	}
+	if image.CreationDate == nil {
+		image.CreationDate = aws.String(c.Now().UTC().Format(time.RFC3339))
+	}
+	if image.RootDeviceName == nil {
+		image.RootDeviceName = aws.String("/dev/xvda")
+	}
+	for _, device := range image.BlockDeviceMappings {
+		if device.Ebs == nil || device.Ebs.SnapshotId == nil {
+			continue
+		}
+		if _, ok := c.snapshots[*device.Ebs.SnapshotId]; !ok {
+			c.snapshots[*device.Ebs.SnapshotId] = &ec2.Snapshot{
+				SnapshotId: device.Ebs.SnapshotId,
+				OwnerId:    image.OwnerId,
+				State:      aws.String(ec2.SnapshotStateCompleted),
+				Encrypted:  device.Ebs.Encrypted,
+			}
+		}
+	}
+	c.images[aws.StringValue(image.ImageId)] = image
+	return aws.StringValue(image.ImageId)
+}
+
+// AddSubnet creates a subnet of the VPC that is not managed by Forge, e.g., the subnet of the user builds are launched in.
+// The subnet is in the first zone of the region when the zone is empty.
+func (c *AWSClient) AddSubnet(vpcID, cidrBlock, zone string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	subnet, err := c.createSubnet(vpcID, cidrBlock, zone, nil)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(subnet.SubnetId), nil
+}
+
+// injected returns the error configured for the method.
+// AddNATGateway creates an available NAT gateway in the subnet that is not managed by Forge, with a new Elastic IP.
+func (c *AWSClient) AddNATGateway(subnetID string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	address := c.allocateAddress(nil)
+	natGateway, err := c.createNATGateway(subnetID, aws.StringValue(address.AllocationId), nil)
+	if err != nil {
+		return "", err
+	}
+	natGateway.State = aws.String(ec2.NatGatewayStateAvailable)
+	return aws.StringValue(natGateway.NatGatewayId), nil
+}
+
+// AddVPCEndpoint creates an available gateway endpoint of the service in the VPC that is not managed by Forge.
+func (c *AWSClient) AddVPCEndpoint(vpcID, serviceName string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	endpoint, err := c.createVPCEndpoint(&ec2.CreateVpcEndpointInput{
+		VpcId:       aws.String(vpcID),
+		ServiceName: aws.String(serviceName),
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	endpoint.State = aws.String("available")
+	return aws.StringValue(endpoint.VpcEndpointId), nil
+}
+
+func (c *AWSClient) injected(method string) error {
+	return c.Errors[method]
+}
+
+// newID returns a new resource ID with the prefix, e.g., vpc-0000000000000001.
+func (c *AWSClient) newID(prefix string) string {
+	c.nextID++
+	return fmt.Sprintf("%s-%017x", prefix, c.nextID)
+}
+
+// advance moves the resources in a transitional state to their next state.
+func (c *AWSClient) advance() {
+	for _, instance := range c.instances {
+		switch aws.StringValue(instance.State.Name) {
+		case ec2.InstanceStateNamePending:
+			instance.State = instanceState(ec2.InstanceStateNameRunning)
+		case ec2.InstanceStateNameShuttingDown:
+			instance.State = instanceState(ec2.InstanceStateNameTerminated)
+			if id := aws.StringValue(instance.SpotInstanceRequestId); id != "" && c.spotRequests[id] == ec2.SpotInstanceStateActive {
+				c.spotRequests[id] = ec2.SpotInstanceStateClosed
+			}
+		}
+	}
+	for _, image := range c.images {
+		if aws.StringValue(image.State) == ec2.ImageStatePending {
+			image.State = aws.String(ec2.ImageStateAvailable)
+		}
+	}
+	for _, natGateway := range c.natGateways {
+		switch aws.StringValue(natGateway.State) {
+		case ec2.NatGatewayStatePending:
+			natGateway.State = aws.String(ec2.NatGatewayStateAvailable)
+		case ec2.NatGatewayStateDeleting:
+			natGateway.State = aws.String(ec2.NatGatewayStateDeleted)
+			for _, address := range c.addresses {
+				if aws.StringValue(address.NetworkInterfaceOwnerId) == aws.StringValue(natGateway.NatGatewayId) {
+					address.AssociationId = nil
+					address.NetworkInterfaceOwnerId = nil
+				}
+			}
+		}
+	}
+	for _, endpoint := range c.vpcEndpoints {
+		switch aws.StringValue(endpoint.State) {
+		case "pending":
+			endpoint.State = aws.String("available")
+		case "deleting":
+			endpoint.State = aws.String("deleted")
+		}
+	}
+}
+
+// notFound returns the error of AWS for a missing resource.
+func notFound(code, id string) error {
+	return awserr.New(code, fmt.Sprintf("The ID '%s' does not exist", id), nil)
+}
+
+func awserrNew(code, message string) error {
+	return awserr.New(code, message, nil)
+}
+
+// dependencyViolation returns the error of AWS for a resource that is still used.
+func dependencyViolation(id, usedBy string) error {
+	return awserr.New("DependencyViolation", fmt.Sprintf("resource %s has a dependent object %s", id, usedBy), nil)
+}
+
+// tags returns the tags of the tag specifications of the resource type.
+func tags(specs []*ec2.TagSpecification, resourceType string) []*ec2.Tag {
+	var tags []*ec2.Tag
+	for _, spec := range specs {
+		if aws.StringValue(spec.ResourceType) == resourceType {
+			tags = append(tags, copyOf(spec).Tags...)
+		}
+	}
+	return tags
+}
+
+// hasTag checks if the tags have the key with the value.
+func hasTag(tags []*ec2.Tag, key, value string) bool {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key && aws.StringValue(tag.Value) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// copyOf returns a deep copy of the resource so that callers can't change the state of the client.
+func copyOf[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	return awsutil.CopyOf(v).(*T)
+}
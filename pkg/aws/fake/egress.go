@@ -0,0 +1,300 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+	"github.com/pkg/errors"
+)
+
+// EnableVPCDNSHostnames enables DNS hostnames in the VPC.
+func (c *AWSClient) EnableVPCDNSHostnames(_ context.Context, vpcID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("EnableVPCDNSHostnames"); err != nil {
+		return errors.Wrap(err, "failed to enable DNS hostnames for VPC")
+	}
+
+	if _, ok := c.vpcs[vpcID]; !ok {
+		return errors.Wrap(notFound("InvalidVpcID.NotFound", vpcID), "failed to enable DNS hostnames for VPC")
+	}
+	return nil
+}
+
+// CreateOrGetNATGateway returns the pending or available NAT gateway of the VPC, creating one with an Elastic IP if needed.
+func (c *AWSClient) CreateOrGetNATGateway(_ context.Context, params awsforge.NATGatewayParams) (*ec2.NatGateway, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("CreateOrGetNATGateway"); err != nil {
+		return nil, errors.Wrap(err, "failed to create NAT gateway")
+	}
+
+	c.advance()
+	for _, id := range sortedKeys(c.natGateways) {
+		natGateway := c.natGateways[id]
+		if aws.StringValue(natGateway.VpcId) != params.VPCID {
+			continue
+		}
+		switch aws.StringValue(natGateway.State) {
+		case ec2.NatGatewayStatePending, ec2.NatGatewayStateAvailable:
+			return copyOf(natGateway), nil
+		}
+	}
+
+	if _, ok := c.subnets[params.SubnetID]; !ok {
+		return nil, errors.Wrap(notFound("InvalidSubnetID.NotFound", params.SubnetID), "failed to create NAT gateway")
+	}
+	address := c.unassociatedAddress(params.VPCID)
+	if address == nil {
+		address = c.allocateAddress(vpcTags(params.Name, params.VPCID))
+	}
+	natGateway, err := c.createNATGateway(params.SubnetID, aws.StringValue(address.AllocationId), vpcTags(params.Name, params.VPCID))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create NAT gateway")
+	}
+	return copyOf(natGateway), nil
+}
+
+// allocateAddress allocates an Elastic IP in the VPC domain.
+func (c *AWSClient) allocateAddress(tags []*ec2.Tag) *ec2.Address {
+	address := &ec2.Address{
+		AllocationId: aws.String(c.newID("eipalloc")),
+		PublicIp:     aws.String(fmt.Sprintf("198.51.100.%d", len(c.addresses)+1)),
+		Domain:       aws.String(ec2.DomainTypeVpc),
+		Tags:         tags,
+	}
+	c.addresses[*address.AllocationId] = address
+	return address
+}
+
+// createNATGateway creates a pending NAT gateway in the subnet and associates the Elastic IP with it.
+func (c *AWSClient) createNATGateway(subnetID, allocationID string, tags []*ec2.Tag) (*ec2.NatGateway, error) {
+	subnet, ok := c.subnets[subnetID]
+	if !ok {
+		return nil, notFound("InvalidSubnetID.NotFound", subnetID)
+	}
+	address, ok := c.addresses[allocationID]
+	if !ok {
+		return nil, notFound("InvalidAllocationID.NotFound", allocationID)
+	}
+	if address.AssociationId != nil {
+		return nil, awserrNew("Resource.AlreadyAssociated", fmt.Sprintf("Elastic IP address [%s] is already associated", allocationID))
+	}
+
+	natGateway := &ec2.NatGateway{
+		NatGatewayId: aws.String(c.newID("nat")),
+		VpcId:        subnet.VpcId,
+		SubnetId:     subnet.SubnetId,
+		State:        aws.String(ec2.NatGatewayStatePending),
+		CreateTime:   aws.Time(c.Now()),
+		NatGatewayAddresses: []*ec2.NatGatewayAddress{
+			{AllocationId: address.AllocationId, PublicIp: address.PublicIp},
+		},
+		Tags: tags,
+	}
+	c.natGateways[*natGateway.NatGatewayId] = natGateway
+	address.AssociationId = aws.String(c.newID("eipassoc"))
+	address.NetworkInterfaceOwnerId = natGateway.NatGatewayId
+	return natGateway, nil
+}
+
+func (c *AWSClient) unassociatedAddress(vpcID string) *ec2.Address {
+	for _, id := range sortedKeys(c.addresses) {
+		address := c.addresses[id]
+		if hasTag(address.Tags, vpcIDTagKey, vpcID) && address.AssociationId == nil {
+			return address
+		}
+	}
+	return nil
+}
+
+// CreateOrGetVPCEndpoints creates the missing VPC endpoints of the services.
+func (c *AWSClient) CreateOrGetVPCEndpoints(_ context.Context, params awsforge.VPCEndpointsParams) ([]*ec2.VpcEndpoint, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("CreateOrGetVPCEndpoints"); err != nil {
+		return nil, errors.Wrap(err, "failed to describe VPC endpoints")
+	}
+
+	c.advance()
+	existing := map[string]*ec2.VpcEndpoint{}
+	for _, endpoint := range c.vpcEndpoints {
+		if aws.StringValue(endpoint.VpcId) != params.VPCID {
+			continue
+		}
+		switch aws.StringValue(endpoint.State) {
+		case "pending", "available":
+			existing[aws.StringValue(endpoint.ServiceName)] = endpoint
+		}
+	}
+
+	endpoints := make([]*ec2.VpcEndpoint, 0, len(params.Services))
+	for _, service := range params.Services {
+		serviceName := fmt.Sprintf("com.amazonaws.%s.%s", params.Region, service)
+		if endpoint, ok := existing[serviceName]; ok {
+			endpoints = append(endpoints, copyOf(endpoint))
+			continue
+		}
+
+		input := &ec2.CreateVpcEndpointInput{
+			VpcId:       aws.String(params.VPCID),
+			ServiceName: aws.String(serviceName),
+		}
+		if service == "s3" || service == "dynamodb" {
+			input.VpcEndpointType = aws.String(ec2.VpcEndpointTypeGateway)
+			input.RouteTableIds = aws.StringSlice(params.RouteTableIDs)
+		} else {
+			input.VpcEndpointType = aws.String(ec2.VpcEndpointTypeInterface)
+			input.SubnetIds = []*string{aws.String(params.SubnetID)}
+			input.SecurityGroupIds = []*string{aws.String(params.SecurityGroupID)}
+			input.PrivateDnsEnabled = aws.Bool(true)
+		}
+		endpoint, err := c.createVPCEndpoint(input, vpcTags(fmt.Sprintf("%s-%s", params.Name, service), params.VPCID))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create VPC endpoint for %s", serviceName)
+		}
+		endpoints = append(endpoints, copyOf(endpoint))
+	}
+
+	return endpoints, nil
+}
+
+// createVPCEndpoint creates a pending gateway endpoint in the route tables, or a pending interface endpoint in the subnets.
+func (c *AWSClient) createVPCEndpoint(input *ec2.CreateVpcEndpointInput, tags []*ec2.Tag) (*ec2.VpcEndpoint, error) {
+	vpcID := aws.StringValue(input.VpcId)
+	if _, ok := c.vpcs[vpcID]; !ok {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+
+	endpoint := &ec2.VpcEndpoint{
+		VpcEndpointId:   aws.String(c.newID("vpce")),
+		VpcId:           aws.String(vpcID),
+		ServiceName:     input.ServiceName,
+		VpcEndpointType: input.VpcEndpointType,
+		State:           aws.String("pending"),
+		Tags:            tags,
+	}
+	if endpoint.VpcEndpointType == nil {
+		endpoint.VpcEndpointType = aws.String(ec2.VpcEndpointTypeGateway)
+	}
+
+	if aws.StringValue(endpoint.VpcEndpointType) == ec2.VpcEndpointTypeGateway {
+		for _, id := range aws.StringValueSlice(input.RouteTableIds) {
+			if _, ok := c.routeTables[id]; !ok {
+				return nil, notFound("InvalidRouteTableId.NotFound", id)
+			}
+		}
+		endpoint.RouteTableIds = copyOf(input).RouteTableIds
+	} else {
+		for _, id := range aws.StringValueSlice(input.SubnetIds) {
+			if _, ok := c.subnets[id]; !ok {
+				return nil, notFound("InvalidSubnetID.NotFound", id)
+			}
+			endpoint.SubnetIds = append(endpoint.SubnetIds, aws.String(id))
+		}
+		for _, id := range aws.StringValueSlice(input.SecurityGroupIds) {
+			if _, ok := c.securityGroups[id]; !ok {
+				return nil, notFound("InvalidGroup.NotFound", id)
+			}
+			endpoint.Groups = append(endpoint.Groups, &ec2.SecurityGroupIdentifier{GroupId: aws.String(id)})
+		}
+		endpoint.PrivateDnsEnabled = aws.Bool(aws.BoolValue(input.PrivateDnsEnabled))
+	}
+	c.vpcEndpoints[*endpoint.VpcEndpointId] = endpoint
+	return endpoint, nil
+}
+
+// DeleteVPCEndpoints deletes the VPC endpoints created by Forge in the VPC and reports whether they are all gone.
+func (c *AWSClient) DeleteVPCEndpoints(_ context.Context, vpcID string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("DeleteVPCEndpoints"); err != nil {
+		return false, errors.Wrap(err, "failed to delete VPC endpoints")
+	}
+
+	c.advance()
+	deleted := true
+	for _, endpoint := range c.vpcEndpoints {
+		if aws.StringValue(endpoint.VpcId) != vpcID || !hasTag(endpoint.Tags, managedTagKey, "true") || aws.StringValue(endpoint.State) == "deleted" {
+			continue
+		}
+		deleted = false
+		endpoint.State = aws.String("deleting")
+	}
+	return deleted, nil
+}
+
+// DeleteNATGateways deletes the NAT gateways created by Forge in the VPC and reports whether they are all gone.
+func (c *AWSClient) DeleteNATGateways(_ context.Context, vpcID string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("DeleteNATGateways"); err != nil {
+		return false, errors.Wrap(err, "failed to describe NAT gateways")
+	}
+
+	c.advance()
+	deleted := true
+	for _, natGateway := range c.natGateways {
+		if aws.StringValue(natGateway.VpcId) != vpcID || !hasTag(natGateway.Tags, managedTagKey, "true") {
+			continue
+		}
+		switch aws.StringValue(natGateway.State) {
+		case ec2.NatGatewayStateDeleted, ec2.NatGatewayStateFailed:
+			continue
+		}
+		deleted = false
+		natGateway.State = aws.String(ec2.NatGatewayStateDeleting)
+	}
+	return deleted, nil
+}
+
+// ReleaseAddresses releases the Elastic IPs of the VPC and reports whether they are all released.
+func (c *AWSClient) ReleaseAddresses(_ context.Context, vpcID string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("ReleaseAddresses"); err != nil {
+		return false, errors.Wrap(err, "failed to describe Elastic IPs")
+	}
+
+	c.advance()
+	released := true
+	for id, address := range c.addresses {
+		if !hasTag(address.Tags, vpcIDTagKey, vpcID) {
+			continue
+		}
+		if address.AssociationId != nil {
+			released = false
+			continue
+		}
+		delete(c.addresses, id)
+	}
+	return released, nil
+}
+
+// vpcTags returns the tags of a resource created by Forge in the VPC.
+func vpcTags(name, vpcID string) []*ec2.Tag {
+	return []*ec2.Tag{
+		{Key: aws.String("Name"), Value: aws.String(name)},
+		{Key: aws.String(managedTagKey), Value: aws.String("true")},
+		{Key: aws.String(vpcIDTagKey), Value: aws.String(vpcID)},
+	}
+}
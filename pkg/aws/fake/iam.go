@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+	"github.com/pkg/errors"
+)
+
+// FindInstanceProfileForRole returns the first instance profile containing the role, given by name or ARN, or nil.
+func (c *AWSClient) FindInstanceProfileForRole(_ context.Context, role string) (*iam.InstanceProfile, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("FindInstanceProfileForRole"); err != nil {
+		return nil, errors.Wrapf(err, "failed to list instance profiles for role %s", role)
+	}
+
+	roleName := awsforge.RoleNameFromARN(role)
+	for _, name := range sortedKeys(c.instanceProfiles) {
+		profile := c.instanceProfiles[name]
+		for _, r := range profile.Roles {
+			if aws.StringValue(r.RoleName) == roleName {
+				return copyOf(profile), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// CreateInstanceProfileForRole creates the forge-managed instance profile, if needed, and adds the role to it.
+// Like in AWS, an instance profile holds a single role.
+func (c *AWSClient) CreateInstanceProfileForRole(_ context.Context, profileName, role string) (*iam.InstanceProfile, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("CreateInstanceProfileForRole"); err != nil {
+		return nil, errors.Wrapf(err, "failed to create instance profile %s", profileName)
+	}
+
+	roleName := awsforge.RoleNameFromARN(role)
+	profile, ok := c.instanceProfiles[profileName]
+	if !ok {
+		id := c.newID("AIPA")
+		profile = &iam.InstanceProfile{
+			InstanceProfileName: aws.String(profileName),
+			InstanceProfileId:   aws.String(id),
+			Arn:                 aws.String(fmt.Sprintf("arn:aws:iam::%s:instance-profile/%s", c.AccountID, profileName)),
+			Path:                aws.String("/"),
+		}
+		c.instanceProfiles[profileName] = profile
+		c.profileTags[profileName] = []*iam.Tag{
+			{Key: aws.String("Name"), Value: aws.String(profileName)},
+			{Key: aws.String(managedTagKey), Value: aws.String("true")},
+		}
+	}
+
+	for _, r := range profile.Roles {
+		if aws.StringValue(r.RoleName) == roleName {
+			return copyOf(profile), nil
+		}
+	}
+	if len(profile.Roles) > 0 {
+		return nil, errors.Wrapf(awserrNew(iam.ErrCodeLimitExceededException, "Cannot exceed quota for InstanceSessionsPerInstanceProfile: 1"),
+			"failed to add role %s to instance profile %s", roleName, profileName)
+	}
+
+	profile.Roles = append(profile.Roles, &iam.Role{
+		RoleName: aws.String(roleName),
+		Arn:      aws.String(fmt.Sprintf("arn:aws:iam::%s:role/%s", c.AccountID, roleName)),
+	})
+	return copyOf(profile), nil
+}
+
+// IsManagedInstanceProfile checks if the instance profile is tagged as managed by forge, missing profiles are not managed.
+func (c *AWSClient) IsManagedInstanceProfile(_ context.Context, profileName string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("IsManagedInstanceProfile"); err != nil {
+		return false, errors.Wrap(err, "failed to list instance profile tags")
+	}
+
+	for _, tag := range c.profileTags[profileName] {
+		if aws.StringValue(tag.Key) == managedTagKey && aws.StringValue(tag.Value) == "true" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DeleteInstanceProfile removes the roles of the instance profile and deletes it, missing profiles are ignored.
+func (c *AWSClient) DeleteInstanceProfile(_ context.Context, profileName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("DeleteInstanceProfile"); err != nil {
+		return errors.Wrapf(err, "failed to delete instance profile %s", profileName)
+	}
+
+	delete(c.instanceProfiles, profileName)
+	delete(c.profileTags, profileName)
+	return nil
+}
@@ -0,0 +1,504 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
+	"github.com/pkg/errors"
+)
+
+// ResolveAMI returns the newest available AMI matching the selector, or the value of its SSM parameter.
+// Names are matched with the * and ? wildcards of AWS filters.
+func (c *AWSClient) ResolveAMI(_ context.Context, selector infrav1.AMISelector) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("ResolveAMI"); err != nil {
+		return "", errors.Wrap(err, "failed to describe AMIs matching selector")
+	}
+
+	if selector.SSMParameter != "" {
+		value, ok := c.SSMParameters[selector.SSMParameter]
+		if !ok {
+			return "", errors.Wrapf(awserrNew("ParameterNotFound", ""), "failed to get SSM parameter %s", selector.SSMParameter)
+		}
+		if value == "" {
+			return "", errors.Errorf("SSM parameter %s has no value", selector.SSMParameter)
+		}
+		return value, nil
+	}
+
+	if selector.Name == "" {
+		return "", errors.New("AMI selector requires either an SSM parameter or a name")
+	}
+	if len(selector.Owners) == 0 {
+		return "", errors.New("AMI selector requires at least one owner when selecting by name")
+	}
+
+	c.advance()
+	var newest *ec2.Image
+	for _, image := range c.images {
+		if aws.StringValue(image.State) != ec2.ImageStateAvailable || !c.ownedBy(image, selector.Owners) {
+			continue
+		}
+		if matched, _ := path.Match(selector.Name, aws.StringValue(image.Name)); !matched {
+			continue
+		}
+		if selector.Architecture != "" && aws.StringValue(image.Architecture) != selector.Architecture {
+			continue
+		}
+		if newest == nil || aws.StringValue(image.CreationDate) > aws.StringValue(newest.CreationDate) {
+			newest = image
+		}
+	}
+
+	if newest == nil {
+		return "", errors.Errorf("no AMI found matching name %q for owners %v", selector.Name, selector.Owners)
+	}
+	return aws.StringValue(newest.ImageId), nil
+}
+
+// CreateAMI creates a pending AMI of the instance, it becomes available when it is next described.
+// The AMI and the snapshots of its volumes are tagged with the tags of the params.
+func (c *AWSClient) CreateAMI(_ context.Context, params awsforge.CreateAMIParams) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("CreateAMI"); err != nil {
+		return errors.Wrap(err, "failed to create AMI")
+	}
+
+	var tags []*ec2.Tag
+	for _, key := range sortedKeys(params.Tags) {
+		tags = append(tags, &ec2.Tag{Key: aws.String(key), Value: aws.String(params.Tags[key])})
+	}
+	description := fmt.Sprintf("AMI created from instance %s", params.InstanceID)
+	if _, err := c.createImage(params.InstanceID, params.Name, description, tags, tags); err != nil {
+		return errors.Wrap(err, "failed to create AMI")
+	}
+	return nil
+}
+
+// createImage creates a pending AMI of the instance with a snapshot of each of its volumes.
+func (c *AWSClient) createImage(instanceID, imageName, description string, imageTags, snapshotTags []*ec2.Tag) (*ec2.Image, error) {
+	instance, ok := c.instances[instanceID]
+	if !ok || aws.StringValue(instance.State.Name) == ec2.InstanceStateNameTerminated {
+		return nil, notFound("InvalidInstanceID.NotFound", instanceID)
+	}
+	for _, image := range c.ownImages(imageName) {
+		if aws.StringValue(image.State) != ec2.ImageStateDeregistered {
+			return nil, awserrNew("InvalidAMIName.Duplicate", fmt.Sprintf("AMI name %s is already in use by AMI %s", imageName, aws.StringValue(image.ImageId)))
+		}
+	}
+
+	image := &ec2.Image{
+		ImageId:        aws.String(c.newID("ami")),
+		Name:           aws.String(imageName),
+		Description:    aws.String(description),
+		OwnerId:        aws.String(c.AccountID),
+		State:          aws.String(ec2.ImageStatePending),
+		CreationDate:   aws.String(c.Now().UTC().Format(time.RFC3339)),
+		RootDeviceName: instance.RootDeviceName,
+		RootDeviceType: aws.String(ec2.DeviceTypeEbs),
+		Tags:           *copyOf(&imageTags),
+	}
+	for _, device := range instance.BlockDeviceMappings {
+		snapshot := &ec2.Snapshot{
+			SnapshotId:  aws.String(c.newID("snap")),
+			VolumeId:    device.Ebs.VolumeId,
+			OwnerId:     aws.String(c.AccountID),
+			State:       aws.String(ec2.SnapshotStateCompleted),
+			StartTime:   aws.Time(c.Now().UTC()),
+			Description: aws.String(fmt.Sprintf("Created by CreateImage(%s) for %s", instanceID, aws.StringValue(image.ImageId))),
+			Tags:        *copyOf(&snapshotTags),
+		}
+		c.snapshots[*snapshot.SnapshotId] = snapshot
+		image.BlockDeviceMappings = append(image.BlockDeviceMappings, &ec2.BlockDeviceMapping{
+			DeviceName: device.DeviceName,
+			Ebs:        &ec2.EbsBlockDevice{SnapshotId: snapshot.SnapshotId},
+		})
+	}
+	c.images[*image.ImageId] = image
+	return image, nil
+}
+
+// EnsureAMIDoesNotExist deregisters the AMIs with the name created before the creation date.
+func (c *AWSClient) EnsureAMIDoesNotExist(_ context.Context, imageName, creationDate string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("EnsureAMIDoesNotExist"); err != nil {
+		return errors.Wrap(err, "failed to describe AMIs")
+	}
+
+	buildCreationTime, err := time.Parse(time.RFC3339, creationDate)
+	if err != nil {
+		return err
+	}
+	for _, image := range c.ownImages(imageName) {
+		imageCreationTime, err := time.Parse(time.RFC3339, aws.StringValue(image.CreationDate))
+		if err != nil {
+			continue
+		}
+		if imageCreationTime.Before(buildCreationTime) {
+			if err := c.deleteImage(image); err != nil {
+				return errors.Wrapf(err, "failed to delete outdated AMI %s", aws.StringValue(image.ImageId))
+			}
+		}
+	}
+	return nil
+}
+
+// ListAMIs returns the AMIs of the account with the name.
+func (c *AWSClient) ListAMIs(_ context.Context, imageName string) ([]*ec2.Image, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("ListAMIs"); err != nil {
+		return nil, errors.Wrap(err, "failed to describe AMIs")
+	}
+
+	c.advance()
+	var images []*ec2.Image
+	for _, image := range c.ownImages(imageName) {
+		images = append(images, copyOf(image))
+	}
+	return images, nil
+}
+
+// CheckAMIStatus returns the ID and the state of the AMI of the account with the name, or empty strings if there is none.
+func (c *AWSClient) CheckAMIStatus(_ context.Context, imageName string) (string, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("CheckAMIStatus"); err != nil {
+		return "", "", errors.Wrap(err, "failed to describe AMI status")
+	}
+
+	c.advance()
+	images := c.ownImages(imageName)
+	if len(images) == 0 {
+		return "", "", nil
+	}
+	return aws.StringValue(images[0].ImageId), aws.StringValue(images[0].State), nil
+}
+
+// ListManagedAMIs returns the available AMIs of the account managed by Forge having all the tags in the region,
+// the region of the client when empty.
+func (c *AWSClient) ListManagedAMIs(_ context.Context, region string, tags map[string]string) ([]*ec2.Image, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("ListManagedAMIs"); err != nil {
+		return nil, errors.Wrap(err, "failed to describe managed AMIs")
+	}
+
+	regional := c.regional(region)
+	if regional != c {
+		regional.mu.Lock()
+		defer regional.mu.Unlock()
+	}
+	regional.advance()
+	var images []*ec2.Image
+	for _, id := range sortedKeys(regional.images) {
+		image := regional.images[id]
+		if aws.StringValue(image.OwnerId) != c.AccountID || aws.StringValue(image.State) != ec2.ImageStateAvailable {
+			continue
+		}
+		if !hasTag(image.Tags, managedTagKey, "true") {
+			continue
+		}
+		matched := true
+		for key, value := range tags {
+			matched = matched && hasTag(image.Tags, key, value)
+		}
+		if matched {
+			images = append(images, copyOf(image))
+		}
+	}
+	return images, nil
+}
+
+// DeleteAMI deregisters the AMI of the region, the region of the client when empty, and deletes the snapshots
+// backing it. AMIs and snapshots that are already gone are ignored.
+func (c *AWSClient) DeleteAMI(_ context.Context, region string, image *ec2.Image) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("DeleteAMI"); err != nil {
+		return errors.Wrapf(err, "failed to deregister AMI %s", aws.StringValue(image.ImageId))
+	}
+
+	regional := c.regional(region)
+	if regional != c {
+		regional.mu.Lock()
+		defer regional.mu.Unlock()
+	}
+	return regional.deleteImage(image)
+}
+
+// deleteImage deregisters the AMI and deletes the snapshots of its block devices.
+func (c *AWSClient) deleteImage(image *ec2.Image) error {
+	delete(c.images, aws.StringValue(image.ImageId))
+	delete(c.launchPermissions, aws.StringValue(image.ImageId))
+	for _, device := range image.BlockDeviceMappings {
+		if device.Ebs == nil || device.Ebs.SnapshotId == nil {
+			continue
+		}
+		err := c.deleteSnapshot(aws.StringValue(device.Ebs.SnapshotId))
+		if awserrors.IgnoreNotFound(err) != nil {
+			return errors.Wrapf(err, "failed to delete snapshot %s of AMI %s", aws.StringValue(device.Ebs.SnapshotId), aws.StringValue(image.ImageId))
+		}
+	}
+	return nil
+}
+
+// deleteSnapshot deletes the snapshot, it fails like AWS while a registered AMI uses it.
+func (c *AWSClient) deleteSnapshot(id string) error {
+	if _, ok := c.snapshots[id]; !ok {
+		return notFound("InvalidSnapshot.NotFound", id)
+	}
+	for _, imageID := range sortedKeys(c.images) {
+		for _, device := range c.images[imageID].BlockDeviceMappings {
+			if device.Ebs != nil && aws.StringValue(device.Ebs.SnapshotId) == id {
+				return awserrNew("InvalidSnapshot.InUse", fmt.Sprintf("The snapshot %s is currently in use by %s", id, imageID))
+			}
+		}
+	}
+	delete(c.snapshots, id)
+	return nil
+}
+
+// CopyAMI copies the available AMI to the region as a pending AMI, it becomes available when it is next described.
+// Copying the AMI to the same region again returns the same copy, like AWS does with the client token of the copy.
+func (c *AWSClient) CopyAMI(_ context.Context, params awsforge.CopyAMIParams) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("CopyAMI"); err != nil {
+		return "", errors.Wrapf(err, "failed to copy AMI %s to region %s", params.SourceImageID, params.Region)
+	}
+
+	token := fmt.Sprintf("%s-%s", params.SourceImageID, params.Region)
+	if copyID, ok := c.copies[token]; ok {
+		return copyID, nil
+	}
+	source, ok := c.images[params.SourceImageID]
+	if !ok || aws.StringValue(source.State) != ec2.ImageStateAvailable {
+		return "", errors.Wrapf(notFound("InvalidAMIID.NotFound", params.SourceImageID), "failed to copy AMI %s to region %s", params.SourceImageID, params.Region)
+	}
+
+	regional := c.regional(params.Region)
+	if regional != c {
+		regional.mu.Lock()
+		defer regional.mu.Unlock()
+	}
+	image := copyOf(source)
+	image.ImageId = aws.String(regional.newID("ami"))
+	image.Name = aws.String(params.Name)
+	image.State = aws.String(ec2.ImageStatePending)
+	image.CreationDate = aws.String(regional.Now().UTC().Format(time.RFC3339))
+	image.Tags = nil
+	for _, key := range sortedKeys(params.Tags) {
+		image.Tags = append(image.Tags, &ec2.Tag{Key: aws.String(key), Value: aws.String(params.Tags[key])})
+	}
+	for _, device := range image.BlockDeviceMappings {
+		if device.Ebs == nil || device.Ebs.SnapshotId == nil {
+			continue
+		}
+		snapshot := copyOf(c.snapshots[aws.StringValue(device.Ebs.SnapshotId)])
+		if snapshot == nil {
+			continue
+		}
+		snapshot.SnapshotId = aws.String(regional.newID("snap"))
+		snapshot.Tags = *copyOf(&image.Tags)
+		if params.KMSKeyID != "" {
+			snapshot.Encrypted = aws.Bool(true)
+			snapshot.KmsKeyId = aws.String(params.KMSKeyID)
+		}
+		regional.snapshots[*snapshot.SnapshotId] = snapshot
+		device.Ebs.SnapshotId = snapshot.SnapshotId
+	}
+	regional.images[*image.ImageId] = image
+	c.copies[token] = *image.ImageId
+	return *image.ImageId, nil
+}
+
+// FindRegionalAMI returns the AMI with the ID in the region, or nil if there is none.
+func (c *AWSClient) FindRegionalAMI(_ context.Context, region, imageID string) (*ec2.Image, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("FindRegionalAMI"); err != nil {
+		return nil, errors.Wrapf(err, "failed to describe AMI %s in region %s", imageID, region)
+	}
+
+	regional := c.regional(region)
+	if regional != c {
+		regional.mu.Lock()
+		defer regional.mu.Unlock()
+	}
+	regional.advance()
+	return copyOf(regional.images[imageID]), nil
+}
+
+// ShareAMI grants the launch permissions on the AMI of the region, the region of the client when empty.
+// The accounts of the permissions are also allowed to create volumes from the encrypted snapshots of the AMI.
+func (c *AWSClient) ShareAMI(_ context.Context, region, imageID string, permissions infrav1.LaunchPermissions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("ShareAMI"); err != nil {
+		return errors.Wrapf(err, "failed to grant launch permissions on AMI %s", imageID)
+	}
+
+	regional := c
+	if region != "" {
+		regional = c.regional(region)
+	}
+	if regional != c {
+		regional.mu.Lock()
+		defer regional.mu.Unlock()
+	}
+
+	var launchPermissions []*ec2.LaunchPermission
+	for _, accountID := range permissions.AccountIDs {
+		launchPermissions = append(launchPermissions, &ec2.LaunchPermission{UserId: aws.String(accountID)})
+	}
+	for _, arn := range permissions.OrganizationARNs {
+		launchPermissions = append(launchPermissions, &ec2.LaunchPermission{OrganizationArn: aws.String(arn)})
+	}
+	for _, arn := range permissions.OrganizationalUnitARNs {
+		launchPermissions = append(launchPermissions, &ec2.LaunchPermission{OrganizationalUnitArn: aws.String(arn)})
+	}
+	if permissions.Public {
+		launchPermissions = append(launchPermissions, &ec2.LaunchPermission{Group: aws.String(ec2.PermissionGroupAll)})
+	}
+	if len(launchPermissions) == 0 {
+		return nil
+	}
+	if err := regional.addLaunchPermissions(imageID, launchPermissions); err != nil {
+		return errors.Wrapf(err, "failed to grant launch permissions on AMI %s", imageID)
+	}
+
+	var volumePermissions []*ec2.CreateVolumePermission
+	for _, accountID := range permissions.AccountIDs {
+		volumePermissions = append(volumePermissions, &ec2.CreateVolumePermission{UserId: aws.String(accountID)})
+	}
+	for _, device := range regional.images[imageID].BlockDeviceMappings {
+		if device.Ebs == nil || device.Ebs.SnapshotId == nil {
+			continue
+		}
+		snapshot, ok := regional.snapshots[aws.StringValue(device.Ebs.SnapshotId)]
+		if !ok || !aws.BoolValue(snapshot.Encrypted) || len(volumePermissions) == 0 {
+			continue
+		}
+		if err := regional.addVolumePermissions(*snapshot.SnapshotId, volumePermissions); err != nil {
+			return errors.Wrapf(err, "failed to grant create volume permissions on snapshot %s of AMI %s", *snapshot.SnapshotId, imageID)
+		}
+	}
+	return nil
+}
+
+// UnshareAMI revokes all the launch permissions on the AMI of the region, the region of the client when empty,
+// and the create volume permissions on its snapshots. AMIs and snapshots that are already gone are ignored.
+func (c *AWSClient) UnshareAMI(_ context.Context, region string, image *ec2.Image) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("UnshareAMI"); err != nil {
+		return errors.Wrapf(err, "failed to revoke launch permissions on AMI %s", aws.StringValue(image.ImageId))
+	}
+
+	regional := c.regional(region)
+	if regional != c {
+		regional.mu.Lock()
+		defer regional.mu.Unlock()
+	}
+	delete(regional.launchPermissions, aws.StringValue(image.ImageId))
+	for _, device := range image.BlockDeviceMappings {
+		if device.Ebs != nil && device.Ebs.SnapshotId != nil {
+			delete(regional.volumePermissions, *device.Ebs.SnapshotId)
+		}
+	}
+	return nil
+}
+
+// addLaunchPermissions grants the launch permissions on the AMI, permissions already granted are kept once.
+func (c *AWSClient) addLaunchPermissions(imageID string, permissions []*ec2.LaunchPermission) error {
+	if _, ok := c.images[imageID]; !ok {
+		return notFound("InvalidAMIID.NotFound", imageID)
+	}
+	for _, permission := range permissions {
+		if !slices.ContainsFunc(c.launchPermissions[imageID], func(granted *ec2.LaunchPermission) bool {
+			return granted.String() == permission.String()
+		}) {
+			c.launchPermissions[imageID] = append(c.launchPermissions[imageID], copyOf(permission))
+		}
+	}
+	return nil
+}
+
+// addVolumePermissions grants the create volume permissions on the snapshot, permissions already granted are kept once.
+func (c *AWSClient) addVolumePermissions(snapshotID string, permissions []*ec2.CreateVolumePermission) error {
+	if _, ok := c.snapshots[snapshotID]; !ok {
+		return notFound("InvalidSnapshot.NotFound", snapshotID)
+	}
+	for _, permission := range permissions {
+		if !slices.ContainsFunc(c.volumePermissions[snapshotID], func(granted *ec2.CreateVolumePermission) bool {
+			return granted.String() == permission.String()
+		}) {
+			c.volumePermissions[snapshotID] = append(c.volumePermissions[snapshotID], copyOf(permission))
+		}
+	}
+	return nil
+}
+
+// FailImage moves the AMI to the failed state with the message, like AWS does when a copy fails.
+func (c *AWSClient) FailImage(imageID, message string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	image, ok := c.images[imageID]
+	if !ok {
+		return errors.Errorf("AMI %s not found", imageID)
+	}
+	image.State = aws.String(ec2.ImageStateFailed)
+	image.StateReason = &ec2.StateReason{Code: aws.String("Client.CopyImageFailed"), Message: aws.String(message)}
+	return nil
+}
+
+// ownImages returns the AMIs of the account with the name, in creation order.
+func (c *AWSClient) ownImages(imageName string) []*ec2.Image {
+	var images []*ec2.Image
+	for _, id := range sortedKeys(c.images) {
+		image := c.images[id]
+		if aws.StringValue(image.OwnerId) == c.AccountID && aws.StringValue(image.Name) == imageName {
+			images = append(images, image)
+		}
+	}
+	return images
+}
+
+// ownedBy checks if the AMI is owned by one of the owners, given by account ID, alias or "self".
+func (c *AWSClient) ownedBy(image *ec2.Image, owners []string) bool {
+	return slices.ContainsFunc(owners, func(owner string) bool {
+		if owner == "self" {
+			owner = c.AccountID
+		}
+		return owner == aws.StringValue(image.OwnerId) || owner == aws.StringValue(image.ImageOwnerAlias)
+	})
+}
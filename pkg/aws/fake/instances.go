@@ -0,0 +1,267 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"slices"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+	"github.com/pkg/errors"
+)
+
+// CreateInstance launches a pending instance in the subnet.
+// It fails like AWS when the AMI, the subnet, the Security Groups or the instance profile are missing,
+// and when the instance type is not offered in the zone of the subnet.
+func (c *AWSClient) CreateInstance(_ context.Context, input awsforge.CreateInstanceParams) (*ec2.Instance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("CreateInstance"); err != nil {
+		return nil, errors.Wrap(err, "failed to run EC2 instance")
+	}
+
+	if input.AmiID == "" {
+		return nil, errors.New("AMI ID not provided")
+	}
+	if input.InstanceType == "" {
+		return nil, errors.New("Instance type not provided")
+	}
+
+	instance, err := c.runInstance(input, []*ec2.Tag{
+		{Key: aws.String("Name"), Value: aws.String(input.Name)},
+		{Key: aws.String(managedTagKey), Value: aws.String("true")},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to run EC2 instance")
+	}
+	return copyOf(instance), nil
+}
+
+// runInstance launches a pending instance with the tags.
+func (c *AWSClient) runInstance(input awsforge.CreateInstanceParams, tags []*ec2.Tag) (*ec2.Instance, error) {
+	image, ok := c.images[input.AmiID]
+	if !ok || aws.StringValue(image.State) != ec2.ImageStateAvailable {
+		return nil, notFound("InvalidAMIID.NotFound", input.AmiID)
+	}
+	subnet, ok := c.subnets[input.SubnetID]
+	if !ok {
+		return nil, notFound("InvalidSubnetID.NotFound", input.SubnetID)
+	}
+	zone := aws.StringValue(subnet.AvailabilityZone)
+	if input.AvailabilityZone != "" && input.AvailabilityZone != zone {
+		return nil, awserrNew("InvalidParameterValue", "the subnet is not in the requested availability zone")
+	}
+	if !slices.Contains(c.offeredZones(input.InstanceType), zone) {
+		return nil, awserrNew("Unsupported", fmt.Sprintf("Your requested instance type (%s) is not supported in your requested Availability Zone (%s)", input.InstanceType, zone))
+	}
+
+	var groups []*ec2.GroupIdentifier
+	sgIDs := append([]string{input.SecurityGroupID}, input.AdditionalSecurityGroupIDs...)
+	for _, sgID := range sgIDs {
+		if sgID == "" {
+			continue
+		}
+		sg, ok := c.securityGroups[sgID]
+		if !ok {
+			return nil, notFound("InvalidGroup.NotFound", sgID)
+		}
+		groups = append(groups, &ec2.GroupIdentifier{GroupId: sg.GroupId, GroupName: sg.GroupName})
+	}
+
+	instance := &ec2.Instance{
+		InstanceId:       aws.String(c.newID("i")),
+		ImageId:          aws.String(input.AmiID),
+		InstanceType:     aws.String(input.InstanceType),
+		State:            instanceState(ec2.InstanceStateNamePending),
+		SubnetId:         subnet.SubnetId,
+		VpcId:            subnet.VpcId,
+		Placement:        &ec2.Placement{AvailabilityZone: aws.String(zone)},
+		PrivateIpAddress: aws.String(c.privateIP(subnet)),
+		SecurityGroups:   groups,
+		RootDeviceName:   image.RootDeviceName,
+		RootDeviceType:   aws.String(ec2.DeviceTypeEbs),
+		Tags:             tags,
+		BlockDeviceMappings: []*ec2.InstanceBlockDeviceMapping{
+			{DeviceName: image.RootDeviceName, Ebs: &ec2.EbsInstanceBlockDevice{VolumeId: aws.String(c.newID("vol"))}},
+		},
+	}
+
+	networkInterface := &ec2.InstanceNetworkInterface{
+		NetworkInterfaceId: aws.String(c.newID("eni")),
+		PrivateIpAddress:   instance.PrivateIpAddress,
+		SubnetId:           subnet.SubnetId,
+		VpcId:              subnet.VpcId,
+	}
+	if input.PublicIP {
+		publicIP := fmt.Sprintf("203.0.113.%d", len(c.instances)%254+1)
+		instance.PublicIpAddress = aws.String(publicIP)
+		networkInterface.Association = &ec2.InstanceNetworkInterfaceAssociation{PublicIp: aws.String(publicIP)}
+	}
+	instance.NetworkInterfaces = []*ec2.InstanceNetworkInterface{networkInterface}
+
+	if input.IAMInstanceProfile != "" {
+		profile, ok := c.instanceProfiles[input.IAMInstanceProfile]
+		if !ok {
+			return nil, awserrNew("InvalidParameterValue", fmt.Sprintf("Value (%s) for parameter iamInstanceProfile.name is invalid", input.IAMInstanceProfile))
+		}
+		instance.IamInstanceProfile = &ec2.IamInstanceProfile{Arn: profile.Arn, Id: profile.InstanceProfileId}
+	}
+
+	if input.SpotOptions != nil {
+		instance.InstanceLifecycle = aws.String(ec2.InstanceLifecycleTypeSpot)
+		instance.SpotInstanceRequestId = aws.String(c.newID("sir"))
+		c.spotRequests[*instance.SpotInstanceRequestId] = ec2.SpotInstanceStateActive
+	}
+
+	c.instances[*instance.InstanceId] = instance
+	return instance, nil
+}
+
+// IsManagedInstance checks if the instance is tagged as managed by forge, missing instances are not managed.
+func (c *AWSClient) IsManagedInstance(_ context.Context, instanceID *string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("IsManagedInstance"); err != nil {
+		return false, err
+	}
+
+	instance, ok := c.instances[aws.StringValue(instanceID)]
+	if !ok {
+		return false, nil
+	}
+	return hasTag(instance.Tags, managedTagKey, "true"), nil
+}
+
+// FindInstanceByID returns the instance with the ID, or nil if there is none.
+func (c *AWSClient) FindInstanceByID(_ context.Context, instanceID *string) (*ec2.Instance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("FindInstanceByID"); err != nil {
+		return nil, err
+	}
+
+	c.advance()
+	return copyOf(c.instances[aws.StringValue(instanceID)]), nil
+}
+
+// TerminateInstance moves the instance to shutting-down, it is terminated when it is next described.
+func (c *AWSClient) TerminateInstance(_ context.Context, instanceID *string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("TerminateInstance"); err != nil {
+		return errors.Wrap(err, "failed to terminate EC2 instance")
+	}
+
+	if err := c.terminateInstance(aws.StringValue(instanceID)); err != nil {
+		return errors.Wrap(err, "failed to terminate EC2 instance")
+	}
+	return nil
+}
+
+func (c *AWSClient) terminateInstance(id string) error {
+	instance, ok := c.instances[id]
+	if !ok {
+		return notFound("InvalidInstanceID.NotFound", id)
+	}
+	if aws.StringValue(instance.State.Name) != ec2.InstanceStateNameTerminated {
+		instance.State = instanceState(ec2.InstanceStateNameShuttingDown)
+	}
+	return nil
+}
+
+// CancelSpotInstanceRequest cancels the Spot request.
+func (c *AWSClient) CancelSpotInstanceRequest(_ context.Context, requestID *string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("CancelSpotInstanceRequest"); err != nil {
+		return errors.Wrap(err, "failed to cancel Spot instance request")
+	}
+
+	id := aws.StringValue(requestID)
+	if _, ok := c.spotRequests[id]; !ok {
+		return errors.Wrap(notFound("InvalidSpotInstanceRequestID.NotFound", id), "failed to cancel Spot instance request")
+	}
+	c.spotRequests[id] = ec2.SpotInstanceStateCancelled
+	return nil
+}
+
+// SpotRequestState returns the state of the Spot request, or an empty string if there is none.
+func (c *AWSClient) SpotRequestState(requestID string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.spotRequests[requestID]
+}
+
+// InterruptSpotInstance interrupts the Spot instance like AWS does when it reclaims the capacity.
+// Instances are stopped when the behavior is stop or hibernate and terminated otherwise.
+func (c *AWSClient) InterruptSpotInstance(instanceID, behavior string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	instance, ok := c.instances[instanceID]
+	if !ok || instance.SpotInstanceRequestId == nil {
+		return errors.Errorf("Spot instance %s not found", instanceID)
+	}
+
+	instance.StateReason = &ec2.StateReason{
+		Code:    aws.String("Server.SpotInstanceTermination"),
+		Message: aws.String("Server.SpotInstanceTermination: Spot instance termination"),
+	}
+	switch behavior {
+	case ec2.InstanceInterruptionBehaviorStop, ec2.InstanceInterruptionBehaviorHibernate:
+		instance.StateReason.Code = aws.String("Server.SpotInstanceShutdown")
+		instance.StateReason.Message = aws.String("Server.SpotInstanceShutdown: Spot instance shutdown")
+		instance.State = instanceState(ec2.InstanceStateNameStopped)
+	default:
+		instance.State = instanceState(ec2.InstanceStateNameTerminated)
+	}
+	return nil
+}
+
+// privateIP returns the next free address of the subnet, AWS reserves the first four addresses.
+func (c *AWSClient) privateIP(subnet *ec2.Subnet) string {
+	_, subnetNet, err := net.ParseCIDR(aws.StringValue(subnet.CidrBlock))
+	if err != nil {
+		return ""
+	}
+	used := 0
+	for _, instance := range c.instances {
+		if aws.StringValue(instance.SubnetId) == aws.StringValue(subnet.SubnetId) {
+			used++
+		}
+	}
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, binary.BigEndian.Uint32(subnetNet.IP.To4())+uint32(4+used))
+	return ip.String()
+}
+
+func instanceState(name string) *ec2.InstanceState {
+	codes := map[string]int64{
+		ec2.InstanceStateNamePending:      0,
+		ec2.InstanceStateNameRunning:      16,
+		ec2.InstanceStateNameShuttingDown: 32,
+		ec2.InstanceStateNameTerminated:   48,
+		ec2.InstanceStateNameStopping:     64,
+		ec2.InstanceStateNameStopped:      80,
+	}
+	return &ec2.InstanceState{Name: aws.String(name), Code: aws.Int64(codes[name])}
+}
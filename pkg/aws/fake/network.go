@@ -0,0 +1,705 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+	"github.com/pkg/errors"
+)
+
+const defaultRouteCIDR = "0.0.0.0/0"
+
+// CreateVPC creates the VPC with its main route table and its default Security Group.
+func (c *AWSClient) CreateVPC(_ context.Context, input *ec2.CreateVpcInput) (*ec2.Vpc, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("CreateVPC"); err != nil {
+		return nil, errors.Wrap(err, "failed to create VPC")
+	}
+
+	vpc, err := c.createVPC(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create VPC")
+	}
+	return copyOf(vpc), nil
+}
+
+func (c *AWSClient) createVPC(input *ec2.CreateVpcInput) (*ec2.Vpc, error) {
+	if _, _, err := net.ParseCIDR(aws.StringValue(input.CidrBlock)); err != nil {
+		return nil, awserrNew("InvalidParameterValue", fmt.Sprintf("Value (%s) for parameter cidrBlock is invalid", aws.StringValue(input.CidrBlock)))
+	}
+
+	vpc := &ec2.Vpc{
+		VpcId:     aws.String(c.newID("vpc")),
+		CidrBlock: input.CidrBlock,
+		State:     aws.String(ec2.VpcStateAvailable),
+		OwnerId:   aws.String(c.AccountID),
+		Tags:      tags(input.TagSpecifications, ec2.ResourceTypeVpc),
+	}
+	c.vpcs[*vpc.VpcId] = vpc
+
+	mainRouteTableID := c.newID("rtb")
+	c.routeTables[mainRouteTableID] = &ec2.RouteTable{
+		RouteTableId: aws.String(mainRouteTableID),
+		VpcId:        vpc.VpcId,
+		OwnerId:      vpc.OwnerId,
+		Routes:       []*ec2.Route{localRoute(vpc)},
+		Associations: []*ec2.RouteTableAssociation{
+			{
+				Main:                    aws.Bool(true),
+				RouteTableId:            aws.String(mainRouteTableID),
+				RouteTableAssociationId: aws.String(c.newID("rtbassoc")),
+			},
+		},
+	}
+
+	defaultSGID := c.newID("sg")
+	c.securityGroups[defaultSGID] = &ec2.SecurityGroup{
+		GroupId:     aws.String(defaultSGID),
+		GroupName:   aws.String("default"),
+		Description: aws.String("default VPC security group"),
+		VpcId:       vpc.VpcId,
+		OwnerId:     vpc.OwnerId,
+	}
+
+	return vpc, nil
+}
+
+// FindVPCByIDOrName returns the VPC with the ID, or with the Name tag when there is none, or nil.
+func (c *AWSClient) FindVPCByIDOrName(_ context.Context, vpcID, vpcName *string) (*ec2.Vpc, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("FindVPCByIDOrName"); err != nil {
+		return nil, errors.Wrap(err, "Failed to find VPC by Name")
+	}
+
+	if vpc, ok := c.vpcs[aws.StringValue(vpcID)]; ok {
+		return copyOf(vpc), nil
+	}
+	if vpcName != nil {
+		for _, id := range sortedKeys(c.vpcs) {
+			if hasTag(c.vpcs[id].Tags, "Name", *vpcName) {
+				return copyOf(c.vpcs[id]), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// DetectEgressIP returns EgressIP.
+func (c *AWSClient) DetectEgressIP(_ context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("DetectEgressIP"); err != nil {
+		return "", errors.Wrap(err, "failed to detect egress IP")
+	}
+	return c.EgressIP, nil
+}
+
+// IsManagedVPC checks if the VPC is tagged as managed by forge.
+func (c *AWSClient) IsManagedVPC(_ context.Context, vpcID *string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("IsManagedVPC"); err != nil {
+		return false, errors.Wrap(err, "failed to describe VPC")
+	}
+
+	vpc, ok := c.vpcs[aws.StringValue(vpcID)]
+	if !ok {
+		return false, errors.Wrap(notFound("InvalidVpcID.NotFound", aws.StringValue(vpcID)), "failed to describe VPC")
+	}
+	return hasTag(vpc.Tags, managedTagKey, "true"), nil
+}
+
+// DeleteVPC deletes the VPC with its main route table and default Security Group.
+// It fails like AWS while the VPC still has subnets, gateways, endpoints, route tables or Security Groups.
+func (c *AWSClient) DeleteVPC(_ context.Context, vpcID *string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("DeleteVPC"); err != nil {
+		return errors.Wrap(err, "failed to delete VPC")
+	}
+
+	if err := c.deleteVPC(aws.StringValue(vpcID)); err != nil {
+		return errors.Wrap(err, "failed to delete VPC")
+	}
+	return nil
+}
+
+func (c *AWSClient) deleteVPC(id string) error {
+	if _, ok := c.vpcs[id]; !ok {
+		return notFound("InvalidVpcID.NotFound", id)
+	}
+	if dependent := c.vpcDependent(id); dependent != "" {
+		return dependencyViolation(id, dependent)
+	}
+
+	for routeTableID, routeTable := range c.routeTables {
+		if aws.StringValue(routeTable.VpcId) == id {
+			delete(c.routeTables, routeTableID)
+		}
+	}
+	for sgID, sg := range c.securityGroups {
+		if aws.StringValue(sg.VpcId) == id {
+			delete(c.securityGroups, sgID)
+		}
+	}
+	delete(c.vpcs, id)
+	return nil
+}
+
+// vpcDependent returns the ID of a resource which prevents deleting the VPC, if any.
+func (c *AWSClient) vpcDependent(vpcID string) string {
+	for id, subnet := range c.subnets {
+		if aws.StringValue(subnet.VpcId) == vpcID {
+			return id
+		}
+	}
+	for id, igw := range c.internetGateways {
+		for _, attachment := range igw.Attachments {
+			if aws.StringValue(attachment.VpcId) == vpcID {
+				return id
+			}
+		}
+	}
+	for id, endpoint := range c.vpcEndpoints {
+		if aws.StringValue(endpoint.VpcId) == vpcID && aws.StringValue(endpoint.State) != "deleted" {
+			return id
+		}
+	}
+	for id, routeTable := range c.routeTables {
+		if aws.StringValue(routeTable.VpcId) == vpcID && !isMainRouteTable(routeTable) {
+			return id
+		}
+	}
+	for id, sg := range c.securityGroups {
+		if aws.StringValue(sg.VpcId) == vpcID && aws.StringValue(sg.GroupName) != "default" {
+			return id
+		}
+	}
+	return ""
+}
+
+// CreateOrGetInternetGateway returns the Internet Gateway attached to the VPC, creating and attaching one if needed.
+func (c *AWSClient) CreateOrGetInternetGateway(_ context.Context, vpcID string) (*ec2.InternetGateway, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("CreateOrGetInternetGateway"); err != nil {
+		return nil, errors.Wrap(err, "failed to create Internet Gateway")
+	}
+
+	if _, ok := c.vpcs[vpcID]; !ok {
+		return nil, errors.Wrap(notFound("InvalidVpcID.NotFound", vpcID), "failed to attach Internet Gateway to VPC")
+	}
+	if igw := c.findInternetGateway(vpcID); igw != nil {
+		return copyOf(igw), nil
+	}
+
+	igw := &ec2.InternetGateway{
+		InternetGatewayId: aws.String(c.newID("igw")),
+		OwnerId:           aws.String(c.AccountID),
+		Attachments: []*ec2.InternetGatewayAttachment{
+			{VpcId: aws.String(vpcID), State: aws.String("available")},
+		},
+	}
+	c.internetGateways[*igw.InternetGatewayId] = igw
+	return copyOf(igw), nil
+}
+
+// DetachAndDeleteInternetGateway detaches and deletes the Internet Gateway attached to the VPC.
+func (c *AWSClient) DetachAndDeleteInternetGateway(_ context.Context, vpcID *string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("DetachAndDeleteInternetGateway"); err != nil {
+		return errors.Wrap(err, "failed to describe Internet Gateway")
+	}
+
+	if igw := c.findInternetGateway(aws.StringValue(vpcID)); igw != nil {
+		delete(c.internetGateways, aws.StringValue(igw.InternetGatewayId))
+	}
+	return nil
+}
+
+func (c *AWSClient) findInternetGateway(vpcID string) *ec2.InternetGateway {
+	for _, id := range sortedKeys(c.internetGateways) {
+		for _, attachment := range c.internetGateways[id].Attachments {
+			if aws.StringValue(attachment.VpcId) == vpcID {
+				return c.internetGateways[id]
+			}
+		}
+	}
+	return nil
+}
+
+// CreateSubnet creates the subnet with the requested CIDR, or with the first free block of the VPC.
+func (c *AWSClient) CreateSubnet(_ context.Context, params awsforge.CreateSubnetParams) (*ec2.Subnet, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("CreateSubnet"); err != nil {
+		return nil, errors.Wrap(err, "failed to create subnet")
+	}
+
+	if params.VPCID == nil {
+		return nil, errors.New("VPC ID is not set in scope")
+	}
+	vpc, ok := c.vpcs[*params.VPCID]
+	if !ok {
+		return nil, errors.Wrap(notFound("InvalidVpcID.NotFound", *params.VPCID), "failed to retrieve VPC CIDR")
+	}
+
+	var used []string
+	for _, subnet := range c.subnets {
+		if aws.StringValue(subnet.VpcId) == *params.VPCID {
+			used = append(used, aws.StringValue(subnet.CidrBlock))
+		}
+	}
+	used = append(used, params.ReservedCIDRs...)
+
+	cidrBlock := params.CIDRBlock
+	if cidrBlock != "" {
+		if err := awsforge.ValidateSubnetInVPC(aws.StringValue(vpc.CidrBlock), cidrBlock); err != nil {
+			return nil, err
+		}
+		if overlaps(cidrBlock, used) {
+			return nil, errors.Errorf("subnet CIDR %s overlaps with an existing subnet in VPC %s", cidrBlock, *params.VPCID)
+		}
+	} else {
+		prefixLength := params.PrefixLength
+		if prefixLength == 0 {
+			prefixLength = awsforge.DefaultSubnetPrefixLength
+		}
+		var err error
+		cidrBlock, err = freeCIDR(aws.StringValue(vpc.CidrBlock), used, prefixLength)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to find available CIDR block")
+		}
+	}
+
+	name := params.Name
+	if name == "" {
+		name = fmt.Sprintf("%s-subnet", params.VPCName)
+	}
+
+	subnet, err := c.createSubnet(*params.VPCID, cidrBlock, params.AvailabilityZone, []*ec2.Tag{
+		{Key: aws.String("Name"), Value: aws.String(name)},
+		{Key: aws.String(managedTagKey), Value: aws.String("true")},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create subnet")
+	}
+	return copyOf(subnet), nil
+}
+
+// createSubnet creates the subnet in the zone, or in the first zone of the region when empty.
+func (c *AWSClient) createSubnet(vpcID, cidrBlock, zone string, tags []*ec2.Tag) (*ec2.Subnet, error) {
+	vpc, ok := c.vpcs[vpcID]
+	if !ok {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+	if err := awsforge.ValidateSubnetInVPC(aws.StringValue(vpc.CidrBlock), cidrBlock); err != nil {
+		return nil, awserrNew("InvalidSubnet.Range", err.Error())
+	}
+	for _, subnet := range c.subnets {
+		if aws.StringValue(subnet.VpcId) == vpcID && overlaps(cidrBlock, []string{aws.StringValue(subnet.CidrBlock)}) {
+			return nil, awserrNew("InvalidSubnet.Conflict", fmt.Sprintf("The CIDR '%s' conflicts with another subnet", cidrBlock))
+		}
+	}
+
+	if zone == "" {
+		zone = c.Zones()[0]
+	} else if !slices.Contains(c.Zones(), zone) {
+		return nil, awserrNew("InvalidParameterValue", fmt.Sprintf("Value (%s) for parameter availabilityZone is invalid", zone))
+	}
+
+	subnet := &ec2.Subnet{
+		SubnetId:         aws.String(c.newID("subnet")),
+		VpcId:            aws.String(vpcID),
+		CidrBlock:        aws.String(cidrBlock),
+		AvailabilityZone: aws.String(zone),
+		State:            aws.String(ec2.SubnetStateAvailable),
+		OwnerId:          aws.String(c.AccountID),
+		Tags:             tags,
+	}
+	c.subnets[*subnet.SubnetId] = subnet
+	return subnet, nil
+}
+
+// DeleteSubnet deletes the subnet, it fails while instances, NAT gateways or endpoints are placed in it.
+func (c *AWSClient) DeleteSubnet(_ context.Context, subnetID *string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("DeleteSubnet"); err != nil {
+		return errors.Wrap(err, "failed to delete subnet")
+	}
+
+	if err := c.deleteSubnet(aws.StringValue(subnetID)); err != nil {
+		return errors.Wrap(err, "failed to delete subnet")
+	}
+	return nil
+}
+
+func (c *AWSClient) deleteSubnet(id string) error {
+	if _, ok := c.subnets[id]; !ok {
+		return notFound("InvalidSubnetID.NotFound", id)
+	}
+	for instanceID, instance := range c.instances {
+		if aws.StringValue(instance.SubnetId) == id && aws.StringValue(instance.State.Name) != ec2.InstanceStateNameTerminated {
+			return dependencyViolation(id, instanceID)
+		}
+	}
+	for natGatewayID, natGateway := range c.natGateways {
+		if aws.StringValue(natGateway.SubnetId) == id && aws.StringValue(natGateway.State) != ec2.NatGatewayStateDeleted {
+			return dependencyViolation(id, natGatewayID)
+		}
+	}
+	for endpointID, endpoint := range c.vpcEndpoints {
+		if slices.Contains(aws.StringValueSlice(endpoint.SubnetIds), id) && aws.StringValue(endpoint.State) != "deleted" {
+			return dependencyViolation(id, endpointID)
+		}
+	}
+
+	for _, routeTable := range c.routeTables {
+		var associations []*ec2.RouteTableAssociation
+		for _, association := range routeTable.Associations {
+			if aws.StringValue(association.SubnetId) != id {
+				associations = append(associations, association)
+			}
+		}
+		routeTable.Associations = associations
+	}
+	delete(c.subnets, id)
+	return nil
+}
+
+// IsManagedSubnet checks if the subnet is tagged as managed by forge, missing subnets are not managed.
+func (c *AWSClient) IsManagedSubnet(_ context.Context, subnetID string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("IsManagedSubnet"); err != nil {
+		return false, errors.Wrap(err, "failed to describe subnet")
+	}
+
+	subnet, ok := c.subnets[subnetID]
+	if !ok {
+		return false, nil
+	}
+	return hasTag(subnet.Tags, managedTagKey, "true"), nil
+}
+
+// FindSubnetByID returns the subnet with the ID.
+func (c *AWSClient) FindSubnetByID(_ context.Context, subnetID string) (*ec2.Subnet, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("FindSubnetByID"); err != nil {
+		return nil, errors.Wrap(err, "failed to describe subnet by ID")
+	}
+
+	subnet, ok := c.subnets[subnetID]
+	if !ok {
+		return nil, errors.Wrap(notFound("InvalidSubnetID.NotFound", subnetID), "failed to describe subnet by ID")
+	}
+	return copyOf(subnet), nil
+}
+
+// FindSubnetByName returns the subnet of the VPC with the Name tag, or nil if there is none.
+func (c *AWSClient) FindSubnetByName(_ context.Context, vpcID, name string) (*ec2.Subnet, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("FindSubnetByName"); err != nil {
+		return nil, errors.Wrap(err, "failed to describe subnets")
+	}
+
+	for _, id := range sortedKeys(c.subnets) {
+		subnet := c.subnets[id]
+		if aws.StringValue(subnet.VpcId) == vpcID && hasTag(subnet.Tags, "Name", name) {
+			return copyOf(subnet), nil
+		}
+	}
+	return nil, nil
+}
+
+// FindAvailabilityZones returns the zones offering the instance type, in the order of the preferred zones if any.
+func (c *AWSClient) FindAvailabilityZones(_ context.Context, instanceType string, preferred []string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("FindAvailabilityZones"); err != nil {
+		return nil, errors.Wrap(err, "failed to describe instance type offerings")
+	}
+
+	offered := c.offeredZones(instanceType)
+	var zones []string
+	if len(preferred) == 0 {
+		zones = offered
+	} else {
+		for _, zone := range preferred {
+			if slices.Contains(offered, zone) {
+				zones = append(zones, zone)
+			}
+		}
+	}
+
+	if len(zones) == 0 {
+		return nil, errors.Errorf("instance type %s is not offered in availability zones %v", instanceType, preferred)
+	}
+	return zones, nil
+}
+
+func (c *AWSClient) offeredZones(instanceType string) []string {
+	if zones, ok := c.Offerings[instanceType]; ok {
+		return zones
+	}
+	return c.Zones()
+}
+
+// CreateOrGetRouteTable returns the forge-managed route table of the VPC with the Name tag, creating it if needed.
+func (c *AWSClient) CreateOrGetRouteTable(_ context.Context, vpcID, name string) (*ec2.RouteTable, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("CreateOrGetRouteTable"); err != nil {
+		return nil, errors.Wrap(err, "failed to create route table")
+	}
+
+	vpc, ok := c.vpcs[vpcID]
+	if !ok {
+		return nil, errors.Wrap(notFound("InvalidVpcID.NotFound", vpcID), "failed to create route table")
+	}
+	for _, id := range sortedKeys(c.routeTables) {
+		routeTable := c.routeTables[id]
+		if aws.StringValue(routeTable.VpcId) == vpcID && hasTag(routeTable.Tags, "Name", name) && hasTag(routeTable.Tags, managedTagKey, "true") {
+			return copyOf(routeTable), nil
+		}
+	}
+
+	routeTable := &ec2.RouteTable{
+		RouteTableId: aws.String(c.newID("rtb")),
+		VpcId:        vpc.VpcId,
+		OwnerId:      vpc.OwnerId,
+		Routes:       []*ec2.Route{localRoute(vpc)},
+		Tags: []*ec2.Tag{
+			{Key: aws.String("Name"), Value: aws.String(name)},
+			{Key: aws.String(managedTagKey), Value: aws.String("true")},
+		},
+	}
+	c.routeTables[*routeTable.RouteTableId] = routeTable
+	return copyOf(routeTable), nil
+}
+
+// EnsureDefaultRoute points the default route of the route table to the target.
+func (c *AWSClient) EnsureDefaultRoute(_ context.Context, routeTable *ec2.RouteTable, target awsforge.RouteTarget) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("EnsureDefaultRoute"); err != nil {
+		return errors.Wrap(err, "failed to add default route")
+	}
+
+	id := aws.StringValue(routeTable.RouteTableId)
+	stored, ok := c.routeTables[id]
+	if !ok {
+		return errors.Wrap(notFound("InvalidRouteTableID.NotFound", id), "failed to add default route")
+	}
+	route, err := c.newRoute(defaultRouteCIDR, target.InternetGatewayID, target.NATGatewayID)
+	if err != nil {
+		return errors.Wrap(err, "failed to add default route")
+	}
+
+	for i, existing := range stored.Routes {
+		if aws.StringValue(existing.DestinationCidrBlock) == defaultRouteCIDR {
+			stored.Routes[i] = route
+			return nil
+		}
+	}
+	stored.Routes = append(stored.Routes, route)
+	return nil
+}
+
+// AssociateRouteTable associates the route table with the subnet, replacing the previous association of the subnet.
+func (c *AWSClient) AssociateRouteTable(_ context.Context, routeTableID, subnetID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("AssociateRouteTable"); err != nil {
+		return errors.Wrap(err, "failed to associate route table with subnet")
+	}
+
+	routeTable, ok := c.routeTables[routeTableID]
+	if !ok {
+		return errors.Wrap(notFound("InvalidRouteTableID.NotFound", routeTableID), "failed to associate route table with subnet")
+	}
+	if _, ok := c.subnets[subnetID]; !ok {
+		return errors.Wrap(notFound("InvalidSubnetID.NotFound", subnetID), "failed to associate route table with subnet")
+	}
+
+	for _, other := range c.routeTables {
+		var associations []*ec2.RouteTableAssociation
+		for _, association := range other.Associations {
+			if aws.StringValue(association.SubnetId) == subnetID {
+				if other == routeTable {
+					return nil
+				}
+				continue
+			}
+			associations = append(associations, association)
+		}
+		other.Associations = associations
+	}
+
+	routeTable.Associations = append(routeTable.Associations, &ec2.RouteTableAssociation{
+		RouteTableAssociationId: aws.String(c.newID("rtbassoc")),
+		RouteTableId:            aws.String(routeTableID),
+		SubnetId:                aws.String(subnetID),
+		Main:                    aws.Bool(false),
+	})
+	return nil
+}
+
+// DeleteRouteTable removes the associations of the route table and deletes it, missing route tables are ignored.
+func (c *AWSClient) DeleteRouteTable(_ context.Context, routeTableID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("DeleteRouteTable"); err != nil {
+		return errors.Wrap(err, "failed to describe route table")
+	}
+
+	if routeTable, ok := c.routeTables[routeTableID]; ok {
+		return c.deleteRouteTable(routeTable)
+	}
+	return nil
+}
+
+// DeleteRouteTables deletes the forge-managed route tables of the VPC.
+func (c *AWSClient) DeleteRouteTables(_ context.Context, vpcID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("DeleteRouteTables"); err != nil {
+		return errors.Wrap(err, "failed to describe route tables")
+	}
+
+	for _, id := range sortedKeys(c.routeTables) {
+		routeTable := c.routeTables[id]
+		if aws.StringValue(routeTable.VpcId) != vpcID || !hasTag(routeTable.Tags, managedTagKey, "true") {
+			continue
+		}
+		if err := c.deleteRouteTable(routeTable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *AWSClient) deleteRouteTable(routeTable *ec2.RouteTable) error {
+	id := aws.StringValue(routeTable.RouteTableId)
+	if isMainRouteTable(routeTable) {
+		return errors.Errorf("route table %s is the main route table of the VPC", id)
+	}
+	delete(c.routeTables, id)
+	return nil
+}
+
+// newRoute returns an active route to the Internet Gateway or the NAT gateway, which must exist.
+func (c *AWSClient) newRoute(destination, gatewayID, natGatewayID string) (*ec2.Route, error) {
+	route := &ec2.Route{
+		DestinationCidrBlock: aws.String(destination),
+		State:                aws.String(ec2.RouteStateActive),
+		Origin:               aws.String(ec2.RouteOriginCreateRoute),
+	}
+	if gatewayID != "" {
+		if _, ok := c.internetGateways[gatewayID]; !ok {
+			return nil, notFound("InvalidInternetGatewayID.NotFound", gatewayID)
+		}
+		route.GatewayId = aws.String(gatewayID)
+	}
+	if natGatewayID != "" {
+		if _, ok := c.natGateways[natGatewayID]; !ok {
+			return nil, notFound("InvalidNatGatewayID.NotFound", natGatewayID)
+		}
+		route.NatGatewayId = aws.String(natGatewayID)
+	}
+	return route, nil
+}
+
+func isMainRouteTable(routeTable *ec2.RouteTable) bool {
+	for _, association := range routeTable.Associations {
+		if aws.BoolValue(association.Main) {
+			return true
+		}
+	}
+	return false
+}
+
+func localRoute(vpc *ec2.Vpc) *ec2.Route {
+	return &ec2.Route{
+		DestinationCidrBlock: vpc.CidrBlock,
+		GatewayId:            aws.String("local"),
+		State:                aws.String(ec2.RouteStateActive),
+		Origin:               aws.String(ec2.RouteOriginCreateRouteTable),
+	}
+}
+
+// freeCIDR returns the first block of the prefix length in the VPC that doesn't overlap the used CIDRs.
+func freeCIDR(vpcCIDR string, used []string, prefixLength int) (string, error) {
+	_, vpcNet, err := net.ParseCIDR(vpcCIDR)
+	if err != nil {
+		return "", err
+	}
+	vpcPrefixLength, bits := vpcNet.Mask.Size()
+	if bits != 32 || prefixLength < vpcPrefixLength || prefixLength > 28 {
+		return "", errors.Errorf("invalid subnet prefix length /%d for VPC CIDR %s", prefixLength, vpcCIDR)
+	}
+
+	base := binary.BigEndian.Uint32(vpcNet.IP.To4())
+	size := uint32(1) << (32 - prefixLength)
+	for i := uint32(0); i < uint32(1)<<(prefixLength-vpcPrefixLength); i++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, base+i*size)
+		candidate := fmt.Sprintf("%s/%d", ip, prefixLength)
+		if !overlaps(candidate, used) {
+			return candidate, nil
+		}
+	}
+	return "", errors.Errorf("no available /%d block in VPC CIDR %s", prefixLength, vpcCIDR)
+}
+
+// overlaps checks if the CIDR overlaps any of the other CIDRs.
+func overlaps(cidr string, others []string) bool {
+	_, cidrNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	for _, other := range others {
+		_, otherNet, err := net.ParseCIDR(other)
+		if err != nil {
+			continue
+		}
+		if cidrNet.Contains(otherNet.IP) || otherNet.Contains(cidrNet.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the IDs of the resources in creation order, so that lookups are deterministic.
+func sortedKeys[T any](resources map[string]T) []string {
+	return slices.Sorted(maps.Keys(resources))
+}
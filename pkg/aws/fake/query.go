@@ -0,0 +1,249 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// decodeQuery sets the fields of the EC2 input struct from the parameters of a Query API request,
+// following the naming of the SDK: lists are flattened as Name.N and struct members as Name.Member.
+func decodeQuery(values url.Values, v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := queryName(field)
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if err := decodeQueryValue(values, v.Field(i), name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeQueryValue(values url.Values, v reflect.Value, name string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.Type().Elem().Kind() == reflect.Struct && v.Type().Elem() != timeType {
+			if !hasQueryPrefix(values, name+".") {
+				return nil
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+			return decodeQuery(values, v.Elem(), name)
+		}
+		if !values.Has(name) {
+			return nil
+		}
+		value := reflect.New(v.Type().Elem())
+		if err := decodeQueryScalar(values.Get(name), value.Elem()); err != nil {
+			return fmt.Errorf("invalid value for parameter %s: %w", name, err)
+		}
+		v.Set(value)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if !values.Has(name) {
+				return nil
+			}
+			return decodeQueryScalar(values.Get(name), v)
+		}
+		for n := 1; values.Has(fmt.Sprintf("%s.%d", name, n)) || hasQueryPrefix(values, fmt.Sprintf("%s.%d.", name, n)); n++ {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeQueryValue(values, elem, fmt.Sprintf("%s.%d", name, n)); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, elem))
+		}
+	}
+	return nil
+}
+
+func decodeQueryScalar(s string, v reflect.Value) error {
+	switch v.Interface().(type) {
+	case string:
+		v.SetString(s)
+	case int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case time.Time:
+		t, err := protocol.ParseTime(protocol.ISO8601TimeFormatName, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+	case []byte:
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		v.SetBytes(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// queryName returns the name of the field in EC2 requests, see the queryutil package of the SDK.
+func queryName(field reflect.StructField) string {
+	if name := field.Tag.Get("queryName"); name != "" {
+		return name
+	}
+	if name := field.Tag.Get("locationName"); name != "" {
+		return strings.ToUpper(name[:1]) + name[1:]
+	}
+	return field.Name
+}
+
+func hasQueryPrefix(values url.Values, prefix string) bool {
+	for key := range values {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeXML writes the value as the element of an EC2 response, lists are wrapped in items like the EC2 API does.
+func encodeXML(e *xml.Encoder, name string, v reflect.Value, tag reflect.StructTag) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+
+	switch {
+	case v.Type() == timeType:
+		return e.EncodeElement(protocol.FormatTime(protocol.ISO8601TimeFormatName, v.Interface().(time.Time)), start)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return e.EncodeElement(base64.StdEncoding.EncodeToString(v.Bytes()), start)
+	case v.Kind() == reflect.Struct:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		if err := encodeXMLFields(e, v); err != nil {
+			return err
+		}
+		return e.EncodeToken(start.End())
+	case v.Kind() == reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		itemName := tag.Get("locationNameList")
+		if itemName == "" {
+			itemName = "item"
+		}
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeXML(e, itemName, v.Index(i), ""); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	default:
+		return e.EncodeElement(fmt.Sprint(v.Interface()), start)
+	}
+}
+
+// encodeXMLFields writes the fields of the struct as elements named after their locationName.
+func encodeXMLFields(e *xml.Encoder, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Tag.Get("locationName")
+		if name == "" {
+			name = field.Name
+		}
+		if err := encodeXML(e, name, v.Field(i), field.Tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchesFilters checks if a resource with the tags and the attributes matches all the filters.
+// The values of a filter are alternatives and may contain the * and ? wildcards, tags are filtered with tag:<key>.
+func matchesFilters(filters []*ec2.Filter, tags []*ec2.Tag, attributes map[string][]string) (bool, error) {
+	for _, filter := range filters {
+		name := aws.StringValue(filter.Name)
+		var values []string
+		if key, ok := strings.CutPrefix(name, "tag:"); ok {
+			for _, tag := range tags {
+				if aws.StringValue(tag.Key) == key {
+					values = append(values, aws.StringValue(tag.Value))
+				}
+			}
+		} else {
+			attribute, ok := attributes[name]
+			if !ok {
+				return false, awserrNew("InvalidParameterValue", fmt.Sprintf("The filter '%s' is invalid", name))
+			}
+			values = attribute
+		}
+
+		matched := slices.ContainsFunc(aws.StringValueSlice(filter.Values), func(pattern string) bool {
+			return slices.ContainsFunc(values, func(value string) bool {
+				ok, _ := path.Match(pattern, value)
+				return ok
+			})
+		})
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"testing"
+
+	buildv1 "github.com/forge-build/forge/pkg/api/v1alpha1"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	"github.com/forge-build/forge-provider-aws/pkg/cloud/scope"
+)
+
+// NewBuild returns the Build owning the AWSBuilds of the scopes created by NewScope.
+func NewBuild() *buildv1.Build {
+	return &buildv1.Build{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "default", UID: "build-uid"}}
+}
+
+// NewScope returns the scope of the AWSBuild making its AWS calls with the client, the build defaults to NewBuild.
+func NewScope(t testing.TB, c *AWSClient, build *buildv1.Build, awsBuild *infrav1.AWSBuild) *scope.AWSBuildScope {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := infrav1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if build == nil {
+		build = NewBuild()
+	}
+
+	log := logr.Discard()
+	buildScope, err := scope.NewAWSBuildScope(context.Background(), scope.AWSBuildScopeParams{
+		Client:    crfake.NewClientBuilder().WithScheme(scheme).Build(),
+		Build:     build,
+		AWSBuild:  awsBuild,
+		AWSClient: c,
+		Log:       &log,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buildScope
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+// CreateSecurityGroup creates a forge-managed Security Group allowing all egress, like AWS does.
+func (c *AWSClient) CreateSecurityGroup(_ context.Context, vpcID, sgName *string) (*ec2.CreateSecurityGroupOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("CreateSecurityGroup"); err != nil {
+		return nil, errors.Wrap(err, "failed to create Security Group")
+	}
+
+	sg, err := c.createSecurityGroup(aws.StringValue(vpcID), aws.StringValue(sgName), "Security Group managed by Forge", []*ec2.Tag{
+		{Key: aws.String("Name"), Value: sgName},
+		{Key: aws.String(managedTagKey), Value: aws.String("true")},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create Security Group")
+	}
+	return &ec2.CreateSecurityGroupOutput{GroupId: sg.GroupId, Tags: copyOf(sg).Tags}, nil
+}
+
+func (c *AWSClient) createSecurityGroup(vpcID, name, description string, tags []*ec2.Tag) (*ec2.SecurityGroup, error) {
+	if _, ok := c.vpcs[vpcID]; !ok {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+	for _, sg := range c.securityGroups {
+		if aws.StringValue(sg.VpcId) == vpcID && aws.StringValue(sg.GroupName) == name {
+			return nil, awserrNew("InvalidGroup.Duplicate", fmt.Sprintf("The security group '%s' already exists for VPC '%s'", name, vpcID))
+		}
+	}
+
+	sg := &ec2.SecurityGroup{
+		GroupId:     aws.String(c.newID("sg")),
+		GroupName:   aws.String(name),
+		Description: aws.String(description),
+		VpcId:       aws.String(vpcID),
+		OwnerId:     aws.String(c.AccountID),
+		IpPermissionsEgress: []*ec2.IpPermission{
+			{IpProtocol: aws.String("-1"), IpRanges: []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}},
+		},
+		Tags: tags,
+	}
+	c.securityGroups[*sg.GroupId] = sg
+	return sg, nil
+}
+
+// FindSecurityGroupByID returns the Security Group with the ID.
+func (c *AWSClient) FindSecurityGroupByID(_ context.Context, sgID string) (*ec2.SecurityGroup, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("FindSecurityGroupByID"); err != nil {
+		return nil, errors.Wrap(err, "failed to describe Security Group")
+	}
+
+	sg, ok := c.securityGroups[sgID]
+	if !ok {
+		return nil, errors.Wrap(notFound("InvalidGroup.NotFound", sgID), "failed to describe Security Group")
+	}
+	return copyOf(sg), nil
+}
+
+// FindSecurityGroupsInVPC returns the Security Groups of the VPC with the IDs or all of the tags.
+// It fails if a Security Group given by ID is missing or belongs to another VPC.
+func (c *AWSClient) FindSecurityGroupsInVPC(_ context.Context, vpcID string, sgIDs []string, tags map[string]string) ([]*ec2.SecurityGroup, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("FindSecurityGroupsInVPC"); err != nil {
+		return nil, errors.Wrap(err, "failed to describe Security Groups")
+	}
+
+	var sgs []*ec2.SecurityGroup
+	if len(sgIDs) > 0 {
+		for _, id := range sgIDs {
+			sg, ok := c.securityGroups[id]
+			if !ok {
+				return nil, errors.Wrap(notFound("InvalidGroup.NotFound", id), "failed to describe Security Groups")
+			}
+			if aws.StringValue(sg.VpcId) != vpcID {
+				return nil, errors.Errorf("Security Group %s belongs to VPC %s instead of %s", id, aws.StringValue(sg.VpcId), vpcID)
+			}
+			sgs = append(sgs, copyOf(sg))
+		}
+		return sgs, nil
+	}
+
+	for _, id := range sortedKeys(c.securityGroups) {
+		sg := c.securityGroups[id]
+		if aws.StringValue(sg.VpcId) != vpcID {
+			continue
+		}
+		matches := true
+		for key, value := range tags {
+			matches = matches && hasTag(sg.Tags, key, value)
+		}
+		if matches {
+			sgs = append(sgs, copyOf(sg))
+		}
+	}
+	return sgs, nil
+}
+
+// AuthorizeSecurityGroupIngress adds ingress rules, it fails like AWS when a rule already exists.
+func (c *AWSClient) AuthorizeSecurityGroupIngress(_ context.Context, sgID string, permissions []*ec2.IpPermission) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("AuthorizeSecurityGroupIngress"); err != nil {
+		return errors.Wrap(err, "failed to add ingress rules to Security Group")
+	}
+
+	sg, ok := c.securityGroups[sgID]
+	if !ok {
+		return errors.Wrap(notFound("InvalidGroup.NotFound", sgID), "failed to add ingress rules to Security Group")
+	}
+	rules, err := authorize(sg.IpPermissions, permissions)
+	if err != nil {
+		return errors.Wrap(err, "failed to add ingress rules to Security Group")
+	}
+	sg.IpPermissions = rules
+	return nil
+}
+
+// RevokeSecurityGroupIngress removes ingress rules, missing rules are ignored.
+func (c *AWSClient) RevokeSecurityGroupIngress(_ context.Context, sgID string, permissions []*ec2.IpPermission) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("RevokeSecurityGroupIngress"); err != nil {
+		return errors.Wrap(err, "failed to remove ingress rules from Security Group")
+	}
+
+	sg, ok := c.securityGroups[sgID]
+	if !ok {
+		return errors.Wrap(notFound("InvalidGroup.NotFound", sgID), "failed to remove ingress rules from Security Group")
+	}
+	sg.IpPermissions = revoke(sg.IpPermissions, permissions)
+	return nil
+}
+
+// AuthorizeSecurityGroupEgress adds egress rules, it fails like AWS when a rule already exists.
+func (c *AWSClient) AuthorizeSecurityGroupEgress(_ context.Context, sgID string, permissions []*ec2.IpPermission) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("AuthorizeSecurityGroupEgress"); err != nil {
+		return errors.Wrap(err, "failed to add egress rules to Security Group")
+	}
+
+	sg, ok := c.securityGroups[sgID]
+	if !ok {
+		return errors.Wrap(notFound("InvalidGroup.NotFound", sgID), "failed to add egress rules to Security Group")
+	}
+	rules, err := authorize(sg.IpPermissionsEgress, permissions)
+	if err != nil {
+		return errors.Wrap(err, "failed to add egress rules to Security Group")
+	}
+	sg.IpPermissionsEgress = rules
+	return nil
+}
+
+// RevokeSecurityGroupEgress removes egress rules, missing rules are ignored.
+func (c *AWSClient) RevokeSecurityGroupEgress(_ context.Context, sgID string, permissions []*ec2.IpPermission) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("RevokeSecurityGroupEgress"); err != nil {
+		return errors.Wrap(err, "failed to remove egress rules from Security Group")
+	}
+
+	sg, ok := c.securityGroups[sgID]
+	if !ok {
+		return errors.Wrap(notFound("InvalidGroup.NotFound", sgID), "failed to remove egress rules from Security Group")
+	}
+	sg.IpPermissionsEgress = revoke(sg.IpPermissionsEgress, permissions)
+	return nil
+}
+
+// IsManagedSecurityGroup checks if the Security Group is tagged as managed by forge, missing groups are not managed.
+func (c *AWSClient) IsManagedSecurityGroup(_ context.Context, sgID string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("IsManagedSecurityGroup"); err != nil {
+		return false, errors.Wrap(err, "failed to describe Security Group")
+	}
+
+	sg, ok := c.securityGroups[sgID]
+	if !ok {
+		return false, nil
+	}
+	return hasTag(sg.Tags, managedTagKey, "true"), nil
+}
+
+// DeleteSecurityGroup deletes the Security Group, it fails while instances or endpoints use it.
+func (c *AWSClient) DeleteSecurityGroup(_ context.Context, sgID *string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injected("DeleteSecurityGroup"); err != nil {
+		return errors.Wrap(err, "failed to delete Security Group")
+	}
+
+	if err := c.deleteSecurityGroup(aws.StringValue(sgID)); err != nil {
+		return errors.Wrap(err, "failed to delete Security Group")
+	}
+	return nil
+}
+
+func (c *AWSClient) deleteSecurityGroup(id string) error {
+	if _, ok := c.securityGroups[id]; !ok {
+		return notFound("InvalidGroup.NotFound", id)
+	}
+	for instanceID, instance := range c.instances {
+		if aws.StringValue(instance.State.Name) == ec2.InstanceStateNameTerminated {
+			continue
+		}
+		for _, group := range instance.SecurityGroups {
+			if aws.StringValue(group.GroupId) == id {
+				return dependencyViolation(id, instanceID)
+			}
+		}
+	}
+	for endpointID, endpoint := range c.vpcEndpoints {
+		if aws.StringValue(endpoint.State) == "deleted" {
+			continue
+		}
+		for _, group := range endpoint.Groups {
+			if aws.StringValue(group.GroupId) == id {
+				return dependencyViolation(id, endpointID)
+			}
+		}
+	}
+
+	delete(c.securityGroups, id)
+	return nil
+}
+
+// authorize returns the rules with the permissions added, AWS rejects rules that already exist.
+func authorize(rules, permissions []*ec2.IpPermission) ([]*ec2.IpPermission, error) {
+	for _, permission := range permissions {
+		if slices.ContainsFunc(rules, func(rule *ec2.IpPermission) bool { return awsutil.DeepEqual(rule, permission) }) {
+			return nil, awserrNew("InvalidPermission.Duplicate", "the specified rule already exists")
+		}
+		rules = append(rules, copyOf(permission))
+	}
+	return rules, nil
+}
+
+// revoke returns the rules without the permissions.
+func revoke(rules, permissions []*ec2.IpPermission) []*ec2.IpPermission {
+	return slices.DeleteFunc(rules, func(rule *ec2.IpPermission) bool {
+		return slices.ContainsFunc(permissions, func(permission *ec2.IpPermission) bool { return awsutil.DeepEqual(rule, permission) })
+	})
+}
@@ -0,0 +1,1074 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+)
+
+const ec2Namespace = "http://ec2.amazonaws.com/doc/2016-11-15/"
+
+// EC2Server serves the subset of the EC2 Query API the provider uses from the state of an AWSClient.
+// Pointing the EC2 endpoint of the controller at it, e.g., with httptest.NewServer, runs the whole
+// reconcile loop without an AWS account. IAM and SSM calls are not served.
+type EC2Server struct {
+	client    *AWSClient
+	actions   map[string]func(url.Values) (any, error)
+	requestID int
+}
+
+// NewEC2Server returns a server for the resources of the client.
+// Errors injected into the client are returned by the actions named by their keys, e.g., RunInstances.
+func NewEC2Server(client *AWSClient) *EC2Server {
+	s := &EC2Server{client: client, actions: map[string]func(url.Values) (any, error){}}
+
+	handle(s, "DescribeVpcs", s.describeVpcs)
+	handle(s, "CreateVpc", s.createVpc)
+	handle(s, "DeleteVpc", s.deleteVpc)
+	handle(s, "ModifyVpcAttribute", s.modifyVpcAttribute)
+	handle(s, "DescribeSubnets", s.describeSubnets)
+	handle(s, "CreateSubnet", s.createSubnet)
+	handle(s, "DeleteSubnet", s.deleteSubnet)
+	handle(s, "DescribeInstanceTypeOfferings", s.describeInstanceTypeOfferings)
+
+	handle(s, "DescribeSecurityGroups", s.describeSecurityGroups)
+	handle(s, "CreateSecurityGroup", s.createSecurityGroup)
+	handle(s, "DeleteSecurityGroup", s.deleteSecurityGroup)
+	handle(s, "AuthorizeSecurityGroupIngress", s.authorizeSecurityGroupIngress)
+	handle(s, "RevokeSecurityGroupIngress", s.revokeSecurityGroupIngress)
+	handle(s, "AuthorizeSecurityGroupEgress", s.authorizeSecurityGroupEgress)
+	handle(s, "RevokeSecurityGroupEgress", s.revokeSecurityGroupEgress)
+
+	handle(s, "DescribeInternetGateways", s.describeInternetGateways)
+	handle(s, "CreateInternetGateway", s.createInternetGateway)
+	handle(s, "AttachInternetGateway", s.attachInternetGateway)
+	handle(s, "DetachInternetGateway", s.detachInternetGateway)
+	handle(s, "DeleteInternetGateway", s.deleteInternetGateway)
+
+	handle(s, "DescribeRouteTables", s.describeRouteTables)
+	handle(s, "CreateRouteTable", s.createRouteTable)
+	handle(s, "DeleteRouteTable", s.deleteRouteTable)
+	handle(s, "CreateRoute", s.createRoute)
+	handle(s, "ReplaceRoute", s.replaceRoute)
+	handle(s, "AssociateRouteTable", s.associateRouteTable)
+	handle(s, "ReplaceRouteTableAssociation", s.replaceRouteTableAssociation)
+	handle(s, "DisassociateRouteTable", s.disassociateRouteTable)
+
+	handle(s, "DescribeNatGateways", s.describeNatGateways)
+	handle(s, "CreateNatGateway", s.createNatGateway)
+	handle(s, "DeleteNatGateway", s.deleteNatGateway)
+	handle(s, "DescribeAddresses", s.describeAddresses)
+	handle(s, "AllocateAddress", s.allocateAddress)
+	handle(s, "ReleaseAddress", s.releaseAddress)
+	handle(s, "DescribeVpcEndpoints", s.describeVpcEndpoints)
+	handle(s, "CreateVpcEndpoint", s.createVpcEndpoint)
+	handle(s, "DeleteVpcEndpoints", s.deleteVpcEndpoints)
+
+	handle(s, "DescribeInstances", s.describeInstances)
+	handle(s, "RunInstances", s.runInstances)
+	handle(s, "TerminateInstances", s.terminateInstances)
+	handle(s, "CancelSpotInstanceRequests", s.cancelSpotInstanceRequests)
+
+	handle(s, "DescribeImages", s.describeImages)
+	handle(s, "CreateImage", s.createImage)
+	handle(s, "DeregisterImage", s.deregisterImage)
+	handle(s, "DescribeSnapshots", s.describeSnapshots)
+	handle(s, "DeleteSnapshot", s.deleteSnapshot)
+	handle(s, "ModifyImageAttribute", s.modifyImageAttribute)
+	handle(s, "ResetImageAttribute", s.resetImageAttribute)
+	handle(s, "ModifySnapshotAttribute", s.modifySnapshotAttribute)
+	handle(s, "ResetSnapshotAttribute", s.resetSnapshotAttribute)
+
+	return s
+}
+
+// handle registers the action, its parameters are decoded into the input struct of the SDK and
+// the output struct of the SDK is encoded as the response.
+func handle[I, O any](s *EC2Server, action string, fn func(*I) (*O, error)) {
+	s.actions[action] = func(values url.Values) (any, error) {
+		input := new(I)
+		if err := decodeQuery(values, reflect.ValueOf(input).Elem(), ""); err != nil {
+			return nil, awserrNew("InvalidParameterValue", err.Error())
+		}
+		return fn(input)
+	}
+}
+
+// ServeHTTP handles an EC2 Query API request, the actions are run one at a time.
+func (s *EC2Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.client.mu.Lock()
+	defer s.client.mu.Unlock()
+	s.requestID++
+
+	if err := r.ParseForm(); err != nil {
+		s.writeError(w, http.StatusBadRequest, "MalformedQueryString", err.Error())
+		return
+	}
+
+	action := r.Form.Get("Action")
+	fn, ok := s.actions[action]
+	if !ok {
+		s.writeError(w, http.StatusBadRequest, "InvalidAction", fmt.Sprintf("The action %s is not valid for this web service.", action))
+		return
+	}
+	output, err := s.run(action, fn, r.Form)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			s.writeError(w, http.StatusBadRequest, aerr.Code(), aerr.Message())
+			return
+		}
+		s.writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml;charset=UTF-8")
+	e := xml.NewEncoder(w)
+	start := xml.StartElement{
+		Name: xml.Name{Local: action + "Response"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: ec2Namespace}},
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return
+	}
+	if err := e.EncodeElement(s.currentRequestID(), xml.StartElement{Name: xml.Name{Local: "requestId"}}); err != nil {
+		return
+	}
+	if err := encodeXMLFields(e, reflect.ValueOf(output).Elem()); err != nil {
+		return
+	}
+	if err := e.EncodeToken(start.End()); err != nil {
+		return
+	}
+	_ = e.Flush()
+}
+
+// run runs the action, unless an error is injected for it.
+func (s *EC2Server) run(action string, fn func(url.Values) (any, error), values url.Values) (any, error) {
+	if err := s.client.injected(action); err != nil {
+		return nil, err
+	}
+	return fn(values)
+}
+
+func (s *EC2Server) currentRequestID() string {
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", s.requestID)
+}
+
+// writeError writes the error response of the EC2 Query API, which the SDK turns into an awserr.Error.
+func (s *EC2Server) writeError(w http.ResponseWriter, status int, code, message string) {
+	response := struct {
+		XMLName   xml.Name `xml:"Response"`
+		Code      string   `xml:"Errors>Error>Code"`
+		Message   string   `xml:"Errors>Error>Message"`
+		RequestID string   `xml:"RequestID"`
+	}{Code: code, Message: message, RequestID: s.currentRequestID()}
+
+	w.Header().Set("Content-Type", "text/xml;charset=UTF-8")
+	w.WriteHeader(status)
+	_ = xml.NewEncoder(w).Encode(response)
+}
+
+// selectIDs returns the IDs of the resources to describe, which are the requested IDs when there are any.
+// Like AWS, it fails when a requested resource doesn't exist.
+func selectIDs[T any](resources map[string]T, ids []*string, notFoundCode string) ([]string, error) {
+	if len(ids) == 0 {
+		return sortedKeys(resources), nil
+	}
+	for _, id := range aws.StringValueSlice(ids) {
+		if _, ok := resources[id]; !ok {
+			return nil, notFound(notFoundCode, id)
+		}
+	}
+	return aws.StringValueSlice(ids), nil
+}
+
+func (s *EC2Server) describeVpcs(input *ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutput, error) {
+	ids, err := selectIDs(s.client.vpcs, input.VpcIds, "InvalidVpcID.NotFound")
+	if err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeVpcsOutput{}
+	for _, id := range ids {
+		vpc := s.client.vpcs[id]
+		matched, err := matchesFilters(input.Filters, vpc.Tags, map[string][]string{
+			"vpc-id":     {id},
+			"cidr-block": {aws.StringValue(vpc.CidrBlock)},
+			"state":      {aws.StringValue(vpc.State)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			output.Vpcs = append(output.Vpcs, copyOf(vpc))
+		}
+	}
+	return output, nil
+}
+
+func (s *EC2Server) createVpc(input *ec2.CreateVpcInput) (*ec2.CreateVpcOutput, error) {
+	vpc, err := s.client.createVPC(input)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.CreateVpcOutput{Vpc: copyOf(vpc)}, nil
+}
+
+func (s *EC2Server) deleteVpc(input *ec2.DeleteVpcInput) (*ec2.DeleteVpcOutput, error) {
+	return &ec2.DeleteVpcOutput{}, s.client.deleteVPC(aws.StringValue(input.VpcId))
+}
+
+func (s *EC2Server) modifyVpcAttribute(input *ec2.ModifyVpcAttributeInput) (*ec2.ModifyVpcAttributeOutput, error) {
+	if _, ok := s.client.vpcs[aws.StringValue(input.VpcId)]; !ok {
+		return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(input.VpcId))
+	}
+	return &ec2.ModifyVpcAttributeOutput{}, nil
+}
+
+func (s *EC2Server) describeSubnets(input *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	ids, err := selectIDs(s.client.subnets, input.SubnetIds, "InvalidSubnetID.NotFound")
+	if err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeSubnetsOutput{}
+	for _, id := range ids {
+		subnet := s.client.subnets[id]
+		matched, err := matchesFilters(input.Filters, subnet.Tags, map[string][]string{
+			"subnet-id":         {id},
+			"vpc-id":            {aws.StringValue(subnet.VpcId)},
+			"cidr-block":        {aws.StringValue(subnet.CidrBlock)},
+			"availability-zone": {aws.StringValue(subnet.AvailabilityZone)},
+			"state":             {aws.StringValue(subnet.State)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			output.Subnets = append(output.Subnets, copyOf(subnet))
+		}
+	}
+	return output, nil
+}
+
+func (s *EC2Server) createSubnet(input *ec2.CreateSubnetInput) (*ec2.CreateSubnetOutput, error) {
+	subnet, err := s.client.createSubnet(aws.StringValue(input.VpcId), aws.StringValue(input.CidrBlock),
+		aws.StringValue(input.AvailabilityZone), tags(input.TagSpecifications, ec2.ResourceTypeSubnet))
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.CreateSubnetOutput{Subnet: copyOf(subnet)}, nil
+}
+
+func (s *EC2Server) deleteSubnet(input *ec2.DeleteSubnetInput) (*ec2.DeleteSubnetOutput, error) {
+	return &ec2.DeleteSubnetOutput{}, s.client.deleteSubnet(aws.StringValue(input.SubnetId))
+}
+
+// describeInstanceTypeOfferings returns the zones offering the instance types of the instance-type filter,
+// or of the instance types of Offerings when there is no such filter.
+func (s *EC2Server) describeInstanceTypeOfferings(input *ec2.DescribeInstanceTypeOfferingsInput) (*ec2.DescribeInstanceTypeOfferingsOutput, error) {
+	if locationType := aws.StringValue(input.LocationType); locationType != ec2.LocationTypeAvailabilityZone {
+		return nil, awserrNew("InvalidParameterValue", fmt.Sprintf("The location type %s is not supported", locationType))
+	}
+
+	instanceTypes := sortedKeys(s.client.Offerings)
+	for _, filter := range input.Filters {
+		if aws.StringValue(filter.Name) == "instance-type" {
+			instanceTypes = aws.StringValueSlice(filter.Values)
+		}
+	}
+
+	output := &ec2.DescribeInstanceTypeOfferingsOutput{}
+	for _, instanceType := range instanceTypes {
+		for _, zone := range s.client.offeredZones(instanceType) {
+			matched, err := matchesFilters(input.Filters, nil, map[string][]string{
+				"instance-type": {instanceType},
+				"location":      {zone},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				output.InstanceTypeOfferings = append(output.InstanceTypeOfferings, &ec2.InstanceTypeOffering{
+					InstanceType: aws.String(instanceType),
+					Location:     aws.String(zone),
+					LocationType: input.LocationType,
+				})
+			}
+		}
+	}
+	return output, nil
+}
+
+func (s *EC2Server) describeSecurityGroups(input *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	ids, err := selectIDs(s.client.securityGroups, input.GroupIds, "InvalidGroup.NotFound")
+	if err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeSecurityGroupsOutput{}
+	for _, id := range ids {
+		sg := s.client.securityGroups[id]
+		matched, err := matchesFilters(input.Filters, sg.Tags, map[string][]string{
+			"group-id":   {id},
+			"group-name": {aws.StringValue(sg.GroupName)},
+			"vpc-id":     {aws.StringValue(sg.VpcId)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			output.SecurityGroups = append(output.SecurityGroups, copyOf(sg))
+		}
+	}
+	return output, nil
+}
+
+func (s *EC2Server) createSecurityGroup(input *ec2.CreateSecurityGroupInput) (*ec2.CreateSecurityGroupOutput, error) {
+	sg, err := s.client.createSecurityGroup(aws.StringValue(input.VpcId), aws.StringValue(input.GroupName),
+		aws.StringValue(input.Description), tags(input.TagSpecifications, ec2.ResourceTypeSecurityGroup))
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.CreateSecurityGroupOutput{GroupId: sg.GroupId, Tags: copyOf(sg).Tags}, nil
+}
+
+func (s *EC2Server) deleteSecurityGroup(input *ec2.DeleteSecurityGroupInput) (*ec2.DeleteSecurityGroupOutput, error) {
+	return &ec2.DeleteSecurityGroupOutput{}, s.client.deleteSecurityGroup(aws.StringValue(input.GroupId))
+}
+
+func (s *EC2Server) authorizeSecurityGroupIngress(input *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	sg, err := s.securityGroup(input.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := authorize(sg.IpPermissions, input.IpPermissions)
+	if err != nil {
+		return nil, err
+	}
+	sg.IpPermissions = rules
+	return &ec2.AuthorizeSecurityGroupIngressOutput{Return: aws.Bool(true)}, nil
+}
+
+func (s *EC2Server) revokeSecurityGroupIngress(input *ec2.RevokeSecurityGroupIngressInput) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	sg, err := s.securityGroup(input.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	sg.IpPermissions = revoke(sg.IpPermissions, input.IpPermissions)
+	return &ec2.RevokeSecurityGroupIngressOutput{Return: aws.Bool(true)}, nil
+}
+
+func (s *EC2Server) authorizeSecurityGroupEgress(input *ec2.AuthorizeSecurityGroupEgressInput) (*ec2.AuthorizeSecurityGroupEgressOutput, error) {
+	sg, err := s.securityGroup(input.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := authorize(sg.IpPermissionsEgress, input.IpPermissions)
+	if err != nil {
+		return nil, err
+	}
+	sg.IpPermissionsEgress = rules
+	return &ec2.AuthorizeSecurityGroupEgressOutput{Return: aws.Bool(true)}, nil
+}
+
+func (s *EC2Server) revokeSecurityGroupEgress(input *ec2.RevokeSecurityGroupEgressInput) (*ec2.RevokeSecurityGroupEgressOutput, error) {
+	sg, err := s.securityGroup(input.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	sg.IpPermissionsEgress = revoke(sg.IpPermissionsEgress, input.IpPermissions)
+	return &ec2.RevokeSecurityGroupEgressOutput{Return: aws.Bool(true)}, nil
+}
+
+func (s *EC2Server) securityGroup(id *string) (*ec2.SecurityGroup, error) {
+	sg, ok := s.client.securityGroups[aws.StringValue(id)]
+	if !ok {
+		return nil, notFound("InvalidGroup.NotFound", aws.StringValue(id))
+	}
+	return sg, nil
+}
+
+func (s *EC2Server) describeInternetGateways(input *ec2.DescribeInternetGatewaysInput) (*ec2.DescribeInternetGatewaysOutput, error) {
+	ids, err := selectIDs(s.client.internetGateways, input.InternetGatewayIds, "InvalidInternetGatewayID.NotFound")
+	if err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeInternetGatewaysOutput{}
+	for _, id := range ids {
+		igw := s.client.internetGateways[id]
+		var vpcIDs []string
+		for _, attachment := range igw.Attachments {
+			vpcIDs = append(vpcIDs, aws.StringValue(attachment.VpcId))
+		}
+		matched, err := matchesFilters(input.Filters, igw.Tags, map[string][]string{
+			"internet-gateway-id": {id},
+			"attachment.vpc-id":   vpcIDs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			output.InternetGateways = append(output.InternetGateways, copyOf(igw))
+		}
+	}
+	return output, nil
+}
+
+func (s *EC2Server) createInternetGateway(input *ec2.CreateInternetGatewayInput) (*ec2.CreateInternetGatewayOutput, error) {
+	igw := &ec2.InternetGateway{
+		InternetGatewayId: aws.String(s.client.newID("igw")),
+		OwnerId:           aws.String(s.client.AccountID),
+		Tags:              tags(input.TagSpecifications, ec2.ResourceTypeInternetGateway),
+	}
+	s.client.internetGateways[*igw.InternetGatewayId] = igw
+	return &ec2.CreateInternetGatewayOutput{InternetGateway: copyOf(igw)}, nil
+}
+
+func (s *EC2Server) attachInternetGateway(input *ec2.AttachInternetGatewayInput) (*ec2.AttachInternetGatewayOutput, error) {
+	igw, err := s.internetGateway(input.InternetGatewayId)
+	if err != nil {
+		return nil, err
+	}
+	vpcID := aws.StringValue(input.VpcId)
+	if _, ok := s.client.vpcs[vpcID]; !ok {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+	if len(igw.Attachments) > 0 {
+		return nil, awserrNew("Resource.AlreadyAssociated", fmt.Sprintf("resource %s is already attached to network %s",
+			aws.StringValue(igw.InternetGatewayId), aws.StringValue(igw.Attachments[0].VpcId)))
+	}
+	if s.client.findInternetGateway(vpcID) != nil {
+		return nil, awserrNew("InvalidParameterValue", fmt.Sprintf("Network %s already has an internet gateway attached", vpcID))
+	}
+	igw.Attachments = []*ec2.InternetGatewayAttachment{{VpcId: aws.String(vpcID), State: aws.String("available")}}
+	return &ec2.AttachInternetGatewayOutput{}, nil
+}
+
+func (s *EC2Server) detachInternetGateway(input *ec2.DetachInternetGatewayInput) (*ec2.DetachInternetGatewayOutput, error) {
+	igw, err := s.internetGateway(input.InternetGatewayId)
+	if err != nil {
+		return nil, err
+	}
+	if len(igw.Attachments) == 0 || aws.StringValue(igw.Attachments[0].VpcId) != aws.StringValue(input.VpcId) {
+		return nil, awserrNew("Gateway.NotAttached", fmt.Sprintf("resource %s is not attached to network %s",
+			aws.StringValue(input.InternetGatewayId), aws.StringValue(input.VpcId)))
+	}
+	igw.Attachments = nil
+	return &ec2.DetachInternetGatewayOutput{}, nil
+}
+
+func (s *EC2Server) deleteInternetGateway(input *ec2.DeleteInternetGatewayInput) (*ec2.DeleteInternetGatewayOutput, error) {
+	igw, err := s.internetGateway(input.InternetGatewayId)
+	if err != nil {
+		return nil, err
+	}
+	if len(igw.Attachments) > 0 {
+		return nil, dependencyViolation(aws.StringValue(igw.InternetGatewayId), aws.StringValue(igw.Attachments[0].VpcId))
+	}
+	delete(s.client.internetGateways, aws.StringValue(igw.InternetGatewayId))
+	return &ec2.DeleteInternetGatewayOutput{}, nil
+}
+
+func (s *EC2Server) internetGateway(id *string) (*ec2.InternetGateway, error) {
+	igw, ok := s.client.internetGateways[aws.StringValue(id)]
+	if !ok {
+		return nil, notFound("InvalidInternetGatewayID.NotFound", aws.StringValue(id))
+	}
+	return igw, nil
+}
+
+func (s *EC2Server) describeRouteTables(input *ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error) {
+	ids, err := selectIDs(s.client.routeTables, input.RouteTableIds, "InvalidRouteTableID.NotFound")
+	if err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeRouteTablesOutput{}
+	for _, id := range ids {
+		routeTable := s.client.routeTables[id]
+		var subnetIDs, associationIDs []string
+		for _, association := range routeTable.Associations {
+			subnetIDs = append(subnetIDs, aws.StringValue(association.SubnetId))
+			associationIDs = append(associationIDs, aws.StringValue(association.RouteTableAssociationId))
+		}
+		matched, err := matchesFilters(input.Filters, routeTable.Tags, map[string][]string{
+			"route-table-id":                         {id},
+			"vpc-id":                                 {aws.StringValue(routeTable.VpcId)},
+			"association.subnet-id":                  subnetIDs,
+			"association.route-table-association-id": associationIDs,
+			"association.main":                       {strconv.FormatBool(isMainRouteTable(routeTable))},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			output.RouteTables = append(output.RouteTables, copyOf(routeTable))
+		}
+	}
+	return output, nil
+}
+
+func (s *EC2Server) createRouteTable(input *ec2.CreateRouteTableInput) (*ec2.CreateRouteTableOutput, error) {
+	vpc, ok := s.client.vpcs[aws.StringValue(input.VpcId)]
+	if !ok {
+		return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(input.VpcId))
+	}
+	routeTable := &ec2.RouteTable{
+		RouteTableId: aws.String(s.client.newID("rtb")),
+		VpcId:        vpc.VpcId,
+		OwnerId:      vpc.OwnerId,
+		Routes:       []*ec2.Route{localRoute(vpc)},
+		Tags:         tags(input.TagSpecifications, ec2.ResourceTypeRouteTable),
+	}
+	s.client.routeTables[*routeTable.RouteTableId] = routeTable
+	return &ec2.CreateRouteTableOutput{RouteTable: copyOf(routeTable)}, nil
+}
+
+func (s *EC2Server) deleteRouteTable(input *ec2.DeleteRouteTableInput) (*ec2.DeleteRouteTableOutput, error) {
+	routeTable, err := s.routeTable(input.RouteTableId)
+	if err != nil {
+		return nil, err
+	}
+	if len(routeTable.Associations) > 0 {
+		return nil, dependencyViolation(aws.StringValue(routeTable.RouteTableId), aws.StringValue(routeTable.Associations[0].RouteTableAssociationId))
+	}
+	delete(s.client.routeTables, aws.StringValue(routeTable.RouteTableId))
+	return &ec2.DeleteRouteTableOutput{}, nil
+}
+
+func (s *EC2Server) createRoute(input *ec2.CreateRouteInput) (*ec2.CreateRouteOutput, error) {
+	routeTable, err := s.routeTable(input.RouteTableId)
+	if err != nil {
+		return nil, err
+	}
+	destination := aws.StringValue(input.DestinationCidrBlock)
+	for _, route := range routeTable.Routes {
+		if aws.StringValue(route.DestinationCidrBlock) == destination {
+			return nil, awserrNew("RouteAlreadyExists", fmt.Sprintf("The route identified by %s already exists.", destination))
+		}
+	}
+	route, err := s.client.newRoute(destination, aws.StringValue(input.GatewayId), aws.StringValue(input.NatGatewayId))
+	if err != nil {
+		return nil, err
+	}
+	routeTable.Routes = append(routeTable.Routes, route)
+	return &ec2.CreateRouteOutput{Return: aws.Bool(true)}, nil
+}
+
+func (s *EC2Server) replaceRoute(input *ec2.ReplaceRouteInput) (*ec2.ReplaceRouteOutput, error) {
+	routeTable, err := s.routeTable(input.RouteTableId)
+	if err != nil {
+		return nil, err
+	}
+	destination := aws.StringValue(input.DestinationCidrBlock)
+	for i, existing := range routeTable.Routes {
+		if aws.StringValue(existing.DestinationCidrBlock) != destination {
+			continue
+		}
+		route, err := s.client.newRoute(destination, aws.StringValue(input.GatewayId), aws.StringValue(input.NatGatewayId))
+		if err != nil {
+			return nil, err
+		}
+		routeTable.Routes[i] = route
+		return &ec2.ReplaceRouteOutput{}, nil
+	}
+	return nil, awserrNew("InvalidRoute.NotFound", fmt.Sprintf("no route with destination-cidr-block %s in route table %s",
+		destination, aws.StringValue(input.RouteTableId)))
+}
+
+func (s *EC2Server) associateRouteTable(input *ec2.AssociateRouteTableInput) (*ec2.AssociateRouteTableOutput, error) {
+	routeTable, err := s.routeTable(input.RouteTableId)
+	if err != nil {
+		return nil, err
+	}
+	subnetID := aws.StringValue(input.SubnetId)
+	if _, ok := s.client.subnets[subnetID]; !ok {
+		return nil, notFound("InvalidSubnetID.NotFound", subnetID)
+	}
+	for _, other := range s.client.routeTables {
+		for _, association := range other.Associations {
+			if aws.StringValue(association.SubnetId) == subnetID {
+				return nil, awserrNew("Resource.AlreadyAssociated", fmt.Sprintf("the specified association for route table %s conflicts with an existing association",
+					aws.StringValue(routeTable.RouteTableId)))
+			}
+		}
+	}
+
+	association := &ec2.RouteTableAssociation{
+		RouteTableAssociationId: aws.String(s.client.newID("rtbassoc")),
+		RouteTableId:            routeTable.RouteTableId,
+		SubnetId:                aws.String(subnetID),
+		Main:                    aws.Bool(false),
+	}
+	routeTable.Associations = append(routeTable.Associations, association)
+	return &ec2.AssociateRouteTableOutput{AssociationId: association.RouteTableAssociationId}, nil
+}
+
+func (s *EC2Server) replaceRouteTableAssociation(input *ec2.ReplaceRouteTableAssociationInput) (*ec2.ReplaceRouteTableAssociationOutput, error) {
+	routeTable, err := s.routeTable(input.RouteTableId)
+	if err != nil {
+		return nil, err
+	}
+	association, err := s.removeAssociation(aws.StringValue(input.AssociationId))
+	if err != nil {
+		return nil, err
+	}
+	association.RouteTableAssociationId = aws.String(s.client.newID("rtbassoc"))
+	association.RouteTableId = routeTable.RouteTableId
+	routeTable.Associations = append(routeTable.Associations, association)
+	return &ec2.ReplaceRouteTableAssociationOutput{NewAssociationId: association.RouteTableAssociationId}, nil
+}
+
+func (s *EC2Server) disassociateRouteTable(input *ec2.DisassociateRouteTableInput) (*ec2.DisassociateRouteTableOutput, error) {
+	for _, routeTable := range s.client.routeTables {
+		for _, association := range routeTable.Associations {
+			if aws.StringValue(association.RouteTableAssociationId) == aws.StringValue(input.AssociationId) && aws.BoolValue(association.Main) {
+				return nil, awserrNew("InvalidParameterValue", "cannot disassociate the main route table association")
+			}
+		}
+	}
+	if _, err := s.removeAssociation(aws.StringValue(input.AssociationId)); err != nil {
+		return nil, err
+	}
+	return &ec2.DisassociateRouteTableOutput{}, nil
+}
+
+// removeAssociation removes the route table association with the ID from its route table and returns it.
+func (s *EC2Server) removeAssociation(id string) (*ec2.RouteTableAssociation, error) {
+	for _, routeTable := range s.client.routeTables {
+		for i, association := range routeTable.Associations {
+			if aws.StringValue(association.RouteTableAssociationId) == id {
+				routeTable.Associations = append(routeTable.Associations[:i], routeTable.Associations[i+1:]...)
+				return association, nil
+			}
+		}
+	}
+	return nil, notFound("InvalidAssociationID.NotFound", id)
+}
+
+func (s *EC2Server) routeTable(id *string) (*ec2.RouteTable, error) {
+	routeTable, ok := s.client.routeTables[aws.StringValue(id)]
+	if !ok {
+		return nil, notFound("InvalidRouteTableID.NotFound", aws.StringValue(id))
+	}
+	return routeTable, nil
+}
+
+func (s *EC2Server) describeNatGateways(input *ec2.DescribeNatGatewaysInput) (*ec2.DescribeNatGatewaysOutput, error) {
+	s.client.advance()
+	ids, err := selectIDs(s.client.natGateways, input.NatGatewayIds, "NatGatewayNotFound")
+	if err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeNatGatewaysOutput{}
+	for _, id := range ids {
+		natGateway := s.client.natGateways[id]
+		matched, err := matchesFilters(input.Filter, natGateway.Tags, map[string][]string{
+			"nat-gateway-id": {id},
+			"vpc-id":         {aws.StringValue(natGateway.VpcId)},
+			"subnet-id":      {aws.StringValue(natGateway.SubnetId)},
+			"state":          {aws.StringValue(natGateway.State)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			output.NatGateways = append(output.NatGateways, copyOf(natGateway))
+		}
+	}
+	return output, nil
+}
+
+func (s *EC2Server) createNatGateway(input *ec2.CreateNatGatewayInput) (*ec2.CreateNatGatewayOutput, error) {
+	natGateway, err := s.client.createNATGateway(aws.StringValue(input.SubnetId), aws.StringValue(input.AllocationId),
+		tags(input.TagSpecifications, ec2.ResourceTypeNatgateway))
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.CreateNatGatewayOutput{NatGateway: copyOf(natGateway)}, nil
+}
+
+func (s *EC2Server) deleteNatGateway(input *ec2.DeleteNatGatewayInput) (*ec2.DeleteNatGatewayOutput, error) {
+	natGateway, ok := s.client.natGateways[aws.StringValue(input.NatGatewayId)]
+	if !ok {
+		return nil, awserrNew("NatGatewayNotFound", fmt.Sprintf("The Nat Gateway %s was not found", aws.StringValue(input.NatGatewayId)))
+	}
+	if aws.StringValue(natGateway.State) != ec2.NatGatewayStateDeleted {
+		natGateway.State = aws.String(ec2.NatGatewayStateDeleting)
+	}
+	return &ec2.DeleteNatGatewayOutput{NatGatewayId: natGateway.NatGatewayId}, nil
+}
+
+func (s *EC2Server) describeAddresses(input *ec2.DescribeAddressesInput) (*ec2.DescribeAddressesOutput, error) {
+	s.client.advance()
+	ids, err := selectIDs(s.client.addresses, input.AllocationIds, "InvalidAllocationID.NotFound")
+	if err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeAddressesOutput{}
+	for _, id := range ids {
+		address := s.client.addresses[id]
+		matched, err := matchesFilters(input.Filters, address.Tags, map[string][]string{
+			"allocation-id": {id},
+			"public-ip":     {aws.StringValue(address.PublicIp)},
+			"domain":        {aws.StringValue(address.Domain)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			output.Addresses = append(output.Addresses, copyOf(address))
+		}
+	}
+	return output, nil
+}
+
+func (s *EC2Server) allocateAddress(input *ec2.AllocateAddressInput) (*ec2.AllocateAddressOutput, error) {
+	address := s.client.allocateAddress(tags(input.TagSpecifications, ec2.ResourceTypeElasticIp))
+	return &ec2.AllocateAddressOutput{
+		AllocationId: address.AllocationId,
+		PublicIp:     address.PublicIp,
+		Domain:       address.Domain,
+	}, nil
+}
+
+func (s *EC2Server) releaseAddress(input *ec2.ReleaseAddressInput) (*ec2.ReleaseAddressOutput, error) {
+	id := aws.StringValue(input.AllocationId)
+	address, ok := s.client.addresses[id]
+	if !ok {
+		return nil, notFound("InvalidAllocationID.NotFound", id)
+	}
+	if address.AssociationId != nil {
+		return nil, awserrNew("InvalidIPAddress.InUse", fmt.Sprintf("Address %s is in use.", aws.StringValue(address.PublicIp)))
+	}
+	delete(s.client.addresses, id)
+	return &ec2.ReleaseAddressOutput{}, nil
+}
+
+func (s *EC2Server) describeVpcEndpoints(input *ec2.DescribeVpcEndpointsInput) (*ec2.DescribeVpcEndpointsOutput, error) {
+	s.client.advance()
+	ids, err := selectIDs(s.client.vpcEndpoints, input.VpcEndpointIds, "InvalidVpcEndpointId.NotFound")
+	if err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeVpcEndpointsOutput{}
+	for _, id := range ids {
+		endpoint := s.client.vpcEndpoints[id]
+		matched, err := matchesFilters(input.Filters, endpoint.Tags, map[string][]string{
+			"vpc-endpoint-id":    {id},
+			"vpc-id":             {aws.StringValue(endpoint.VpcId)},
+			"service-name":       {aws.StringValue(endpoint.ServiceName)},
+			"vpc-endpoint-state": {aws.StringValue(endpoint.State)},
+			"vpc-endpoint-type":  {aws.StringValue(endpoint.VpcEndpointType)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			output.VpcEndpoints = append(output.VpcEndpoints, copyOf(endpoint))
+		}
+	}
+	return output, nil
+}
+
+func (s *EC2Server) createVpcEndpoint(input *ec2.CreateVpcEndpointInput) (*ec2.CreateVpcEndpointOutput, error) {
+	endpoint, err := s.client.createVPCEndpoint(input, tags(input.TagSpecifications, ec2.ResourceTypeVpcEndpoint))
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.CreateVpcEndpointOutput{VpcEndpoint: copyOf(endpoint)}, nil
+}
+
+// deleteVpcEndpoints reports missing endpoints as unsuccessful items instead of failing, like AWS does.
+func (s *EC2Server) deleteVpcEndpoints(input *ec2.DeleteVpcEndpointsInput) (*ec2.DeleteVpcEndpointsOutput, error) {
+	output := &ec2.DeleteVpcEndpointsOutput{}
+	for _, id := range aws.StringValueSlice(input.VpcEndpointIds) {
+		endpoint, ok := s.client.vpcEndpoints[id]
+		if !ok || aws.StringValue(endpoint.State) == "deleted" {
+			output.Unsuccessful = append(output.Unsuccessful, &ec2.UnsuccessfulItem{
+				ResourceId: aws.String(id),
+				Error: &ec2.UnsuccessfulItemError{
+					Code:    aws.String("InvalidVpcEndpoint.NotFound"),
+					Message: aws.String(fmt.Sprintf("The Vpc Endpoint Id '%s' does not exist", id)),
+				},
+			})
+			continue
+		}
+		endpoint.State = aws.String("deleting")
+	}
+	return output, nil
+}
+
+func (s *EC2Server) describeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	s.client.advance()
+	ids, err := selectIDs(s.client.instances, input.InstanceIds, "InvalidInstanceID.NotFound")
+	if err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeInstancesOutput{}
+	for _, id := range ids {
+		instance := s.client.instances[id]
+		matched, err := matchesFilters(input.Filters, instance.Tags, map[string][]string{
+			"instance-id":         {id},
+			"instance-state-name": {aws.StringValue(instance.State.Name)},
+			"instance-type":       {aws.StringValue(instance.InstanceType)},
+			"image-id":            {aws.StringValue(instance.ImageId)},
+			"subnet-id":           {aws.StringValue(instance.SubnetId)},
+			"vpc-id":              {aws.StringValue(instance.VpcId)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			output.Reservations = append(output.Reservations, s.reservation(instance))
+		}
+	}
+	return output, nil
+}
+
+// runInstances launches the instances in the subnet of the first network interface, or in the subnet of the request.
+func (s *EC2Server) runInstances(input *ec2.RunInstancesInput) (*ec2.Reservation, error) {
+	params := awsforge.CreateInstanceParams{
+		AmiID:              aws.StringValue(input.ImageId),
+		InstanceType:       aws.StringValue(input.InstanceType),
+		SubnetID:           aws.StringValue(input.SubnetId),
+		Userdata:           aws.StringValue(input.UserData),
+		IAMInstanceProfile: iamInstanceProfileName(input.IamInstanceProfile),
+	}
+	sgIDs := aws.StringValueSlice(input.SecurityGroupIds)
+	if len(input.NetworkInterfaces) > 0 {
+		networkInterface := input.NetworkInterfaces[0]
+		if networkInterface.SubnetId != nil {
+			params.SubnetID = aws.StringValue(networkInterface.SubnetId)
+		}
+		params.PublicIP = aws.BoolValue(networkInterface.AssociatePublicIpAddress)
+		sgIDs = append(sgIDs, aws.StringValueSlice(networkInterface.Groups)...)
+	}
+	if len(sgIDs) > 0 {
+		params.SecurityGroupID = sgIDs[0]
+		params.AdditionalSecurityGroupIDs = sgIDs[1:]
+	}
+	if input.Placement != nil {
+		params.AvailabilityZone = aws.StringValue(input.Placement.AvailabilityZone)
+	}
+	if options := input.InstanceMarketOptions; options != nil && aws.StringValue(options.MarketType) == ec2.MarketTypeSpot {
+		params.SpotOptions = &infrav1.SpotOptions{}
+		if options.SpotOptions != nil {
+			params.SpotOptions.MaxPrice = options.SpotOptions.MaxPrice
+			params.SpotOptions.InterruptionBehavior = aws.StringValue(options.SpotOptions.InstanceInterruptionBehavior)
+		}
+	}
+	if params.AmiID == "" {
+		return nil, awserrNew("MissingParameter", "The request must contain the parameter ImageId")
+	}
+	if params.InstanceType == "" {
+		params.InstanceType = ec2.InstanceTypeM1Small
+	}
+
+	reservation := &ec2.Reservation{
+		ReservationId: aws.String(s.client.newID("r")),
+		OwnerId:       aws.String(s.client.AccountID),
+	}
+	for range max(aws.Int64Value(input.MinCount), 1) {
+		instance, err := s.client.runInstance(params, tags(input.TagSpecifications, ec2.ResourceTypeInstance))
+		if err != nil {
+			return nil, err
+		}
+		reservation.Instances = append(reservation.Instances, copyOf(instance))
+	}
+	return reservation, nil
+}
+
+// iamInstanceProfileName returns the name of the instance profile, given by name or by ARN.
+func iamInstanceProfileName(profile *ec2.IamInstanceProfileSpecification) string {
+	if profile == nil {
+		return ""
+	}
+	if profile.Name != nil {
+		return aws.StringValue(profile.Name)
+	}
+	return path.Base(aws.StringValue(profile.Arn))
+}
+
+func (s *EC2Server) terminateInstances(input *ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error) {
+	output := &ec2.TerminateInstancesOutput{}
+	for _, id := range aws.StringValueSlice(input.InstanceIds) {
+		instance, ok := s.client.instances[id]
+		if !ok {
+			return nil, notFound("InvalidInstanceID.NotFound", id)
+		}
+		previousState := copyOf(instance.State)
+		if err := s.client.terminateInstance(id); err != nil {
+			return nil, err
+		}
+		output.TerminatingInstances = append(output.TerminatingInstances, &ec2.InstanceStateChange{
+			InstanceId:    aws.String(id),
+			PreviousState: previousState,
+			CurrentState:  copyOf(instance.State),
+		})
+	}
+	return output, nil
+}
+
+func (s *EC2Server) cancelSpotInstanceRequests(input *ec2.CancelSpotInstanceRequestsInput) (*ec2.CancelSpotInstanceRequestsOutput, error) {
+	output := &ec2.CancelSpotInstanceRequestsOutput{}
+	for _, id := range aws.StringValueSlice(input.SpotInstanceRequestIds) {
+		if _, ok := s.client.spotRequests[id]; !ok {
+			return nil, notFound("InvalidSpotInstanceRequestID.NotFound", id)
+		}
+		s.client.spotRequests[id] = ec2.SpotInstanceStateCancelled
+		output.CancelledSpotInstanceRequests = append(output.CancelledSpotInstanceRequests, &ec2.CancelledSpotInstanceRequest{
+			SpotInstanceRequestId: aws.String(id),
+			State:                 aws.String(ec2.CancelSpotInstanceRequestStateCancelled),
+		})
+	}
+	return output, nil
+}
+
+func (s *EC2Server) reservation(instance *ec2.Instance) *ec2.Reservation {
+	return &ec2.Reservation{
+		ReservationId: aws.String("r-" + aws.StringValue(instance.InstanceId)[len("i-"):]),
+		OwnerId:       aws.String(s.client.AccountID),
+		Instances:     []*ec2.Instance{copyOf(instance)},
+	}
+}
+
+func (s *EC2Server) describeImages(input *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	s.client.advance()
+	ids, err := selectIDs(s.client.images, input.ImageIds, "InvalidAMIID.NotFound")
+	if err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeImagesOutput{}
+	for _, id := range ids {
+		image := s.client.images[id]
+		if len(input.Owners) > 0 && !s.client.ownedBy(image, aws.StringValueSlice(input.Owners)) {
+			continue
+		}
+		matched, err := matchesFilters(input.Filters, image.Tags, map[string][]string{
+			"image-id":     {id},
+			"name":         {aws.StringValue(image.Name)},
+			"state":        {aws.StringValue(image.State)},
+			"architecture": {aws.StringValue(image.Architecture)},
+			"owner-id":     {aws.StringValue(image.OwnerId)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			output.Images = append(output.Images, copyOf(image))
+		}
+	}
+	return output, nil
+}
+
+func (s *EC2Server) createImage(input *ec2.CreateImageInput) (*ec2.CreateImageOutput, error) {
+	image, err := s.client.createImage(aws.StringValue(input.InstanceId), aws.StringValue(input.Name), aws.StringValue(input.Description),
+		tags(input.TagSpecifications, ec2.ResourceTypeImage), tags(input.TagSpecifications, ec2.ResourceTypeSnapshot))
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.CreateImageOutput{ImageId: image.ImageId}, nil
+}
+
+func (s *EC2Server) deregisterImage(input *ec2.DeregisterImageInput) (*ec2.DeregisterImageOutput, error) {
+	id := aws.StringValue(input.ImageId)
+	if _, ok := s.client.images[id]; !ok {
+		return nil, notFound("InvalidAMIID.NotFound", id)
+	}
+	delete(s.client.images, id)
+	return &ec2.DeregisterImageOutput{}, nil
+}
+
+func (s *EC2Server) describeSnapshots(input *ec2.DescribeSnapshotsInput) (*ec2.DescribeSnapshotsOutput, error) {
+	ids, err := selectIDs(s.client.snapshots, input.SnapshotIds, "InvalidSnapshot.NotFound")
+	if err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeSnapshotsOutput{}
+	for _, id := range ids {
+		snapshot := s.client.snapshots[id]
+		matched, err := matchesFilters(input.Filters, snapshot.Tags, map[string][]string{
+			"snapshot-id": {id},
+			"volume-id":   {aws.StringValue(snapshot.VolumeId)},
+			"status":      {aws.StringValue(snapshot.State)},
+			"owner-id":    {aws.StringValue(snapshot.OwnerId)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			output.Snapshots = append(output.Snapshots, copyOf(snapshot))
+		}
+	}
+	return output, nil
+}
+
+func (s *EC2Server) deleteSnapshot(input *ec2.DeleteSnapshotInput) (*ec2.DeleteSnapshotOutput, error) {
+	if err := s.client.deleteSnapshot(aws.StringValue(input.SnapshotId)); err != nil {
+		return nil, err
+	}
+	return &ec2.DeleteSnapshotOutput{}, nil
+}
+
+func (s *EC2Server) modifyImageAttribute(input *ec2.ModifyImageAttributeInput) (*ec2.ModifyImageAttributeOutput, error) {
+	if input.LaunchPermission == nil || len(input.LaunchPermission.Remove) > 0 {
+		return nil, awserrNew("InvalidParameterValue", "only adding launch permissions is supported")
+	}
+	if err := s.client.addLaunchPermissions(aws.StringValue(input.ImageId), input.LaunchPermission.Add); err != nil {
+		return nil, err
+	}
+	return &ec2.ModifyImageAttributeOutput{}, nil
+}
+
+func (s *EC2Server) resetImageAttribute(input *ec2.ResetImageAttributeInput) (*ec2.ResetImageAttributeOutput, error) {
+	id := aws.StringValue(input.ImageId)
+	if _, ok := s.client.images[id]; !ok {
+		return nil, notFound("InvalidAMIID.NotFound", id)
+	}
+	delete(s.client.launchPermissions, id)
+	return &ec2.ResetImageAttributeOutput{}, nil
+}
+
+func (s *EC2Server) modifySnapshotAttribute(input *ec2.ModifySnapshotAttributeInput) (*ec2.ModifySnapshotAttributeOutput, error) {
+	if aws.StringValue(input.Attribute) != ec2.SnapshotAttributeNameCreateVolumePermission ||
+		input.CreateVolumePermission == nil || len(input.CreateVolumePermission.Remove) > 0 {
+		return nil, awserrNew("InvalidParameterValue", "only adding create volume permissions is supported")
+	}
+	if err := s.client.addVolumePermissions(aws.StringValue(input.SnapshotId), input.CreateVolumePermission.Add); err != nil {
+		return nil, err
+	}
+	return &ec2.ModifySnapshotAttributeOutput{}, nil
+}
+
+func (s *EC2Server) resetSnapshotAttribute(input *ec2.ResetSnapshotAttributeInput) (*ec2.ResetSnapshotAttributeOutput, error) {
+	id := aws.StringValue(input.SnapshotId)
+	if _, ok := s.client.snapshots[id]; !ok {
+		return nil, notFound("InvalidSnapshot.NotFound", id)
+	}
+	delete(s.client.volumePermissions, id)
+	return &ec2.ResetSnapshotAttributeOutput{}, nil
+}
@@ -18,22 +18,52 @@ package aws
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/pkg/errors"
 )
 
+const (
+	// DefaultVPCCIDR is the CIDR block of VPCs created by Forge.
+	DefaultVPCCIDR = "10.0.0.0/16"
+	// DefaultSubnetPrefixLength is the prefix length of subnets created by Forge.
+	DefaultSubnetPrefixLength = 24
+	// DefaultRoleSessionName is the name of the sessions of assumed roles.
+	DefaultRoleSessionName = "forge-provider-aws"
+
+	// minVPCPrefixLength and maxPrefixLength are the VPC and subnet sizes supported by AWS.
+	minVPCPrefixLength = 16
+	maxPrefixLength    = 28
+)
+
+// findAvailableCIDR returns the first block of the given size in the VPC that doesn't overlap the used CIDRs.
 func findAvailableCIDR(vpcCIDR string, usedCIDRs []string, subnetMask int) (string, error) {
 	_, vpcIPNet, err := net.ParseCIDR(vpcCIDR)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse VPC CIDR %s: %w", vpcCIDR, err)
 	}
 
-	// Iterate through potential subnets in the VPC CIDR
-	for ip := vpcIPNet.IP.Mask(vpcIPNet.Mask); vpcIPNet.Contains(ip); incrementIP(ip) {
+	vpcPrefixLength, bits := vpcIPNet.Mask.Size()
+	if bits != 32 {
+		return "", errors.Errorf("VPC CIDR %s is not an IPv4 CIDR block", vpcCIDR)
+	}
+	if subnetMask < vpcPrefixLength || subnetMask > maxPrefixLength {
+		return "", errors.Errorf("subnet prefix length /%d must be between the VPC prefix length /%d and /%d", subnetMask, vpcPrefixLength, maxPrefixLength)
+	}
+
+	// Iterate through the subnet sized blocks of the VPC CIDR
+	blockSize := 1 << (32 - subnetMask)
+	blocks := 1 << (subnetMask - vpcPrefixLength)
+	ip := vpcIPNet.IP.To4().Mask(vpcIPNet.Mask)
+	for i := 0; i < blocks; i++ {
 		// Create a candidate CIDR block
 		candidateCIDR := fmt.Sprintf("%s/%d", ip.String(), subnetMask)
 
@@ -46,11 +76,82 @@ func findAvailableCIDR(vpcCIDR string, usedCIDRs []string, subnetMask int) (stri
 			// Found an available CIDR
 			return candidateCIDR, nil
 		}
+
+		for j := 0; j < blockSize; j++ {
+			incrementIP(ip)
+		}
 	}
 
 	return "", errors.New("no available CIDR block found")
 }
 
+// ValidateNetworkCIDRs checks the CIDR blocks of the network spec without calling AWS.
+// An empty VPC CIDR means the VPC already exists and its range is not known yet.
+func ValidateNetworkCIDRs(vpcCIDR, subnetCIDR string, subnetPrefixLength int) error {
+	vpcPrefixLength := minVPCPrefixLength
+	if vpcCIDR != "" {
+		var err error
+		vpcPrefixLength, err = ipv4PrefixLength(vpcCIDR)
+		if err != nil {
+			return errors.Wrap(err, "invalid VPC CIDR")
+		}
+		if vpcPrefixLength < minVPCPrefixLength || vpcPrefixLength > maxPrefixLength {
+			return errors.Errorf("VPC CIDR %s must have a prefix length between /%d and /%d", vpcCIDR, minVPCPrefixLength, maxPrefixLength)
+		}
+	}
+
+	if subnetCIDR == "" {
+		if subnetPrefixLength < vpcPrefixLength || subnetPrefixLength > maxPrefixLength {
+			return errors.Errorf("subnet prefix length /%d must be between the VPC prefix length /%d and /%d", subnetPrefixLength, vpcPrefixLength, maxPrefixLength)
+		}
+		return nil
+	}
+
+	prefixLength, err := ipv4PrefixLength(subnetCIDR)
+	if err != nil {
+		return errors.Wrap(err, "invalid subnet CIDR")
+	}
+	if prefixLength < minVPCPrefixLength || prefixLength > maxPrefixLength {
+		return errors.Errorf("subnet CIDR %s must have a prefix length between /%d and /%d", subnetCIDR, minVPCPrefixLength, maxPrefixLength)
+	}
+	if vpcCIDR != "" {
+		return ValidateSubnetInVPC(vpcCIDR, subnetCIDR)
+	}
+	return nil
+}
+
+// ValidateSubnetInVPC checks that the subnet CIDR is a canonical block within the VPC CIDR.
+func ValidateSubnetInVPC(vpcCIDR, subnetCIDR string) error {
+	vpcFirst, vpcLast, err := parseCIDR(vpcCIDR)
+	if err != nil {
+		return err
+	}
+	subnetFirst, subnetLast, err := parseCIDR(subnetCIDR)
+	if err != nil {
+		return err
+	}
+
+	if ip, ipNet, _ := net.ParseCIDR(subnetCIDR); !ip.Equal(subnetFirst) {
+		return errors.Errorf("subnet CIDR %s is not aligned to its prefix length, use %s", subnetCIDR, ipNet.String())
+	}
+	if bytesCompare(subnetFirst.To16(), vpcFirst.To16()) < 0 || bytesCompare(subnetLast.To16(), vpcLast.To16()) > 0 {
+		return errors.Errorf("subnet CIDR %s is not within VPC CIDR %s", subnetCIDR, vpcCIDR)
+	}
+	return nil
+}
+
+func ipv4PrefixLength(cidr string) (int, error) {
+	ip, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse CIDR %s: %w", cidr, err)
+	}
+	if ip.To4() == nil {
+		return 0, errors.Errorf("CIDR %s is not an IPv4 CIDR block", cidr)
+	}
+	prefixLength, _ := ipNet.Mask.Size()
+	return prefixLength, nil
+}
+
 // incrementIP increments an IP address in-place.
 func incrementIP(ip net.IP) {
 	for j := len(ip) - 1; j >= 0; j-- {
@@ -110,3 +211,54 @@ func GetNameFromTags(tags []*ec2.Tag) string {
 	}
 	return ""
 }
+
+// RoleNameFromARN returns the role name of an IAM role ARN (arn:aws:iam::<account>:role/<path>/<name>).
+// Values that are not ARNs are returned unchanged.
+func RoleNameFromARN(role string) string {
+	if !strings.HasPrefix(role, "arn:") {
+		return role
+	}
+	return role[strings.LastIndex(role, "/")+1:]
+}
+
+// checkIPURL returns the public IP address the request originates from.
+const checkIPURL = "https://checkip.amazonaws.com"
+
+// DetectEgressIP returns the public IP address the controller uses to reach the internet.
+// The request goes through the HTTP client of the session, i.e., through the proxy and with the CA bundle of the endpoints.
+func (s *AWSClient) DetectEgressIP(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkIPURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	httpClient := http.DefaultClient
+	if s.session != nil && s.session.Config.HTTPClient != nil {
+		httpClient = s.session.Config.HTTPClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to detect egress IP")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("failed to detect egress IP: unexpected status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read egress IP")
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil || ip.To4() == nil {
+		return "", errors.Errorf("failed to detect egress IP: invalid address %q", strings.TrimSpace(string(body)))
+	}
+
+	return ip.String(), nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/iam"
+	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
+	"github.com/pkg/errors"
+)
+
+// instanceProfileWaiterMaxAttempts bounds the wait for a new instance profile, IAM is polled every second.
+const instanceProfileWaiterMaxAttempts = 10
+
+// FindInstanceProfileForRole returns the first instance profile that contains the given role, or nil if there is none.
+// The role can be given either by name or by ARN.
+func (s *AWSClient) FindInstanceProfileForRole(ctx context.Context, role string) (*iam.InstanceProfile, error) {
+	output, err := s.IAM.ListInstanceProfilesForRoleWithContext(ctx, &iam.ListInstanceProfilesForRoleInput{
+		RoleName: aws.String(RoleNameFromARN(role)),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list instance profiles for role %s", role)
+	}
+
+	if len(output.InstanceProfiles) > 0 {
+		return output.InstanceProfiles[0], nil
+	}
+
+	return nil, nil
+}
+
+// CreateInstanceProfileForRole creates a forge-managed instance profile and adds the given role to it.
+// An existing profile with the same name is reused, so the call is safe to repeat.
+func (s *AWSClient) CreateInstanceProfileForRole(ctx context.Context, profileName, role string) (*iam.InstanceProfile, error) {
+	roleName := RoleNameFromARN(role)
+
+	profile, err := s.findInstanceProfile(ctx, profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile == nil {
+		output, err := s.IAM.CreateInstanceProfileWithContext(ctx, &iam.CreateInstanceProfileInput{
+			InstanceProfileName: aws.String(profileName),
+			Tags: []*iam.Tag{
+				{Key: aws.String("Name"), Value: aws.String(profileName)},
+				{Key: aws.String("forge-managed"), Value: aws.String("true")},
+			},
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create instance profile %s", profileName)
+		}
+		profile = output.InstanceProfile
+
+		// EC2 rejects instance profiles that IAM does not return yet
+		err = s.IAM.WaitUntilInstanceProfileExistsWithContext(ctx, &iam.GetInstanceProfileInput{
+			InstanceProfileName: aws.String(profileName),
+		}, request.WithWaiterMaxAttempts(instanceProfileWaiterMaxAttempts))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to wait for instance profile %s", profileName)
+		}
+	}
+
+	for _, r := range profile.Roles {
+		if aws.StringValue(r.RoleName) == roleName {
+			return profile, nil
+		}
+	}
+
+	_, err = s.IAM.AddRoleToInstanceProfileWithContext(ctx, &iam.AddRoleToInstanceProfileInput{
+		InstanceProfileName: aws.String(profileName),
+		RoleName:            aws.String(roleName),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to add role %s to instance profile %s", roleName, profileName)
+	}
+
+	return profile, nil
+}
+
+// IsManagedInstanceProfile checks if the instance profile is tagged as managed by forge.
+func (s *AWSClient) IsManagedInstanceProfile(ctx context.Context, profileName string) (bool, error) {
+	output, err := s.IAM.ListInstanceProfileTagsWithContext(ctx, &iam.ListInstanceProfileTagsInput{
+		InstanceProfileName: aws.String(profileName),
+	})
+	if err != nil {
+		if awserrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "failed to list instance profile tags")
+	}
+
+	for _, tag := range output.Tags {
+		if aws.StringValue(tag.Key) == "forge-managed" && aws.StringValue(tag.Value) == "true" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// DeleteInstanceProfile removes all roles from the instance profile and deletes it.
+func (s *AWSClient) DeleteInstanceProfile(ctx context.Context, profileName string) error {
+	profile, err := s.findInstanceProfile(ctx, profileName)
+	if err != nil {
+		return err
+	}
+	if profile == nil {
+		return nil
+	}
+
+	for _, role := range profile.Roles {
+		_, err := s.IAM.RemoveRoleFromInstanceProfileWithContext(ctx, &iam.RemoveRoleFromInstanceProfileInput{
+			InstanceProfileName: aws.String(profileName),
+			RoleName:            role.RoleName,
+		})
+		if err != nil && !awserrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to remove role %s from instance profile %s", aws.StringValue(role.RoleName), profileName)
+		}
+	}
+
+	_, err = s.IAM.DeleteInstanceProfileWithContext(ctx, &iam.DeleteInstanceProfileInput{
+		InstanceProfileName: aws.String(profileName),
+	})
+	if err != nil && !awserrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete instance profile %s", profileName)
+	}
+
+	return nil
+}
+
+func (s *AWSClient) findInstanceProfile(ctx context.Context, profileName string) (*iam.InstanceProfile, error) {
+	output, err := s.IAM.GetInstanceProfileWithContext(ctx, &iam.GetInstanceProfileInput{
+		InstanceProfileName: aws.String(profileName),
+	})
+	if err != nil {
+		if awserrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to get instance profile %s", profileName)
+	}
+
+	return output.InstanceProfile, nil
+}
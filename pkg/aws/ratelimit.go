@@ -0,0 +1,97 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultAPIQPS is the sustained rate of calls per service, account and region.
+	DefaultAPIQPS = 10
+	// DefaultAPIBurst is the number of calls allowed above DefaultAPIQPS.
+	DefaultAPIBurst = 20
+	// DefaultAPIMaxRetries is the number of retries of throttled and failed calls.
+	DefaultAPIMaxRetries = 5
+
+	// minRateFraction is the lowest fraction of the configured rate the limiter slows down to when throttled.
+	minRateFraction = 0.1
+	// rateRecoveryFactor is how much the rate grows back after each successful call.
+	rateRecoveryFactor = 1.05
+
+	minThrottleDelay = 500 * time.Millisecond
+	maxThrottleDelay = 20 * time.Second
+)
+
+// RateLimitOptions configures the client-side rate limiting and the retries of the AWS calls.
+type RateLimitOptions struct {
+	// QPS is the sustained rate of calls per service, account and region, calls are not limited when zero.
+	QPS float64
+	// Burst is the number of calls allowed above QPS.
+	Burst int
+	// MaxRetries is the number of retries of throttled and failed calls.
+	MaxRetries int
+}
+
+// adaptiveLimiter is a token bucket which slows down when AWS throttles the calls and gradually recovers its rate.
+type adaptiveLimiter struct {
+	limiter *rate.Limiter
+	max     rate.Limit
+}
+
+func newAdaptiveLimiter(options RateLimitOptions) *adaptiveLimiter {
+	return &adaptiveLimiter{
+		limiter: rate.NewLimiter(rate.Limit(options.QPS), options.Burst),
+		max:     rate.Limit(options.QPS),
+	}
+}
+
+// install waits for the limiter before each attempt of the calls of the client and adapts its rate to their outcome.
+func (l *adaptiveLimiter) install(c *client.Client) {
+	c.Handlers.Sign.PushFrontNamed(request.NamedHandler{Name: "forge.RateLimiter", Fn: l.wait})
+	c.Handlers.CompleteAttempt.PushBackNamed(request.NamedHandler{Name: "forge.AdaptiveRateLimiter", Fn: l.observe})
+}
+
+func (l *adaptiveLimiter) wait(r *request.Request) {
+	if err := l.limiter.Wait(r.Context()); err != nil {
+		r.Error = err
+	}
+}
+
+func (l *adaptiveLimiter) observe(r *request.Request) {
+	current := l.limiter.Limit()
+	switch {
+	case awserrors.IsThrottling(r.Error):
+		l.limiter.SetLimit(max(current/2, l.max*minRateFraction))
+	case r.Error == nil && current < l.max:
+		l.limiter.SetLimit(min(current*rateRecoveryFactor, l.max))
+	}
+}
+
+// retryer returns the retryer of the calls, throttled calls are retried with a longer backoff.
+func retryer(options RateLimitOptions) request.Retryer {
+	return client.DefaultRetryer{
+		NumMaxRetries:    options.MaxRetries,
+		MinThrottleDelay: minThrottleDelay,
+		MaxThrottleDelay: maxThrottleDelay,
+	}
+}
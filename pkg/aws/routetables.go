@@ -0,0 +1,211 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// defaultRouteCIDR is the destination of the default route.
+const defaultRouteCIDR = "0.0.0.0/0"
+
+// CreateOrGetRouteTable returns the route table of the VPC with the given Name tag, creating it if needed.
+func (s *AWSClient) CreateOrGetRouteTable(ctx context.Context, vpcID, name string) (*ec2.RouteTable, error) {
+	output, err := s.EC2.DescribeRouteTablesWithContext(ctx, &ec2.DescribeRouteTablesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("vpc-id"), Values: []*string{aws.String(vpcID)}},
+			{Name: aws.String("tag:Name"), Values: []*string{aws.String(name)}},
+			{Name: aws.String("tag:forge-managed"), Values: []*string{aws.String("true")}},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe route tables")
+	}
+	if len(output.RouteTables) > 0 {
+		return output.RouteTables[0], nil
+	}
+
+	log := log.FromContext(ctx)
+	log.Info("Creating route table", "VPCID", vpcID, "Name", name)
+
+	createOutput, err := s.EC2.CreateRouteTableWithContext(ctx, &ec2.CreateRouteTableInput{
+		VpcId:             aws.String(vpcID),
+		TagSpecifications: vpcResourceTags(ec2.ResourceTypeRouteTable, name, vpcID),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create route table")
+	}
+
+	return createOutput.RouteTable, nil
+}
+
+// EnsureDefaultRoute points the default route of the route table to the target.
+// An existing default route is kept when it already points to the target and replaced otherwise.
+func (s *AWSClient) EnsureDefaultRoute(ctx context.Context, routeTable *ec2.RouteTable, target RouteTarget) error {
+	input := &ec2.CreateRouteInput{
+		RouteTableId:         routeTable.RouteTableId,
+		DestinationCidrBlock: aws.String(defaultRouteCIDR),
+	}
+	if target.InternetGatewayID != "" {
+		input.GatewayId = aws.String(target.InternetGatewayID)
+	}
+	if target.NATGatewayID != "" {
+		input.NatGatewayId = aws.String(target.NATGatewayID)
+	}
+
+	for _, route := range routeTable.Routes {
+		if aws.StringValue(route.DestinationCidrBlock) != defaultRouteCIDR {
+			continue
+		}
+		if aws.StringValue(route.GatewayId) == target.InternetGatewayID &&
+			aws.StringValue(route.NatGatewayId) == target.NATGatewayID &&
+			aws.StringValue(route.State) == ec2.RouteStateActive {
+			return nil
+		}
+
+		_, err := s.EC2.ReplaceRouteWithContext(ctx, &ec2.ReplaceRouteInput{
+			RouteTableId:         input.RouteTableId,
+			DestinationCidrBlock: input.DestinationCidrBlock,
+			GatewayId:            input.GatewayId,
+			NatGatewayId:         input.NatGatewayId,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to replace default route")
+		}
+		return nil
+	}
+
+	_, err := s.EC2.CreateRouteWithContext(ctx, input)
+	if err != nil {
+		return errors.Wrap(err, "failed to add default route")
+	}
+	return nil
+}
+
+// AssociateRouteTable associates the route table with the subnet.
+// It replaces the association of the subnet with another route table and keeps an existing association with this one.
+func (s *AWSClient) AssociateRouteTable(ctx context.Context, routeTableID, subnetID string) error {
+	output, err := s.EC2.DescribeRouteTablesWithContext(ctx, &ec2.DescribeRouteTablesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("association.subnet-id"), Values: []*string{aws.String(subnetID)}},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to describe route tables")
+	}
+
+	log := log.FromContext(ctx)
+	for _, routeTable := range output.RouteTables {
+		for _, association := range routeTable.Associations {
+			if aws.StringValue(association.SubnetId) != subnetID {
+				continue
+			}
+			if aws.StringValue(routeTable.RouteTableId) == routeTableID {
+				return nil
+			}
+
+			log.Info("Replacing route table association", "SubnetID", subnetID, "RouteTableID", routeTableID)
+			_, err := s.EC2.ReplaceRouteTableAssociationWithContext(ctx, &ec2.ReplaceRouteTableAssociationInput{
+				AssociationId: association.RouteTableAssociationId,
+				RouteTableId:  aws.String(routeTableID),
+			})
+			if err != nil {
+				return errors.Wrap(err, "failed to replace route table association")
+			}
+			return nil
+		}
+	}
+
+	log.Info("Associating route table", "SubnetID", subnetID, "RouteTableID", routeTableID)
+	_, err = s.EC2.AssociateRouteTableWithContext(ctx, &ec2.AssociateRouteTableInput{
+		RouteTableId: aws.String(routeTableID),
+		SubnetId:     aws.String(subnetID),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to associate route table with subnet")
+	}
+	return nil
+}
+
+// DeleteRouteTable removes the associations of the route table and deletes it.
+func (s *AWSClient) DeleteRouteTable(ctx context.Context, routeTableID string) error {
+	output, err := s.EC2.DescribeRouteTablesWithContext(ctx, &ec2.DescribeRouteTablesInput{
+		RouteTableIds: []*string{aws.String(routeTableID)},
+	})
+	if err != nil {
+		if awserrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to describe route table")
+	}
+
+	for _, routeTable := range output.RouteTables {
+		if err := s.deleteRouteTable(ctx, routeTable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteRouteTables deletes the route tables created by Forge in the VPC, the main route table is deleted with the VPC.
+func (s *AWSClient) DeleteRouteTables(ctx context.Context, vpcID string) error {
+	output, err := s.EC2.DescribeRouteTablesWithContext(ctx, &ec2.DescribeRouteTablesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("vpc-id"), Values: []*string{aws.String(vpcID)}},
+			{Name: aws.String("tag:forge-managed"), Values: []*string{aws.String("true")}},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to describe route tables")
+	}
+
+	for _, routeTable := range output.RouteTables {
+		if err := s.deleteRouteTable(ctx, routeTable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *AWSClient) deleteRouteTable(ctx context.Context, routeTable *ec2.RouteTable) error {
+	routeTableID := aws.StringValue(routeTable.RouteTableId)
+	for _, association := range routeTable.Associations {
+		if aws.BoolValue(association.Main) {
+			return errors.Errorf("route table %s is the main route table of the VPC", routeTableID)
+		}
+		_, err := s.EC2.DisassociateRouteTableWithContext(ctx, &ec2.DisassociateRouteTableInput{
+			AssociationId: association.RouteTableAssociationId,
+		})
+		if err != nil && !awserrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to disassociate route table %s", routeTableID)
+		}
+	}
+
+	_, err := s.EC2.DeleteRouteTableWithContext(ctx, &ec2.DeleteRouteTableInput{
+		RouteTableId: routeTable.RouteTableId,
+	})
+	if err != nil && !awserrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete route table %s", routeTableID)
+	}
+	return nil
+}
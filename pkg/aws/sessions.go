@@ -0,0 +1,134 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+)
+
+// sessionIdleTimeout is how long a cached session is kept without being used.
+const sessionIdleTimeout = 30 * time.Minute
+
+// SessionCache reuses the sessions and SDK clients across reconciles.
+// Sessions are keyed by region, credentials, endpoints and versions of their Secrets, so a rotated Secret gets a new session.
+// Credentials of assumed roles are refreshed by their session before they expire.
+// The calls of a service made with the same region and credentials share a rate limiter.
+type SessionCache struct {
+	mu       sync.Mutex
+	sessions map[string]*cachedSession
+	options  RateLimitOptions
+
+	// limiters are the rate limiters by service, region and credentials.
+	// They have their own lock as the clients of other regions are created outside of mu.
+	limitersMu sync.Mutex
+	limiters   map[string]*adaptiveLimiter
+}
+
+type cachedSession struct {
+	client   AWSClient
+	lastUsed time.Time
+}
+
+// NewSessionCache returns an empty SessionCache limiting the AWS calls with the options.
+func NewSessionCache(options RateLimitOptions) *SessionCache {
+	return &SessionCache{
+		sessions: map[string]*cachedSession{},
+		limiters: map[string]*adaptiveLimiter{},
+		options:  options,
+	}
+}
+
+// client returns the cached client of the credentials, creating it if needed.
+func (c *SessionCache) client(params ClientParams, creds *secretCredentials, bundle *caBundle) (AWSClient, error) {
+	key := identityKey(params, creds)
+	if creds != nil {
+		key += "@" + creds.resourceVersion
+	}
+	if bundle != nil {
+		key += "@" + bundle.resourceVersion
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Drop the sessions of deleted or rotated credentials
+	for k, cached := range c.sessions {
+		if now.Sub(cached.lastUsed) > sessionIdleTimeout {
+			delete(c.sessions, k)
+		}
+	}
+
+	if cached, ok := c.sessions[key]; ok {
+		cached.lastUsed = now
+		return cached.client, nil
+	}
+
+	sess, err := newSession(params, creds, bundle)
+	if err != nil {
+		return AWSClient{}, err
+	}
+	awsClient := newAWSClient(sess, params)
+	awsClient.limit = c.limit(credentialsKey(params, creds))
+	for _, serviceClient := range []*client.Client{awsClient.EC2.Client, awsClient.IAM.Client, awsClient.SSM.Client} {
+		// The region of the credentials Secret overrides the region of the build, the calls are limited where they are made
+		awsClient.limit(serviceClient, awsClient.Region())
+	}
+
+	c.sessions[key] = &cachedSession{client: awsClient, lastUsed: now}
+	return awsClient, nil
+}
+
+// limit returns a function installing the retryer and the rate limiter of the service and region on the clients
+// of the credentials. The limiters outlive the sessions so that rotated credentials keep the same budget.
+func (c *SessionCache) limit(credentials string) func(serviceClient *client.Client, region string) {
+	return func(serviceClient *client.Client, region string) {
+		serviceClient.Retryer = retryer(c.options)
+		if c.options.QPS <= 0 {
+			return
+		}
+
+		key := fmt.Sprintf("%s|%s|%s", serviceClient.ServiceName, region, credentials)
+		c.limitersMu.Lock()
+		limiter, ok := c.limiters[key]
+		if !ok {
+			limiter = newAdaptiveLimiter(c.options)
+			c.limiters[key] = limiter
+		}
+		c.limitersMu.Unlock()
+		limiter.install(serviceClient)
+	}
+}
+
+// identityKey identifies the region, the credentials and the endpoints of a session,
+// regardless of the versions of their Secrets.
+func identityKey(params ClientParams, creds *secretCredentials) string {
+	return fmt.Sprintf("%s|%s", params.Region, credentialsKey(params, creds))
+}
+
+// credentialsKey identifies the credentials and the endpoints of a session, regardless of its region.
+func credentialsKey(params ClientParams, creds *secretCredentials) string {
+	identity := "controller"
+	if creds != nil {
+		identity = fmt.Sprintf("secret:%s", creds.secret)
+	}
+	return fmt.Sprintf("%s|%v|%s", identity, params.AssumeRoles, endpointsKey(params))
+}
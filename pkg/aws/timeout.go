@@ -0,0 +1,41 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// DefaultAPICallTimeout is the deadline of each AWS call, including its retries.
+const DefaultAPICallTimeout = 2 * time.Minute
+
+// installCallTimeout bounds the calls of the client with a deadline on top of the context they are made with.
+// The deadline is set once per call so that it also covers the retries of the call.
+func installCallTimeout(c *client.Client, timeout time.Duration) {
+	c.Handlers.Validate.PushFrontNamed(request.NamedHandler{
+		Name: "forge.CallTimeout",
+		Fn: func(r *request.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			r.SetContext(ctx)
+			r.Handlers.Complete.PushBack(func(*request.Request) { cancel() })
+		},
+	})
+}
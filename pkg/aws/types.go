@@ -20,8 +20,87 @@ import (
 	"context"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 )
 
+// ClientParams configures the region and the credentials of an AWSClient.
+type ClientParams struct {
+	Region string
+	// CredentialsRef is the Secret holding static credentials, the default credential chain is used when nil.
+	CredentialsRef *corev1.SecretReference
+	// AssumeRoles are assumed in order, starting with the base credentials.
+	AssumeRoles []infrav1.AssumeRoleSpec
+	// Sessions reuses the sessions of previous clients with the same credentials, a new session is created when nil.
+	Sessions *SessionCache
+	// EC2Endpoint overrides the EC2 endpoint of the region, e.g., to run against a local stand-in of the EC2 API.
+	EC2Endpoint string
+	// Endpoints configures the endpoints, the CA bundle and the proxy of all the AWS clients, EC2Endpoint wins over its EC2 endpoint.
+	// The namespace of its CABundleRef must be set.
+	Endpoints *infrav1.EndpointsSpec
+}
+
+// CreateAMIParams configures the AMI created from an instance.
+type CreateAMIParams struct {
+	InstanceID string
+	Name       string
+	// Tags are set on the AMI and the snapshots of its volumes.
+	Tags map[string]string
+}
+
+// CopyAMIParams configures the copy of an AMI of the region of the client to another region.
+type CopyAMIParams struct {
+	SourceImageID string
+	Name          string
+	Region        string
+	// KMSKeyID encrypts the snapshots of the copy, they keep the encryption of the source AMI when it is empty.
+	KMSKeyID string
+	// Tags are set on the copy and the snapshots of its volumes.
+	Tags map[string]string
+}
+
+type CreateSubnetParams struct {
+	VPCName string
+	VPCID   *string
+	// Name is the Name tag of the subnet, <VPCName>-subnet is used when empty.
+	Name string
+	// CIDRBlock is the CIDR of the subnet, a free block of PrefixLength is picked when empty.
+	CIDRBlock string
+	// PrefixLength is the size of the picked subnet, DefaultSubnetPrefixLength is used when zero.
+	PrefixLength int
+	// ReservedCIDRs are kept free when picking the subnet CIDR.
+	ReservedCIDRs []string
+	// AvailabilityZone is the zone of the subnet, AWS picks one when empty.
+	AvailabilityZone string
+}
+
+// RouteTarget is the target of a default route, either an Internet Gateway or a NAT gateway.
+type RouteTarget struct {
+	InternetGatewayID string
+	NATGatewayID      string
+}
+
+type NATGatewayParams struct {
+	VPCID string
+	// SubnetID is the public subnet the NAT gateway is placed in.
+	SubnetID string
+	Name     string
+}
+
+type VPCEndpointsParams struct {
+	VPCID  string
+	Region string
+	// Services are the short service names (e.g., s3, ssm) of the endpoints.
+	Services []string
+	// SubnetID and SecurityGroupID are used by the interface endpoints.
+	SubnetID        string
+	SecurityGroupID string
+	// RouteTableIDs are the route tables the gateway endpoints are added to.
+	RouteTableIDs []string
+	Name          string
+}
+
 type CreateInstanceParams struct {
 	Name            string
 	AmiID           string
@@ -30,41 +109,90 @@ type CreateInstanceParams struct {
 	PublicIP        bool
 	SubnetID        string
 	SecurityGroupID string
+	// AdditionalSecurityGroupIDs are attached to the instance alongside SecurityGroupID.
+	AdditionalSecurityGroupIDs []string
+	// SpotOptions requests Spot capacity for the instance, on-demand is used when nil.
+	SpotOptions *infrav1.SpotOptions
+	// IAMInstanceProfile is the name of the instance profile attached to the instance.
+	IAMInstanceProfile string
+	// RootVolume overrides the root volume of the source AMI.
+	RootVolume *infrav1.AttachedVolumeSpec
+	// AdditionalVolumes are attached to the instance next to the root volume.
+	AdditionalVolumes []infrav1.AttachedVolumeSpec
+	// AvailabilityZone places the instance, it must be the zone of the subnet.
+	AvailabilityZone string
 }
 
 type Interface interface {
 
 	// EC2 Instance
-	IsManagedInstance(instanceID *string) (bool, error)
-	FindInstanceByID(instanceID *string) (*ec2.Instance, error)
-	CreateInstance(input CreateInstanceParams) (*ec2.Instance, error)
-	TerminateInstance(instanceID *string) error
+	IsManagedInstance(ctx context.Context, instanceID *string) (bool, error)
+	FindInstanceByID(ctx context.Context, instanceID *string) (*ec2.Instance, error)
+	CreateInstance(ctx context.Context, input CreateInstanceParams) (*ec2.Instance, error)
+	TerminateInstance(ctx context.Context, instanceID *string) error
+	CancelSpotInstanceRequest(ctx context.Context, requestID *string) error
 
 	// Network
-	FindVPCByIDOrName(vpcID, vpcName *string) (*ec2.Vpc, error)
-	IsManagedVPC(vpcID *string) (bool, error)
-	DeleteVPC(vpcID *string) error
-	CreateVPC(input *ec2.CreateVpcInput) (*ec2.Vpc, error)
+	FindVPCByIDOrName(ctx context.Context, vpcID, vpcName *string) (*ec2.Vpc, error)
+	IsManagedVPC(ctx context.Context, vpcID *string) (bool, error)
+	DeleteVPC(ctx context.Context, vpcID *string) error
+	CreateVPC(ctx context.Context, input *ec2.CreateVpcInput) (*ec2.Vpc, error)
+	DetectEgressIP(ctx context.Context) (string, error)
 
 	// Security Group
-	CreateSecurityGroup(vpcID, sgName *string) (*ec2.CreateSecurityGroupOutput, error)
-	AuthorizeSecurityGroupIngress(sgID string) error
-	IsManagedSecurityGroup(sgID string) (bool, error)
-	DeleteSecurityGroup(sgID *string) error
+	CreateSecurityGroup(ctx context.Context, vpcID, sgName *string) (*ec2.CreateSecurityGroupOutput, error)
+	FindSecurityGroupByID(ctx context.Context, sgID string) (*ec2.SecurityGroup, error)
+	FindSecurityGroupsInVPC(ctx context.Context, vpcID string, sgIDs []string, tags map[string]string) ([]*ec2.SecurityGroup, error)
+	AuthorizeSecurityGroupIngress(ctx context.Context, sgID string, permissions []*ec2.IpPermission) error
+	RevokeSecurityGroupIngress(ctx context.Context, sgID string, permissions []*ec2.IpPermission) error
+	AuthorizeSecurityGroupEgress(ctx context.Context, sgID string, permissions []*ec2.IpPermission) error
+	RevokeSecurityGroupEgress(ctx context.Context, sgID string, permissions []*ec2.IpPermission) error
+	IsManagedSecurityGroup(ctx context.Context, sgID string) (bool, error)
+	DeleteSecurityGroup(ctx context.Context, sgID *string) error
 
 	// Subnets
-	CreateSubnet(ctx context.Context, vpcName string, vpcID *string) (*ec2.Subnet, error)
+	CreateSubnet(ctx context.Context, params CreateSubnetParams) (*ec2.Subnet, error)
 	DeleteSubnet(ctx context.Context, subnetID *string) error
 	IsManagedSubnet(ctx context.Context, subnetID string) (bool, error)
 	FindSubnetByID(ctx context.Context, subnetID string) (*ec2.Subnet, error)
+	FindAvailabilityZones(ctx context.Context, instanceType string, preferred []string) ([]string, error)
 
 	// InternetGateway
-	DetachAndDeleteInternetGateway(vpcID *string) error
+	DetachAndDeleteInternetGateway(ctx context.Context, vpcID *string) error
 	CreateOrGetInternetGateway(ctx context.Context, vpcID string) (*ec2.InternetGateway, error)
 
+	// Route Tables
+	CreateOrGetRouteTable(ctx context.Context, vpcID, name string) (*ec2.RouteTable, error)
+	EnsureDefaultRoute(ctx context.Context, routeTable *ec2.RouteTable, target RouteTarget) error
+	AssociateRouteTable(ctx context.Context, routeTableID, subnetID string) error
+	DeleteRouteTable(ctx context.Context, routeTableID string) error
+	DeleteRouteTables(ctx context.Context, vpcID string) error
+
+	// Private egress
+	FindSubnetByName(ctx context.Context, vpcID, name string) (*ec2.Subnet, error)
+	EnableVPCDNSHostnames(ctx context.Context, vpcID string) error
+	CreateOrGetNATGateway(ctx context.Context, params NATGatewayParams) (*ec2.NatGateway, error)
+	CreateOrGetVPCEndpoints(ctx context.Context, params VPCEndpointsParams) ([]*ec2.VpcEndpoint, error)
+	DeleteVPCEndpoints(ctx context.Context, vpcID string) (bool, error)
+	DeleteNATGateways(ctx context.Context, vpcID string) (bool, error)
+	ReleaseAddresses(ctx context.Context, vpcID string) (bool, error)
+
+	// IAM Instance Profile
+	FindInstanceProfileForRole(ctx context.Context, role string) (*iam.InstanceProfile, error)
+	CreateInstanceProfileForRole(ctx context.Context, profileName, role string) (*iam.InstanceProfile, error)
+	IsManagedInstanceProfile(ctx context.Context, profileName string) (bool, error)
+	DeleteInstanceProfile(ctx context.Context, profileName string) error
+
 	// AMI Image
-	CreateAMI(ctx context.Context, instanceID, imageName string) error
+	ResolveAMI(ctx context.Context, selector infrav1.AMISelector) (string, error)
+	CreateAMI(ctx context.Context, params CreateAMIParams) error
 	EnsureAMIDoesNotExist(ctx context.Context, imageName, creationDate string) error
 	ListAMIs(ctx context.Context, imageName string) ([]*ec2.Image, error)
 	CheckAMIStatus(ctx context.Context, imageName string) (string, string, error)
+	ListManagedAMIs(ctx context.Context, region string, tags map[string]string) ([]*ec2.Image, error)
+	DeleteAMI(ctx context.Context, region string, image *ec2.Image) error
+	CopyAMI(ctx context.Context, params CopyAMIParams) (string, error)
+	FindRegionalAMI(ctx context.Context, region, imageID string) (*ec2.Image, error)
+	ShareAMI(ctx context.Context, region, imageID string, permissions infrav1.LaunchPermissions) error
+	UnshareAMI(ctx context.Context, region string, image *ec2.Image) error
 }
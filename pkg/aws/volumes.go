@@ -0,0 +1,156 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+// getImageRootDeviceName returns the root device name of the given AMI (e.g., /dev/sda1).
+func (s *AWSClient) getImageRootDeviceName(ctx context.Context, amiID string) (string, error) {
+	output, err := s.EC2.DescribeImagesWithContext(ctx, &ec2.DescribeImagesInput{
+		ImageIds: []*string{aws.String(amiID)},
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to describe AMI %s", amiID)
+	}
+
+	if len(output.Images) == 0 {
+		return "", errors.Errorf("no AMI found with ID %s", amiID)
+	}
+
+	rootDeviceName := aws.StringValue(output.Images[0].RootDeviceName)
+	if rootDeviceName == "" {
+		return "", errors.Errorf("AMI %s has no root device name", amiID)
+	}
+
+	return rootDeviceName, nil
+}
+
+// buildBlockDeviceMappings converts the root and additional volume specs into block device mappings.
+// The root volume is mapped onto the root device of the source AMI so it overrides the AMI's own root disk.
+func (s *AWSClient) buildBlockDeviceMappings(ctx context.Context, input CreateInstanceParams) ([]*ec2.BlockDeviceMapping, error) {
+	if input.RootVolume == nil && len(input.AdditionalVolumes) == 0 {
+		return nil, nil
+	}
+
+	rootDeviceName, err := s.getImageRootDeviceName(ctx, input.AmiID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve root device name")
+	}
+
+	var mappings []*ec2.BlockDeviceMapping
+	usedDeviceNames := map[string]bool{rootDeviceName: true}
+
+	if input.RootVolume != nil {
+		ebs, err := ebsBlockDevice(*input.RootVolume, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid root volume")
+		}
+		mappings = append(mappings, &ec2.BlockDeviceMapping{
+			DeviceName: aws.String(rootDeviceName),
+			Ebs:        ebs,
+		})
+	}
+
+	for i, volume := range input.AdditionalVolumes {
+		deviceName := aws.StringValue(volume.DeviceName)
+		if deviceName == "" {
+			deviceName, err = nextDeviceName(usedDeviceNames)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if usedDeviceNames[deviceName] {
+			return nil, errors.Errorf("additional volume %d: device name %s is already in use", i, deviceName)
+		}
+		usedDeviceNames[deviceName] = true
+
+		ebs, err := ebsBlockDevice(volume, aws.Int64(infrav1.DefaultVolumeSize))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid additional volume %d", i)
+		}
+		mappings = append(mappings, &ec2.BlockDeviceMapping{
+			DeviceName: aws.String(deviceName),
+			Ebs:        ebs,
+		})
+	}
+
+	return mappings, nil
+}
+
+// ebsBlockDevice validates a volume spec and converts it into an EBS block device.
+// If the spec has no size, defaultSize is used; a nil defaultSize keeps the size of the AMI snapshot.
+func ebsBlockDevice(volume infrav1.AttachedVolumeSpec, defaultSize *int64) (*ec2.EbsBlockDevice, error) {
+	ebs := &ec2.EbsBlockDevice{
+		DeleteOnTermination: aws.Bool(true),
+		VolumeSize:          defaultSize,
+	}
+
+	if volume.Size != nil {
+		ebs.VolumeSize = volume.Size
+	}
+
+	volumeType := infrav1.DiskType(ec2.VolumeTypeGp3)
+	if volume.VolumeType != nil {
+		volumeType = *volume.VolumeType
+	}
+	ebs.VolumeType = aws.String(string(volumeType))
+
+	if volume.IOPS != nil {
+		switch volumeType {
+		case infrav1.ProvisionedIOPS, infrav1.ProvisionedIOPSv2, infrav1.GeneralPurposeSSDv3:
+			ebs.Iops = volume.IOPS
+		default:
+			return nil, errors.Errorf("iops is not supported for volume type %s", volumeType)
+		}
+	} else if volumeType == infrav1.ProvisionedIOPS || volumeType == infrav1.ProvisionedIOPSv2 {
+		return nil, errors.Errorf("iops is required for volume type %s", volumeType)
+	}
+
+	if volume.Throughput != nil {
+		if volumeType != infrav1.GeneralPurposeSSDv3 {
+			return nil, errors.Errorf("throughput is not supported for volume type %s", volumeType)
+		}
+		ebs.Throughput = volume.Throughput
+	}
+
+	if aws.BoolValue(volume.Encrypted) || volume.EncryptionKey != nil {
+		ebs.Encrypted = aws.Bool(true)
+		ebs.KmsKeyId = volume.EncryptionKey
+	}
+
+	return ebs, nil
+}
+
+// nextDeviceName returns the first /dev/sd[b-z] device name that is not in use.
+func nextDeviceName(used map[string]bool) (string, error) {
+	for c := 'b'; c <= 'z'; c++ {
+		name := fmt.Sprintf("/dev/sd%c", c)
+		// Xen based instances expose /dev/sdX as /dev/xvdX, so both must be free.
+		if !used[name] && !used[fmt.Sprintf("/dev/xvd%c", c)] {
+			return name, nil
+		}
+	}
+	return "", errors.New("no free device name available for additional volume")
+}
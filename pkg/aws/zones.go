@@ -0,0 +1,68 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+// FindAvailabilityZones returns the availability zones of the region offering the instance type.
+// The preferred zones are returned in their order, all the zones offering the instance type are returned sorted otherwise.
+func (s *AWSClient) FindAvailabilityZones(ctx context.Context, instanceType string, preferred []string) ([]string, error) {
+	offered := map[string]bool{}
+	err := s.EC2.DescribeInstanceTypeOfferingsPagesWithContext(ctx, &ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: aws.String(ec2.LocationTypeAvailabilityZone),
+		Filters: []*ec2.Filter{
+			{Name: aws.String("instance-type"), Values: []*string{aws.String(instanceType)}},
+		},
+	}, func(page *ec2.DescribeInstanceTypeOfferingsOutput, _ bool) bool {
+		for _, offering := range page.InstanceTypeOfferings {
+			offered[aws.StringValue(offering.Location)] = true
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe instance type offerings")
+	}
+
+	var zones []string
+	if len(preferred) == 0 {
+		for zone := range offered {
+			zones = append(zones, zone)
+		}
+		sort.Strings(zones)
+	} else {
+		for _, zone := range preferred {
+			if offered[zone] {
+				zones = append(zones, zone)
+			}
+		}
+	}
+
+	if len(zones) == 0 {
+		if len(preferred) == 0 {
+			return nil, errors.Errorf("instance type %s is not offered in any availability zone of the region", instanceType)
+		}
+		return nil, errors.Errorf("instance type %s is not offered in availability zones %v", instanceType, preferred)
+	}
+	return zones, nil
+}
@@ -32,7 +32,7 @@ type Reconciler interface {
 
 // Client is an interface which can get cloud client.
 type Client interface {
-	Cloud() *awsforge.AWSClient
+	Cloud() awsforge.Interface
 	Log(service string) logr.Logger
 }
 
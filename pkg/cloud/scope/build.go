@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -30,10 +31,15 @@ import (
 	"github.com/forge-build/forge/pkg/util"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// maxInstanceProfileNameLength is the length limit of IAM instance profile names.
+const maxInstanceProfileNameLength = 128
+
 // AWSBuildScope defines the basic context for an actuator to operate upon for AWS.
 type AWSBuildScope struct {
 	client      client.Client
@@ -41,8 +47,10 @@ type AWSBuildScope struct {
 	Logger      *logr.Logger
 	Build       *buildv1.Build
 	AWSBuild    *infrav1.AWSBuild
-	AWSClient   *awsforge.AWSClient
+	AWSClient   awsforge.Interface
 	sshKEy      SSHKey
+	// region is the region of the AWS client, which may differ from the region of the spec.
+	region string
 }
 
 type SSHKey struct {
@@ -53,11 +61,14 @@ type SSHKey struct {
 
 // AWSBuildScopeParams defines the input parameters to create an AWS BuildScope.
 type AWSBuildScopeParams struct {
-	Client    client.Client
-	Build     *buildv1.Build
-	AWSBuild  *infrav1.AWSBuild
-	AWSClient *awsforge.AWSClient
-	Log       *logr.Logger
+	Client   client.Client
+	Build    *buildv1.Build
+	AWSBuild *infrav1.AWSBuild
+	// AWSClient makes the AWS calls of the services, pkg/aws/fake provides an in-memory implementation.
+	AWSClient awsforge.Interface
+	// ClientParams configures the AWSClient created when AWSClient is nil.
+	ClientParams awsforge.ClientParams
+	Log          *logr.Logger
 }
 
 // NewAWSBuildScope creates a new AWSBuildScope from the supplied parameters.
@@ -69,13 +80,15 @@ func NewAWSBuildScope(ctx context.Context, params AWSBuildScopeParams) (*AWSBuil
 		return nil, errors.New("failed to generate new scope from nil AWSBuild")
 	}
 
+	var region string
 	if params.AWSClient == nil {
-		awsSvc, err := awsforge.NewAWSClient(ctx, params.AWSBuild.Spec.Region, params.AWSBuild.Spec.CredentialsRef, params.Client)
+		awsSvc, err := awsforge.NewAWSClient(ctx, params.ClientParams, params.Client)
 		if err != nil {
 			return nil, errors.Errorf("failed to create aws client: %v", err)
 		}
 
 		params.AWSClient = &awsSvc
+		region = awsSvc.Region()
 	}
 
 	helper, err := patch.NewHelper(params.AWSBuild, params.Client)
@@ -88,12 +101,14 @@ func NewAWSBuildScope(ctx context.Context, params AWSBuildScopeParams) (*AWSBuil
 		Build:       params.Build,
 		AWSBuild:    params.AWSBuild,
 		AWSClient:   params.AWSClient,
+		region:      region,
 		patchHelper: helper,
 		Logger:      params.Log,
 	}, nil
 }
 
-func (s *AWSBuildScope) Cloud() *awsforge.AWSClient {
+// Cloud returns the client the services make their AWS calls with.
+func (s *AWSBuildScope) Cloud() awsforge.Interface {
 	return s.AWSClient
 }
 
@@ -112,12 +127,9 @@ func (s *AWSBuildScope) SetSSHKey(key SSHKey) {
 }
 
 func (s *AWSBuildScope) VPCSpec() *ec2.CreateVpcInput {
-	// Define a default CIDR block for new VPCs
-	defaultCIDR := "10.0.0.0/16"
-
 	// Define the input for creating a new VPC
 	return &ec2.CreateVpcInput{
-		CidrBlock: aws.String(defaultCIDR),
+		CidrBlock: aws.String(s.VPCCIDR()),
 		TagSpecifications: []*ec2.TagSpecification{
 			{
 				ResourceType: aws.String(ec2.ResourceTypeVpc),
@@ -169,8 +181,11 @@ func (s *AWSBuildScope) SetVPCName(name string) {
 	s.AWSBuild.Spec.Network.Name = name
 }
 
-// Region returns the AWS region for the build.
+// Region returns the AWS region of the build, the region of the credentials Secret takes precedence over the spec.
 func (s *AWSBuildScope) Region() string {
+	if s.region != "" {
+		return s.region
+	}
 	return s.AWSBuild.Spec.Region
 }
 
@@ -192,6 +207,45 @@ func (s *AWSBuildScope) Namespace() string {
 	return s.Build.Namespace
 }
 
+// BuildUID returns the UID of the build.
+func (s *AWSBuildScope) BuildUID() string {
+	return string(s.Build.UID)
+}
+
+// BuildLabels returns the labels of the build.
+func (s *AWSBuildScope) BuildLabels() map[string]string {
+	return s.Build.Labels
+}
+
+// ImageSpec returns the configuration of the AMI produced by the build.
+func (s *AWSBuildScope) ImageSpec() infrav1.ImageSpec {
+	if s.AWSBuild.Spec.Image == nil {
+		return infrav1.ImageSpec{}
+	}
+	return *s.AWSBuild.Spec.Image
+}
+
+// VPCCIDR returns the CIDR block of the VPC to create, defaulting to awsforge.DefaultVPCCIDR.
+func (s *AWSBuildScope) VPCCIDR() string {
+	if s.AWSBuild.Spec.Network.VPCCIDR == "" {
+		return awsforge.DefaultVPCCIDR
+	}
+	return s.AWSBuild.Spec.Network.VPCCIDR
+}
+
+// SubnetCIDR returns the requested CIDR block of the subnet to create, if any.
+func (s *AWSBuildScope) SubnetCIDR() string {
+	return aws.StringValue(s.AWSBuild.Spec.Network.SubnetCIDR)
+}
+
+// SubnetPrefixLength returns the prefix length of the subnet to create, defaulting to awsforge.DefaultSubnetPrefixLength.
+func (s *AWSBuildScope) SubnetPrefixLength() int {
+	if s.AWSBuild.Spec.Network.SubnetPrefixLength == nil {
+		return awsforge.DefaultSubnetPrefixLength
+	}
+	return int(*s.AWSBuild.Spec.Network.SubnetPrefixLength)
+}
+
 // SubnetID returns the subnet ID for the AWS instance.
 func (s *AWSBuildScope) SubnetID() *string {
 	return s.AWSBuild.Spec.Network.SubnetID
@@ -210,20 +264,201 @@ func (s *AWSBuildScope) SecurityGroupID() *string {
 	return s.AWSBuild.Spec.Network.SecurityGroupID
 }
 
+// AdditionalSecurityGroups returns the references of the security groups attached alongside the managed one.
+func (s *AWSBuildScope) AdditionalSecurityGroups() []infrav1.SecurityGroupReference {
+	return s.AWSBuild.Spec.Network.AdditionalSecurityGroups
+}
+
+// AdditionalSecurityGroupIDs returns the resolved IDs of the additional security groups.
+func (s *AWSBuildScope) AdditionalSecurityGroupIDs() []string {
+	return s.AWSBuild.Status.AdditionalSecurityGroupIDs
+}
+
+// SetAdditionalSecurityGroupIDs sets the resolved IDs of the additional security groups.
+func (s *AWSBuildScope) SetAdditionalSecurityGroupIDs(ids []string) {
+	s.AWSBuild.Status.AdditionalSecurityGroupIDs = ids
+}
+
+// RouteTableID returns the ID of the route table created for the build subnet.
+func (s *AWSBuildScope) RouteTableID() *string {
+	return s.AWSBuild.Status.RouteTableID
+}
+
+// SetRouteTableID sets the ID of the route table created for the build subnet.
+func (s *AWSBuildScope) SetRouteTableID(id *string) {
+	s.AWSBuild.Status.RouteTableID = id
+}
+
+// SSHIngress returns the peers allowed to connect to the instance over SSH.
+func (s *AWSBuildScope) SSHIngress() *infrav1.SecurityGroupRulePeers {
+	return s.AWSBuild.Spec.Network.SSHIngress
+}
+
+// EgressIP returns the public IP of the controller detected for the SSH rule.
+func (s *AWSBuildScope) EgressIP() *string {
+	return s.AWSBuild.Status.EgressIP
+}
+
+// SetEgressIP sets the public IP of the controller detected for the SSH rule.
+func (s *AWSBuildScope) SetEgressIP(ip string) {
+	s.AWSBuild.Status.EgressIP = &ip
+}
+
+// AdditionalIngressRules returns the ingress rules added next to the SSH rule.
+func (s *AWSBuildScope) AdditionalIngressRules() []infrav1.SecurityGroupRule {
+	return s.AWSBuild.Spec.Network.AdditionalIngressRules
+}
+
+// EgressRules returns the egress rules of the security group.
+func (s *AWSBuildScope) EgressRules() []infrav1.SecurityGroupRule {
+	return s.AWSBuild.Spec.Network.EgressRules
+}
+
+// PublicIP returns whether the instance gets a public IP, private networks never assign one.
 func (s *AWSBuildScope) PublicIP() *bool {
+	if s.AWSBuild.Spec.Network.Private != nil {
+		return aws.Bool(false)
+	}
 	return s.AWSBuild.Spec.PublicIP
 }
 
+// PrivateEgress returns the egress of the private network, or an empty mode for public networks.
+func (s *AWSBuildScope) PrivateEgress() infrav1.PrivateEgressMode {
+	private := s.AWSBuild.Spec.Network.Private
+	if private == nil {
+		return ""
+	}
+	if private.Egress == "" {
+		return infrav1.PrivateEgressNATGateway
+	}
+	return private.Egress
+}
+
+// VPCEndpointServices returns the services reachable through VPC endpoints, defaulting to awsforge.DefaultVPCEndpointServices.
+func (s *AWSBuildScope) VPCEndpointServices() []string {
+	private := s.AWSBuild.Spec.Network.Private
+	if private == nil || len(private.VPCEndpoints) == 0 {
+		return awsforge.DefaultVPCEndpointServices
+	}
+	return private.VPCEndpoints
+}
+
 // AMI returns the Amazon Machine Image (AMI) ID.
+// Once the source AMI is resolved, it is returned so that the build stays reproducible.
 func (s *AWSBuildScope) AMI() string {
+	if s.AWSBuild.Status.SourceAMI != nil {
+		return *s.AWSBuild.Status.SourceAMI
+	}
 	return aws.StringValue(s.AWSBuild.Spec.AMI)
 }
 
+// AMISelector returns the selector used to resolve the AMI when no AMI ID is set.
+func (s *AWSBuildScope) AMISelector() *infrav1.AMISelector {
+	return s.AWSBuild.Spec.AMISelector
+}
+
+// SourceAMI returns the resolved AMI ID the instance is launched from.
+func (s *AWSBuildScope) SourceAMI() *string {
+	return s.AWSBuild.Status.SourceAMI
+}
+
+// SetSourceAMI sets the resolved AMI ID the instance is launched from.
+func (s *AWSBuildScope) SetSourceAMI(id *string) {
+	s.AWSBuild.Status.SourceAMI = id
+}
+
+// MarketType returns the market used to purchase the instance.
+func (s *AWSBuildScope) MarketType() infrav1.MarketType {
+	return s.AWSBuild.Spec.MarketType
+}
+
+// SpotOptions returns the Spot options for the instance.
+func (s *AWSBuildScope) SpotOptions() *infrav1.SpotOptions {
+	return s.AWSBuild.Spec.SpotOptions
+}
+
+// LaunchAttempt returns the index of the launch option used for the instance.
+func (s *AWSBuildScope) LaunchAttempt() int32 {
+	return s.AWSBuild.Status.LaunchAttempt
+}
+
+// SetLaunchAttempt sets the index of the launch option used for the instance.
+func (s *AWSBuildScope) SetLaunchAttempt(attempt int32) {
+	s.AWSBuild.Status.LaunchAttempt = attempt
+}
+
+// AvailabilityZones returns the preferred availability zones of the instance.
+func (s *AWSBuildScope) AvailabilityZones() []string {
+	return s.AWSBuild.Spec.AvailabilityZones
+}
+
+// AvailabilityZone returns the availability zone of the subnet and the instance.
+func (s *AWSBuildScope) AvailabilityZone() *string {
+	return s.AWSBuild.Status.AvailabilityZone
+}
+
+// SetAvailabilityZone sets the availability zone of the subnet and the instance.
+func (s *AWSBuildScope) SetAvailabilityZone(zone *string) {
+	s.AWSBuild.Status.AvailabilityZone = zone
+}
+
+// MarkConditionTrue sets the given condition of the AWSBuild to true.
+func (s *AWSBuildScope) MarkConditionTrue(t clusterv1.ConditionType) {
+	conditions.MarkTrue(s.AWSBuild, t)
+}
+
+// IsConditionTrue returns true if the given condition of the AWSBuild is true.
+func (s *AWSBuildScope) IsConditionTrue(t clusterv1.ConditionType) bool {
+	return conditions.IsTrue(s.AWSBuild, t)
+}
+
+// MarkConditionFalse sets the given condition of the AWSBuild to false.
+func (s *AWSBuildScope) MarkConditionFalse(t clusterv1.ConditionType, reason string, severity clusterv1.ConditionSeverity, messageFormat string, messageArgs ...interface{}) {
+	conditions.MarkFalse(s.AWSBuild, t, reason, severity, messageFormat, messageArgs...)
+}
+
+// RootVolume returns the root volume configuration for the instance.
+func (s *AWSBuildScope) RootVolume() *infrav1.AttachedVolumeSpec {
+	return s.AWSBuild.Spec.RootVolume
+}
+
+// AdditionalVolumes returns the additional volumes to attach to the instance.
+func (s *AWSBuildScope) AdditionalVolumes() []infrav1.AttachedVolumeSpec {
+	return s.AWSBuild.Spec.AdditionalVolumes
+}
+
 // IAMRole returns the IAM role for the instance.
 func (s *AWSBuildScope) IAMRole() string {
 	return aws.StringValue(s.AWSBuild.Spec.IAMRole)
 }
 
+// IAMInstanceProfile returns the instance profile for the instance.
+func (s *AWSBuildScope) IAMInstanceProfile() *string {
+	return s.AWSBuild.Spec.IAMInstanceProfile
+}
+
+// SetIAMInstanceProfile sets the instance profile for the instance.
+func (s *AWSBuildScope) SetIAMInstanceProfile(name *string) {
+	s.AWSBuild.Spec.IAMInstanceProfile = name
+}
+
+// CreateInstanceProfile returns whether a temporary instance profile should be created for the IAM role.
+func (s *AWSBuildScope) CreateInstanceProfile() bool {
+	return s.AWSBuild.Spec.CreateInstanceProfile
+}
+
+// InstanceProfileName returns the name of the instance profile created for the build.
+// IAM names are global to the account, the UID of the build keeps builds of the same name
+// in different namespaces apart.
+func (s *AWSBuildScope) InstanceProfileName() string {
+	suffix := fmt.Sprintf("-forge-%s", s.BuildUID())
+	name := s.Name()
+	if len(name)+len(suffix) > maxInstanceProfileNameLength {
+		name = name[:maxInstanceProfileNameLength-len(suffix)]
+	}
+	return name + suffix
+}
+
 // PatchObject persists the build configuration and status.
 func (s *AWSBuildScope) PatchObject() error {
 	return s.patchHelper.Patch(context.TODO(), s.AWSBuild)
@@ -266,9 +501,64 @@ func (s *AWSBuildScope) IsCleanedUP() bool {
 	return s.AWSBuild.Status.CleanedUP
 }
 
+// SetFailure marks the build as failed for good with the reason and the message.
+func (s *AWSBuildScope) SetFailure(reason, message string) {
+	s.AWSBuild.Status.FailureReason = &reason
+	s.AWSBuild.Status.FailureMessage = &message
+}
+
+// HasFailed checks if the build failed for good.
+func (s *AWSBuildScope) HasFailed() bool {
+	return s.AWSBuild.Status.FailureReason != nil
+}
+
 func (s *AWSBuildScope) CreationDate() string {
 	return s.AWSBuild.CreationTimestamp.Time.Format(time.RFC3339)
 }
+
+// ImageName returns the name of the AMI produced by the build, or nil until it is rendered.
+func (s *AWSBuildScope) ImageName() *string {
+	return s.AWSBuild.Status.ImageName
+}
+
+// SetImageName sets the name of the AMI produced by the build.
+func (s *AWSBuildScope) SetImageName(name string) {
+	s.AWSBuild.Status.ImageName = &name
+}
+
+// SetExpiredImages sets the IDs of the AMIs expired by the retention policy.
+func (s *AWSBuildScope) SetExpiredImages(ids []string) {
+	s.AWSBuild.Status.ExpiredImages = ids
+}
+
+// ImageCopies returns the states of the copies of the AMI to other regions.
+func (s *AWSBuildScope) ImageCopies() []infrav1.ImageCopyStatus {
+	return s.AWSBuild.Status.ImageCopies
+}
+
+// SetImageCopy sets the state of the copy of the AMI to its region.
+func (s *AWSBuildScope) SetImageCopy(status infrav1.ImageCopyStatus) {
+	for i := range s.AWSBuild.Status.ImageCopies {
+		if s.AWSBuild.Status.ImageCopies[i].Region == status.Region {
+			s.AWSBuild.Status.ImageCopies[i] = status
+			return
+		}
+	}
+	s.AWSBuild.Status.ImageCopies = append(s.AWSBuild.Status.ImageCopies, status)
+}
+
+// SharedImages returns the IDs of the AMIs shared with the launch permissions of the image spec.
+func (s *AWSBuildScope) SharedImages() []string {
+	return s.AWSBuild.Status.SharedImages
+}
+
+// AddSharedImage records that the AMI is shared with the launch permissions of the image spec.
+func (s *AWSBuildScope) AddSharedImage(id string) {
+	if !slices.Contains(s.AWSBuild.Status.SharedImages, id) {
+		s.AWSBuild.Status.SharedImages = append(s.AWSBuild.Status.SharedImages, id)
+	}
+}
+
 func (s *AWSBuildScope) SetArtifactRef(reference string) {
 	s.AWSBuild.Status.ArtifactRef = &reference
 }
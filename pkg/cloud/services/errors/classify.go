@@ -0,0 +1,136 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package awserrors
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// Class tells whether a failed AWS call may succeed when it is retried.
+type Class string
+
+const (
+	// ClassTransient errors may go away on their own, the call is retried with backoff.
+	// Errors that are not AWS errors or have an unknown code are transient.
+	ClassTransient Class = "Transient"
+
+	// ClassQuota errors are caused by a quota of the account, the call may succeed
+	// once other resources are released or the quota is raised.
+	ClassQuota Class = "Quota"
+
+	// ClassTerminal errors are caused by the spec or the permissions of the build, retrying the call can't succeed.
+	ClassTerminal Class = "Terminal"
+)
+
+// terminalCodes are the codes of the AWS errors that retrying can't fix.
+var terminalCodes = map[string]bool{
+	// Credentials and permissions
+	"AuthFailure":                 true,
+	"UnauthorizedOperation":       true,
+	"OptInRequired":               true,
+	"Blocked":                     true,
+	"AccessDenied":                true,
+	"AccessDeniedException":       true,
+	"InvalidClientTokenId":        true,
+	"UnrecognizedClientException": true,
+
+	// Invalid requests
+	"InvalidParameter":            true,
+	"InvalidParameterValue":       true,
+	"InvalidParameterCombination": true,
+	"MissingParameter":            true,
+	"ValidationError":             true,
+	"Unsupported":                 true,
+	"UnsupportedOperation":        true,
+	"InvalidBlockDeviceMapping":   true,
+	"InvalidAMIID.NotFound":       true,
+	"InvalidAMIID.Unavailable":    true,
+	"MalformedPolicyDocument":     true,
+}
+
+// TerminalError is an error that retrying can't fix which is not an AWS error, e.g., a failed copy of an AMI.
+type TerminalError struct {
+	// Reason is a short CamelCase description of the error.
+	Reason string
+	Err    error
+}
+
+// NewTerminalError returns a TerminalError with the reason wrapping the error.
+func NewTerminalError(reason string, err error) error {
+	return &TerminalError{Reason: reason, Err: err}
+}
+
+func (e *TerminalError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TerminalError) Unwrap() error {
+	return e.Err
+}
+
+// Code returns the code of the AWS error wrapped by the error, or an empty string if there is none.
+func Code(err error) string {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code()
+	}
+	return ""
+}
+
+// Reason returns the reason of the TerminalError or the code of the AWS error wrapped by the error,
+// or an empty string if there is none.
+func Reason(err error) string {
+	var terminalErr *TerminalError
+	if errors.As(err, &terminalErr) {
+		return terminalErr.Reason
+	}
+	return Code(err)
+}
+
+// Classify returns the class of the error from the code of the AWS error it wraps.
+// Throttled calls are transient even though their code ends with LimitExceeded, TerminalErrors are terminal.
+func Classify(err error) Class {
+	var terminalErr *TerminalError
+	if errors.As(err, &terminalErr) {
+		return ClassTerminal
+	}
+
+	code := Code(err)
+	switch {
+	case code == "" || IsThrottling(err):
+		return ClassTransient
+	case terminalCodes[code] || strings.HasSuffix(code, ".Malformed"):
+		return ClassTerminal
+	case strings.HasSuffix(code, "LimitExceeded") || strings.HasSuffix(code, "QuotaExceeded"):
+		return ClassQuota
+	default:
+		return ClassTransient
+	}
+}
+
+// IsTerminal checks if retrying the call that failed with the error can't succeed.
+func IsTerminal(err error) bool {
+	return Classify(err) == ClassTerminal
+}
+
+// IsQuotaExceeded checks if the error means that a quota of the account is reached.
+func IsQuotaExceeded(err error) bool {
+	return Classify(err) == ClassQuota
+}
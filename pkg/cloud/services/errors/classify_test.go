@@ -0,0 +1,168 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package awserrors
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	pkgerrors "github.com/pkg/errors"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		want       Class
+		wantReason string
+	}{
+		{
+			name: "error without AWS code is transient",
+			err:  errors.New("connection reset by peer"),
+			want: ClassTransient,
+		},
+		{
+			name:       "unknown code is transient",
+			err:        awserr.New("InternalError", "an internal error has occurred", nil),
+			want:       ClassTransient,
+			wantReason: "InternalError",
+		},
+		{
+			name:       "throttled call is transient",
+			err:        awserr.New("Throttling", "rate exceeded", nil),
+			want:       ClassTransient,
+			wantReason: "Throttling",
+		},
+		{
+			name:       "request limit is transient even though it ends with LimitExceeded",
+			err:        awserr.New("RequestLimitExceeded", "request limit exceeded", nil),
+			want:       ClassTransient,
+			wantReason: "RequestLimitExceeded",
+		},
+		{
+			name:       "canceled call is transient",
+			err:        awserr.New(request.CanceledErrorCode, "request context canceled", context.Canceled),
+			want:       ClassTransient,
+			wantReason: request.CanceledErrorCode,
+		},
+		{
+			name:       "instance limit is a quota",
+			err:        awserr.New("InstanceLimitExceeded", "you have requested more instances than your current instance limit", nil),
+			want:       ClassQuota,
+			wantReason: "InstanceLimitExceeded",
+		},
+		{
+			name:       "vCPU limit is a quota",
+			err:        awserr.New("VcpuLimitExceeded", "you have requested more vCPU capacity than your current vCPU limit", nil),
+			want:       ClassQuota,
+			wantReason: "VcpuLimitExceeded",
+		},
+		{
+			name:       "service quota is a quota",
+			err:        awserr.New("ServiceQuotaExceeded", "service quota exceeded", nil),
+			want:       ClassQuota,
+			wantReason: "ServiceQuotaExceeded",
+		},
+		{
+			name:       "missing permission is terminal",
+			err:        awserr.New("UnauthorizedOperation", "you are not authorized to perform this operation", nil),
+			want:       ClassTerminal,
+			wantReason: "UnauthorizedOperation",
+		},
+		{
+			name:       "unsupported configuration is terminal",
+			err:        awserr.New("Unsupported", "the requested configuration is currently not supported", nil),
+			want:       ClassTerminal,
+			wantReason: "Unsupported",
+		},
+		{
+			name:       "invalid parameter is terminal",
+			err:        awserr.New("InvalidParameterValue", "invalid value for instance type", nil),
+			want:       ClassTerminal,
+			wantReason: "InvalidParameterValue",
+		},
+		{
+			name:       "malformed ID is terminal",
+			err:        awserr.New("InvalidSubnetID.Malformed", "invalid subnet ID", nil),
+			want:       ClassTerminal,
+			wantReason: "InvalidSubnetID.Malformed",
+		},
+		{
+			name:       "AWS error wrapped by the services keeps its class",
+			err:        pkgerrors.Wrap(awserr.New("InstanceLimitExceeded", "instance limit", nil), "failed to run instance"),
+			want:       ClassQuota,
+			wantReason: "InstanceLimitExceeded",
+		},
+		{
+			name:       "terminal error is terminal with its reason",
+			err:        pkgerrors.Wrap(NewTerminalError("ImageCopyFailed", errors.New("copy failed")), "failed to copy AMI"),
+			want:       ClassTerminal,
+			wantReason: "ImageCopyFailed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.want {
+				t.Errorf("Classify() = %s, want %s", got, tt.want)
+			}
+			if got := Reason(tt.err); got != tt.wantReason {
+				t.Errorf("Reason() = %q, want %q", got, tt.wantReason)
+			}
+			if got := IsTerminal(tt.err); got != (tt.want == ClassTerminal) {
+				t.Errorf("IsTerminal() = %t, want %t", got, tt.want == ClassTerminal)
+			}
+			if got := IsQuotaExceeded(tt.err); got != (tt.want == ClassQuota) {
+				t.Errorf("IsQuotaExceeded() = %t, want %t", got, tt.want == ClassQuota)
+			}
+		})
+	}
+}
+
+func TestIsRequestCanceled(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "canceled AWS call",
+			err:  awserr.New(request.CanceledErrorCode, "request context canceled", context.Canceled),
+			want: true,
+		},
+		{
+			name: "canceled context",
+			err:  pkgerrors.Wrap(context.Canceled, "failed to wait"),
+			want: true,
+		},
+		{
+			name: "throttled call",
+			err:  awserr.New("RequestLimitExceeded", "request limit exceeded", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRequestCanceled(tt.err); got != tt.want {
+				t.Errorf("IsRequestCanceled() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
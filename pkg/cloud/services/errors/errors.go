@@ -17,19 +17,27 @@ limitations under the License.
 package awserrors
 
 import (
+	"context"
 	"errors"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/iam"
 )
 
 var ErrInstanceNotTerminated = errors.New("the Instance is not terminated yet, Waiting")
 
+var ErrNetworkNotReady = errors.New("the network resources are not ready yet, Waiting")
+
+var ErrNetworkNotDeleted = errors.New("the private network resources are not deleted yet, Waiting")
+
 // IsNotFound checks if the error is a "not found" error for resources.
+// IAM reports missing entities as NoSuchEntity instead of a *NotFound code.
 func IsNotFound(err error) bool {
 	if awsErr, ok := err.(awserr.Error); ok {
 		code := awsErr.Code()
-		return strings.Contains(code, "NotFound")
+		return strings.Contains(code, "NotFound") || code == iam.ErrCodeNoSuchEntityException
 	}
 	return false
 }
@@ -47,3 +55,69 @@ func IgnoreNotFound(err error) error {
 func IsInstanceNotTerminated(err error) bool {
 	return errors.Is(err, ErrInstanceNotTerminated)
 }
+
+func IsNetworkNotReady(err error) bool {
+	return errors.Is(err, ErrNetworkNotReady)
+}
+
+func IsNetworkNotDeleted(err error) bool {
+	return errors.Is(err, ErrNetworkNotDeleted)
+}
+
+// IsDependencyViolation checks if the error means that the resource is still used by another resource.
+func IsDependencyViolation(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == "DependencyViolation"
+}
+
+// IsSpotCapacityError checks if the error means that the Spot request could not be fulfilled.
+func IsSpotCapacityError(err error) bool {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case "InsufficientInstanceCapacity", "SpotMaxPriceTooLow", "MaxSpotInstanceCountExceeded", "UnfulfillableCapacity":
+			return true
+		}
+	}
+	return false
+}
+
+// IsInstanceProfileNotPropagated checks if the error means that EC2 doesn't know the instance profile,
+// which happens for a few seconds after it is created while IAM propagates it.
+func IsInstanceProfileNotPropagated(err error) bool {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code() == "InvalidParameterValue" && strings.Contains(awsErr.Message(), "iamInstanceProfile")
+	}
+	return false
+}
+
+// IsInsufficientCapacity checks if the error means that the instance type can't be launched in the availability zone.
+// Unsupported errors only qualify when they name the availability zone, other unsupported options fail in every zone.
+func IsInsufficientCapacity(err error) bool {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case "InsufficientInstanceCapacity":
+			return true
+		case "Unsupported":
+			return strings.Contains(awsErr.Message(), "Availability Zone")
+		}
+	}
+	return false
+}
+
+// IsThrottling checks if the error means that AWS throttled the call.
+func IsThrottling(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && request.IsErrorThrottle(awsErr)
+}
+
+// IsRequestCanceled checks if the error means that the call was aborted because its context was canceled.
+func IsRequestCanceled(err error) bool {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == request.CanceledErrorCode {
+		return true
+	}
+	return errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
+	"github.com/pkg/errors"
+)
+
+// ImageCopyFailedReason is the failure reason of the builds with a failed copy of their AMI.
+const ImageCopyFailedReason = "ImageCopyFailed"
+
+// reconcileCopies copies the available AMI to the regions of the spec and tracks the state of the copies.
+// It returns true once all the copies are available, and a terminal error once all the copies are done
+// and some of them failed.
+func (s *Service) reconcileCopies(ctx context.Context, amiID, amiName string) (bool, error) {
+	targets := s.scope.ImageSpec().CopyToRegions
+	if len(targets) == 0 {
+		return true, nil
+	}
+
+	var tags map[string]string
+	done := true
+	var failed []string
+	for _, target := range targets {
+		status := s.imageCopy(target.Region)
+		if status.ImageID == "" {
+			if tags == nil {
+				var err error
+				if tags, err = s.copyTags(ctx, amiID, amiName); err != nil {
+					return false, err
+				}
+			}
+
+			s.Log.Info("Copying AMI to region", "AMI ID", amiID, "region", target.Region)
+			copyID, err := s.Client.CopyAMI(ctx, awsforge.CopyAMIParams{
+				SourceImageID: amiID,
+				Name:          amiName,
+				Region:        target.Region,
+				KMSKeyID:      target.KMSKeyID,
+				Tags:          tags,
+			})
+			if err != nil {
+				return false, err
+			}
+			s.scope.SetImageCopy(infrav1.ImageCopyStatus{Region: target.Region, ImageID: copyID, State: ec2.ImageStatePending})
+			done = false
+			continue
+		}
+
+		image, err := s.Client.FindRegionalAMI(ctx, target.Region, status.ImageID)
+		if err != nil {
+			return false, err
+		}
+		// A new copy may not be visible yet
+		if image == nil {
+			done = false
+			continue
+		}
+
+		status.State = aws.StringValue(image.State)
+		switch status.State {
+		case ec2.ImageStateAvailable:
+		case ec2.ImageStateFailed, ec2.ImageStateError, ec2.ImageStateInvalid, ec2.ImageStateDeregistered:
+			if image.StateReason != nil {
+				status.FailureMessage = aws.StringValue(image.StateReason.Message)
+			}
+			failed = append(failed, target.Region)
+		default:
+			done = false
+		}
+		s.scope.SetImageCopy(status)
+	}
+
+	if !done {
+		s.Log.Info("Waiting for the copies of the AMI", "AMI ID", amiID)
+		return false, nil
+	}
+	if len(failed) > 0 {
+		return false, awserrors.NewTerminalError(ImageCopyFailedReason,
+			errors.Errorf("copies of AMI %s failed in regions %s", amiID, strings.Join(failed, ", ")))
+	}
+	return true, nil
+}
+
+// imageCopy returns the status of the copy to the region, empty when it was not copied yet.
+func (s *Service) imageCopy(region string) infrav1.ImageCopyStatus {
+	for _, status := range s.scope.ImageCopies() {
+		if status.Region == region {
+			return status
+		}
+	}
+	return infrav1.ImageCopyStatus{Region: region}
+}
+
+// copyTags returns the tags of the AMI that can be set on its copies, the tags prefixed with aws: are reserved.
+func (s *Service) copyTags(ctx context.Context, amiID, amiName string) (map[string]string, error) {
+	images, err := s.Client.ListAMIs(ctx, amiName)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	for _, image := range images {
+		if aws.StringValue(image.ImageId) != amiID {
+			continue
+		}
+		for _, tag := range image.Tags {
+			if !strings.HasPrefix(aws.StringValue(tag.Key), "aws:") {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+		}
+	}
+	return tags, nil
+}
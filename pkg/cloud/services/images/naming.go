@@ -0,0 +1,162 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultNameTemplate names the AMI after the build.
+	defaultNameTemplate = "{{.BuildName}}"
+
+	// AMI names are 3 to 128 characters long.
+	minImageNameLength = 3
+	maxImageNameLength = 128
+
+	// nameHashLength is the length of the hash shortening the names that are too long.
+	nameHashLength = 8
+
+	// timestampLayout formats the Timestamp of the template with characters allowed in AMI names.
+	timestampLayout = "20060102150405"
+)
+
+// invalidImageNameChars matches the characters AWS does not allow in AMI names.
+var invalidImageNameChars = regexp.MustCompile(`[^a-zA-Z0-9()\[\] ./'@_-]`)
+
+// nameTemplateData are the values the name template is rendered with.
+type nameTemplateData struct {
+	BuildName string
+	Namespace string
+	BuildUID  string
+	SourceAMI string
+	Timestamp string
+}
+
+// imageName returns the name of the AMI of the build, rendering it on the first call.
+// The name is suffixed with the UID of the build when another AMI already has it,
+// unless the previous AMIs are deregistered.
+func (s *Service) imageName(ctx context.Context) (string, error) {
+	if name := s.scope.ImageName(); name != nil {
+		return *name, nil
+	}
+
+	spec := s.scope.ImageSpec()
+	name, err := s.renderImageName(spec.NameTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	if !spec.DeregisterPrevious {
+		taken, err := s.isImageNameTaken(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		if taken {
+			uid := s.scope.BuildUID()
+			if len(uid) > nameHashLength {
+				uid = uid[:nameHashLength]
+			}
+			suffixed := shortenImageName(name, maxImageNameLength-len(uid)-1) + "-" + uid
+			s.Log.Info("AMI name is already used, suffixing it with the build UID", "imageName", name, "newImageName", suffixed)
+			name = suffixed
+		}
+	}
+
+	s.scope.SetImageName(name)
+	return name, nil
+}
+
+// renderImageName renders the template and makes the result a valid AMI name.
+func (s *Service) renderImageName(nameTemplate string) (string, error) {
+	if nameTemplate == "" {
+		nameTemplate = defaultNameTemplate
+	}
+	tmpl, err := template.New("imageName").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid image name template")
+	}
+
+	creationTime, err := time.Parse(time.RFC3339, s.scope.CreationDate())
+	if err != nil {
+		return "", errors.Wrap(err, "invalid build creation date")
+	}
+
+	var rendered strings.Builder
+	err = tmpl.Execute(&rendered, nameTemplateData{
+		BuildName: s.scope.Name(),
+		Namespace: s.scope.Namespace(),
+		BuildUID:  s.scope.BuildUID(),
+		SourceAMI: aws.StringValue(s.scope.SourceAMI()),
+		Timestamp: creationTime.UTC().Format(timestampLayout),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to render image name template")
+	}
+
+	name := invalidImageNameChars.ReplaceAllString(strings.TrimSpace(rendered.String()), "-")
+	if len(name) < minImageNameLength {
+		return "", errors.Errorf("image name %q rendered from template %q is shorter than %d characters", name, nameTemplate, minImageNameLength)
+	}
+	return shortenImageName(name, maxImageNameLength), nil
+}
+
+// isImageNameTaken checks if an AMI of the account that was not produced by the build has the name.
+func (s *Service) isImageNameTaken(ctx context.Context, name string) (bool, error) {
+	images, err := s.Client.ListAMIs(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	for _, image := range images {
+		if aws.StringValue(image.State) == ec2.ImageStateDeregistered {
+			continue
+		}
+		if !hasTag(image.Tags, buildUIDTagKey, s.scope.BuildUID()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// shortenImageName cuts the name to the length, replacing its end with a hash of the whole name
+// so that long names sharing a prefix don't collide.
+func shortenImageName(name string, length int) string {
+	if len(name) <= length {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	return name[:length-nameHashLength-1] + "-" + hex.EncodeToString(sum[:])[:nameHashLength]
+}
+
+func hasTag(tags []*ec2.Tag, key, value string) bool {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key && aws.StringValue(tag.Value) == value {
+			return true
+		}
+	}
+	return false
+}
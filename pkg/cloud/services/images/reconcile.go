@@ -19,6 +19,7 @@ package images
 import (
 	"context"
 
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
 	"github.com/pkg/errors"
 )
 
@@ -37,14 +38,18 @@ func (s *Service) Reconcile(ctx context.Context) error {
 		return errors.New("instance ID is not set, cannot create image")
 	}
 
-	amiName := s.scope.Name()
-
-	// Ensure no existing AMI conflicts
-	s.Log.V(1).Info("Ensuring no existing AMI conflicts", "imageName", amiName)
-	if err := s.Client.EnsureAMIDoesNotExist(ctx, amiName, s.scope.CreationDate()); err != nil {
+	amiName, err := s.imageName(ctx)
+	if err != nil {
 		return err
 	}
 
+	if s.scope.ImageSpec().DeregisterPrevious {
+		s.Log.V(1).Info("Deregistering previous AMIs with the same name", "imageName", amiName)
+		if err := s.Client.EnsureAMIDoesNotExist(ctx, amiName, s.scope.CreationDate()); err != nil {
+			return err
+		}
+	}
+
 	amiID, amiState, err := s.Client.CheckAMIStatus(ctx, amiName)
 	if err != nil {
 		return err
@@ -52,13 +57,31 @@ func (s *Service) Reconcile(ctx context.Context) error {
 
 	switch amiState {
 	case "available":
-		s.scope.SetArtifactRef(amiID)
 		s.Log.Info("AMI is already available", "AMI ID", amiID)
+		if err := s.applyRetention(ctx, amiID); err != nil {
+			return err
+		}
+		copied, err := s.reconcileCopies(ctx, amiID, amiName)
+		if err != nil || !copied {
+			return err
+		}
+		if err := s.shareImages(ctx, amiID); err != nil {
+			return err
+		}
+		s.scope.SetArtifactRef(amiID)
 	case "pending":
 		s.Log.Info("AMI is still being created, waiting for readiness", "AMI ID", amiID)
 	default:
-		s.Log.Info("Creating AMI object...", amiName)
-		err := s.Client.CreateAMI(ctx, *instanceID, amiName)
+		s.Log.Info("Creating AMI object...", "imageName", amiName)
+		tags, err := s.imageTags(ctx, *instanceID, amiName)
+		if err != nil {
+			return err
+		}
+		err = s.Client.CreateAMI(ctx, awsforge.CreateAMIParams{
+			InstanceID: *instanceID,
+			Name:       amiName,
+			Tags:       tags,
+		})
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,448 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+	"github.com/forge-build/forge-provider-aws/pkg/aws/fake"
+	"github.com/forge-build/forge-provider-aws/pkg/cloud/scope"
+	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
+)
+
+const (
+	// maxReconciles is the number of reconciles the AMI of the build has to be available in.
+	maxReconciles = 5
+
+	copyRegion = "us-west-2"
+	accountID  = "111122223333"
+)
+
+// fixture holds the instance the AMI of the build is created from.
+type fixture struct {
+	client   *fake.AWSClient
+	awsBuild *infrav1.AWSBuild
+	amiID    string
+
+	// provisionersReady is the readiness of the provisioners of the Build.
+	provisionersReady bool
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, f *fixture)
+		// beforeReconcile runs before each reconcile, e.g., to change the AMIs while they are created.
+		beforeReconcile func(t *testing.T, f *fixture)
+		errors          map[string]error
+		wantErr         string
+		// wantReason is the reason of the terminal error or the code of the AWS error.
+		wantReason string
+		// wantName is the name of the AMI of the build, no AMI is created when it is empty.
+		wantName string
+		verify   func(t *testing.T, f *fixture, image *ec2.Image)
+	}{
+		{
+			name:     "AMI of the instance is created and tagged",
+			wantName: "build",
+			verify: func(t *testing.T, f *fixture, image *ec2.Image) {
+				for key, value := range map[string]string{
+					"Name":           "build",
+					"forge-managed":  "true",
+					buildUIDTagKey:   "build-uid",
+					sourceAMITagKey:  f.amiID,
+					"image-family":   "base",
+					"forge.build/os": "linux",
+				} {
+					if !hasTag(image.Tags, key, value) {
+						t.Errorf("AMI has no tag %s=%s, tags: %v", key, value, image.Tags)
+					}
+				}
+				if hasTag(image.Tags, "team", "images") {
+					t.Error("AMI is tagged with a label of the build outside of the prefix")
+				}
+			},
+		},
+		{
+			name: "AMI is not created before the provisioners are ready",
+			setup: func(t *testing.T, f *fixture) {
+				f.provisionersReady = false
+			},
+		},
+		{
+			name: "AMI is named with the template",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.Image = &infrav1.ImageSpec{NameTemplate: "{{.Namespace}}/{{.BuildName}} {{.Timestamp}}"}
+			},
+			wantName: "default/build 20240102030405",
+		},
+		{
+			name: "AMI name used by another build is suffixed with the UID of the build",
+			setup: func(t *testing.T, f *fixture) {
+				f.client.AddImage(&ec2.Image{Name: aws.String("build")})
+			},
+			wantName: "build-build-ui",
+		},
+		{
+			name: "previous AMIs with the name are deregistered",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.Image = &infrav1.ImageSpec{DeregisterPrevious: true}
+				previous := f.client.AddImage(&ec2.Image{Name: aws.String("build"), CreationDate: aws.String("2024-01-01T00:00:00Z")})
+				t.Cleanup(func() {
+					if f.client.Image(previous) != nil {
+						t.Errorf("previous AMI %s is not deregistered", previous)
+					}
+				})
+			},
+			wantName: "build",
+		},
+		{
+			name: "AMI is copied to the regions",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.Image = &infrav1.ImageSpec{CopyToRegions: []infrav1.ImageCopy{{Region: copyRegion}}}
+			},
+			wantName: "build",
+			verify: func(t *testing.T, f *fixture, image *ec2.Image) {
+				copies := f.awsBuild.Status.ImageCopies
+				if len(copies) != 1 || copies[0].Region != copyRegion || copies[0].State != ec2.ImageStateAvailable {
+					t.Fatalf("image copies = %+v, want an available copy in %s", copies, copyRegion)
+				}
+				if f.client.Regional(copyRegion).Image(copies[0].ImageID) == nil {
+					t.Errorf("copy %s not found in %s", copies[0].ImageID, copyRegion)
+				}
+			},
+		},
+		{
+			name: "failed copy of the AMI is terminal",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.Image = &infrav1.ImageSpec{CopyToRegions: []infrav1.ImageCopy{{Region: copyRegion}}}
+			},
+			beforeReconcile: func(t *testing.T, f *fixture) {
+				for _, status := range f.awsBuild.Status.ImageCopies {
+					if err := f.client.Regional(status.Region).FailImage(status.ImageID, "The snapshot could not be copied."); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			wantErr:    "failed in regions " + copyRegion,
+			wantReason: ImageCopyFailedReason,
+		},
+		{
+			name: "AMI and its copies are shared",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.Image = &infrav1.ImageSpec{
+					CopyToRegions:     []infrav1.ImageCopy{{Region: copyRegion}},
+					LaunchPermissions: &infrav1.LaunchPermissions{AccountIDs: []string{accountID}},
+				}
+			},
+			wantName: "build",
+			verify: func(t *testing.T, f *fixture, image *ec2.Image) {
+				want := []*ec2.LaunchPermission{{UserId: aws.String(accountID)}}
+				if permissions := f.client.LaunchPermissions(aws.StringValue(image.ImageId)); !reflect.DeepEqual(permissions, want) {
+					t.Errorf("AMI launch permissions = %v, want %v", permissions, want)
+				}
+				copyID := f.awsBuild.Status.ImageCopies[0].ImageID
+				if permissions := f.client.Regional(copyRegion).LaunchPermissions(copyID); !reflect.DeepEqual(permissions, want) {
+					t.Errorf("copy launch permissions = %v, want %v", permissions, want)
+				}
+				if shared := f.awsBuild.Status.SharedImages; !reflect.DeepEqual(shared, []string{aws.StringValue(image.ImageId), copyID}) {
+					t.Errorf("shared images = %v, want the AMI and its copy", shared)
+				}
+			},
+		},
+		{
+			name: "retention policy removes the older AMIs it doesn't keep",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.Image = &infrav1.ImageSpec{
+					Tags:      map[string]string{"image-family": "base"},
+					Retention: &infrav1.ImageRetentionPolicy{MatchTags: map[string]string{"image-family": "base"}, KeepTags: map[string]string{"pinned": "true"}},
+				}
+				older, newer := retainedImages(f)
+				pinned := f.client.AddImage(&ec2.Image{Name: aws.String("pinned"), CreationDate: aws.String("2023-01-01T00:00:00Z"), Tags: []*ec2.Tag{
+					{Key: aws.String("forge-managed"), Value: aws.String("true")},
+					{Key: aws.String("image-family"), Value: aws.String("base")},
+					{Key: aws.String("pinned"), Value: aws.String("true")},
+				}})
+				t.Cleanup(func() {
+					if f.client.Image(older) != nil {
+						t.Errorf("older AMI %s is not removed", older)
+					}
+					if f.client.Image(pinned) == nil {
+						t.Errorf("pinned AMI %s is removed", pinned)
+					}
+					if f.client.Image(newer) == nil {
+						t.Errorf("AMI %s created after the AMI of the build is removed", newer)
+					}
+					if expired := f.awsBuild.Status.ExpiredImages; !reflect.DeepEqual(expired, []string{older}) {
+						t.Errorf("expired images = %v, want [%s]", expired, older)
+					}
+				})
+			},
+			wantName: "build",
+		},
+		{
+			name: "retention policy removes the older copies of the AMIs it doesn't keep with their snapshots",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.Image = &infrav1.ImageSpec{
+					Tags:          map[string]string{"image-family": "base"},
+					CopyToRegions: []infrav1.ImageCopy{{Region: copyRegion}},
+					Retention:     &infrav1.ImageRetentionPolicy{MatchTags: map[string]string{"image-family": "base"}, KeepLast: aws.Int32(2)},
+				}
+				tags := []*ec2.Tag{
+					{Key: aws.String("forge-managed"), Value: aws.String("true")},
+					{Key: aws.String("image-family"), Value: aws.String("base")},
+				}
+				regional := f.client.Regional(copyRegion)
+				oldest := regional.AddImage(&ec2.Image{Name: aws.String("oldest"), CreationDate: aws.String("2023-01-01T00:00:00Z"), Tags: tags,
+					BlockDeviceMappings: []*ec2.BlockDeviceMapping{{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2.EbsBlockDevice{SnapshotId: aws.String("snap-oldest")}}},
+				})
+				older := regional.AddImage(&ec2.Image{Name: aws.String("older"), CreationDate: aws.String("2024-01-01T00:00:00Z"), Tags: tags})
+				t.Cleanup(func() {
+					if regional.Image(oldest) != nil {
+						t.Errorf("oldest copy %s is not removed", oldest)
+					}
+					if regional.Snapshot("snap-oldest") != nil {
+						t.Errorf("snapshot of the oldest copy %s is not removed", oldest)
+					}
+					if regional.Image(older) == nil {
+						t.Errorf("copy %s kept by keepLast is removed", older)
+					}
+					if expired := f.awsBuild.Status.ExpiredImages; !reflect.DeepEqual(expired, []string{oldest}) {
+						t.Errorf("expired images = %v, want [%s]", expired, oldest)
+					}
+				})
+			},
+			wantName: "build",
+		},
+		{
+			name: "retention policy revokes the sharing of the copies it removes",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.Image = &infrav1.ImageSpec{
+					Tags:          map[string]string{"image-family": "base"},
+					CopyToRegions: []infrav1.ImageCopy{{Region: copyRegion}},
+					Retention:     &infrav1.ImageRetentionPolicy{MatchTags: map[string]string{"image-family": "base"}, KeepLast: aws.Int32(1)},
+				}
+				regional := f.client.Regional(copyRegion)
+				older := regional.AddImage(&ec2.Image{Name: aws.String("older"), CreationDate: aws.String("2024-01-01T00:00:00Z"),
+					Tags: []*ec2.Tag{
+						{Key: aws.String("forge-managed"), Value: aws.String("true")},
+						{Key: aws.String("image-family"), Value: aws.String("base")},
+					},
+					BlockDeviceMappings: []*ec2.BlockDeviceMapping{{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2.EbsBlockDevice{SnapshotId: aws.String("snap-older"), Encrypted: aws.Bool(true)}}},
+				})
+				if err := f.client.ShareAMI(context.Background(), copyRegion, older, infrav1.LaunchPermissions{AccountIDs: []string{accountID}}); err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() {
+					if regional.Image(older) != nil {
+						t.Errorf("older copy %s is not removed", older)
+					}
+					if permissions := regional.CreateVolumePermissions("snap-older"); len(permissions) > 0 {
+						t.Errorf("create volume permissions of the snapshot of the older copy = %v, want them revoked", permissions)
+					}
+				})
+			},
+			wantName: "build",
+		},
+		{
+			name: "retention policy in dry run only reports the AMIs it would remove",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.Image = &infrav1.ImageSpec{
+					Retention: &infrav1.ImageRetentionPolicy{MatchTags: map[string]string{"image-family": "base"}, KeepTags: map[string]string{"pinned": "true"}, DryRun: true},
+				}
+				older, _ := retainedImages(f)
+				t.Cleanup(func() {
+					if f.client.Image(older) == nil {
+						t.Errorf("AMI %s is removed in dry run", older)
+					}
+					if expired := f.awsBuild.Status.ExpiredImages; !reflect.DeepEqual(expired, []string{older}) {
+						t.Errorf("expired images = %v, want [%s]", expired, older)
+					}
+				})
+			},
+			wantName: "build",
+		},
+		{
+			name: "build without instance is an error",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.InstanceID = nil
+			},
+			wantErr: "instance ID is not set",
+		},
+		{
+			name:       "AMI creation error is returned",
+			errors:     map[string]error{"CreateAMI": awserr.New("InvalidInstanceID.Malformed", "The instance ID is malformed.", nil)},
+			wantErr:    "failed to create AMI",
+			wantReason: "InvalidInstanceID.Malformed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t)
+			if tt.setup != nil {
+				tt.setup(t, f)
+			}
+			for method, err := range tt.errors {
+				f.client.Errors[method] = err
+			}
+
+			var err error
+			for i := 0; i < maxReconciles && f.awsBuild.Status.ArtifactRef == nil && err == nil; i++ {
+				if tt.beforeReconcile != nil {
+					tt.beforeReconcile(t, f)
+				}
+				err = New(newScope(t, f)).Reconcile(ctx)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Reconcile() error = %v, want %q", err, tt.wantErr)
+				}
+				if reason := awserrors.Reason(err); reason != tt.wantReason {
+					t.Errorf("Reconcile() error reason = %q, want %q", reason, tt.wantReason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			if tt.wantName == "" {
+				if ref := f.awsBuild.Status.ArtifactRef; ref != nil {
+					t.Fatalf("artifact ref = %s, want no AMI", *ref)
+				}
+				return
+			}
+			if f.awsBuild.Status.ArtifactRef == nil {
+				t.Fatalf("AMI is not available after %d reconciles", maxReconciles)
+			}
+			image := f.client.Image(*f.awsBuild.Status.ArtifactRef)
+			if image == nil {
+				t.Fatalf("AMI %s not found", *f.awsBuild.Status.ArtifactRef)
+			}
+			if name := aws.StringValue(image.Name); name != tt.wantName {
+				t.Errorf("AMI name = %q, want %q", name, tt.wantName)
+			}
+			if name := aws.StringValue(f.awsBuild.Status.ImageName); name != tt.wantName {
+				t.Errorf("image name = %q, want %q", name, tt.wantName)
+			}
+			if tt.verify != nil {
+				tt.verify(t, f, image)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	for i := 0; i < maxReconciles && f.awsBuild.Status.ArtifactRef == nil; i++ {
+		if err := New(newScope(t, f)).Reconcile(ctx); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+	}
+	if f.awsBuild.Status.ArtifactRef == nil {
+		t.Fatalf("AMI is not available after %d reconciles", maxReconciles)
+	}
+
+	if err := New(newScope(t, f)).Delete(ctx); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if f.client.Image(*f.awsBuild.Status.ArtifactRef) == nil {
+		t.Error("AMI produced by the build is deleted with the build")
+	}
+}
+
+// newFixture returns a build with a provisioned instance to create the AMI from.
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	f := &fixture{
+		client: fake.New(),
+		awsBuild: &infrav1.AWSBuild{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "build",
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+			},
+			Spec: infrav1.AWSBuildSpec{
+				Region:       fake.DefaultRegion,
+				InstanceType: "t3.micro",
+			},
+		},
+		provisionersReady: true,
+	}
+
+	vpc, err := f.client.CreateVPC(ctx, newScope(t, f).VPCSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	subnet, err := f.client.CreateSubnet(ctx, awsforge.CreateSubnetParams{VPCName: "build-forge-vpc", VPCID: vpc.VpcId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.amiID = f.client.AddImage(&ec2.Image{Name: aws.String("base")})
+	instance, err := f.client.CreateInstance(ctx, awsforge.CreateInstanceParams{
+		Name:         "build",
+		InstanceType: "t3.micro",
+		AmiID:        f.amiID,
+		SubnetID:     aws.StringValue(subnet.SubnetId),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.awsBuild.Spec.InstanceID = instance.InstanceId
+	f.awsBuild.Spec.Image = &infrav1.ImageSpec{
+		Tags:             map[string]string{"image-family": "base"},
+		BuildLabelPrefix: "forge.build/",
+	}
+	f.awsBuild.Status.SourceAMI = aws.String(f.amiID)
+	return f
+}
+
+// newScope returns the scope of the build of the fixture, labeled like a build of the images team.
+func newScope(t *testing.T, f *fixture) *scope.AWSBuildScope {
+	t.Helper()
+	build := fake.NewBuild()
+	build.Labels = map[string]string{"forge.build/os": "linux", "team": "images"}
+	build.Status.ProvisionersReady = f.provisionersReady
+	return fake.NewScope(t, f.client, build, f.awsBuild)
+}
+
+// retainedImages adds an AMI of the image family created before the AMI of the build and one created after it.
+func retainedImages(f *fixture) (older, newer string) {
+	tags := []*ec2.Tag{
+		{Key: aws.String("forge-managed"), Value: aws.String("true")},
+		{Key: aws.String("image-family"), Value: aws.String("base")},
+	}
+	older = f.client.AddImage(&ec2.Image{Name: aws.String("older"), CreationDate: aws.String("2024-01-01T00:00:00Z"), Tags: tags})
+	newer = f.client.AddImage(&ec2.Image{Name: aws.String("newer"), CreationDate: aws.String("2100-01-01T00:00:00Z"), Tags: tags})
+	return older, newer
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+// applyRetention removes the AMIs selected by the retention policy that none of its rules keeps,
+// with their snapshots, once the AMI of the build is available. The policy applies in the region of the build
+// and in each region the AMIs are copied to.
+// The AMI of the build and the AMIs created after it are always kept, the newer AMIs belong to other
+// builds that may not have recorded them yet.
+func (s *Service) applyRetention(ctx context.Context, amiID string) error {
+	policy := s.scope.ImageSpec().Retention
+	if policy == nil || s.scope.IsConditionTrue(infrav1.ImageRetentionCondition) {
+		return nil
+	}
+
+	build, err := s.Client.FindRegionalAMI(ctx, s.scope.Region(), amiID)
+	if err != nil {
+		return err
+	}
+	if build == nil {
+		return errors.Errorf("AMI %s not found, cannot apply the retention policy", amiID)
+	}
+
+	expired, err := s.removeExpiredImages(ctx, "", build, *policy)
+	if err != nil {
+		return err
+	}
+	for _, target := range s.scope.ImageSpec().CopyToRegions {
+		regionExpired, err := s.removeExpiredImages(ctx, target.Region, build, *policy)
+		if err != nil {
+			return err
+		}
+		expired = append(expired, regionExpired...)
+	}
+
+	s.scope.SetExpiredImages(expired)
+	s.scope.MarkConditionTrue(infrav1.ImageRetentionCondition)
+	return nil
+}
+
+// removeExpiredImages removes the AMIs of the region, the region of the build when empty, that the policy
+// doesn't keep and returns their IDs.
+func (s *Service) removeExpiredImages(ctx context.Context, region string, build *ec2.Image, policy infrav1.ImageRetentionPolicy) ([]string, error) {
+	images, err := s.Client.ListManagedAMIs(ctx, region, policy.MatchTags)
+	if err != nil {
+		return nil, err
+	}
+	// The copy of the AMI of the build is made after the retention, the AMI stands for it in the other regions
+	// so that the newest AMIs are kept in every region
+	if region != "" {
+		images = append(images, build)
+	}
+
+	var expired []string
+	for _, image := range expiredImages(images, build, policy, time.Now()) {
+		id := aws.StringValue(image.ImageId)
+		expired = append(expired, id)
+		if policy.DryRun {
+			s.Log.Info("AMI would be removed by the retention policy", "AMI ID", id, "imageName", aws.StringValue(image.Name), "region", region)
+			continue
+		}
+
+		s.Log.Info("Removing AMI expired by the retention policy", "AMI ID", id, "imageName", aws.StringValue(image.Name), "region", region)
+		if err := s.Client.UnshareAMI(ctx, region, image); err != nil {
+			return nil, errors.Wrapf(err, "failed to unshare AMI %s expired by the retention policy", id)
+		}
+		if err := s.Client.DeleteAMI(ctx, region, image); err != nil {
+			return nil, errors.Wrapf(err, "failed to remove AMI %s expired by the retention policy", id)
+		}
+	}
+	return expired, nil
+}
+
+// expiredImages returns the images created before the image of the build that none of the rules of the policy keeps,
+// from the oldest.
+func expiredImages(images []*ec2.Image, build *ec2.Image, policy infrav1.ImageRetentionPolicy, now time.Time) []*ec2.Image {
+	sorted := make([]*ec2.Image, len(images))
+	copy(sorted, images)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return imageCreationTime(sorted[i]).After(imageCreationTime(sorted[j]))
+	})
+
+	var expired []*ec2.Image
+	for i, image := range sorted {
+		switch {
+		case aws.StringValue(image.ImageId) == aws.StringValue(build.ImageId):
+		case !imageCreationTime(image).Before(imageCreationTime(build)):
+		case policy.KeepLast != nil && i < int(*policy.KeepLast):
+		case policy.KeepNewerThan != nil && now.Sub(imageCreationTime(image)) < policy.KeepNewerThan.Duration:
+		case len(policy.KeepTags) > 0 && hasTags(image.Tags, policy.KeepTags):
+		default:
+			expired = append(expired, image)
+		}
+	}
+
+	// Remove the oldest first so that an interrupted run keeps the newest AMIs
+	for i, j := 0, len(expired)-1; i < j; i, j = i+1, j-1 {
+		expired[i], expired[j] = expired[j], expired[i]
+	}
+	return expired
+}
+
+// imageCreationTime returns the creation time of the image, or the zero time when it can't be parsed.
+func imageCreationTime(image *ec2.Image) time.Time {
+	creationTime, err := time.Parse(time.RFC3339, aws.StringValue(image.CreationDate))
+	if err != nil {
+		return time.Time{}
+	}
+	return creationTime
+}
+
+func hasTags(tags []*ec2.Tag, expected map[string]string) bool {
+	for key, value := range expected {
+		if !hasTag(tags, key, value) {
+			return false
+		}
+	}
+	return true
+}
@@ -21,7 +21,10 @@ import (
 
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/go-logr/logr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
 	"github.com/forge-build/forge-provider-aws/pkg/cloud"
 )
 
@@ -29,10 +32,17 @@ const ServiceName = "Image Reconciler"
 
 // instancesInterface defines the EC2 operations needed for instances.
 type instancesInterface interface {
-	CreateAMI(ctx context.Context, instanceID, imageName string) error
+	CreateAMI(ctx context.Context, params awsforge.CreateAMIParams) error
+	FindInstanceByID(ctx context.Context, instanceID *string) (*ec2.Instance, error)
 	EnsureAMIDoesNotExist(ctx context.Context, imageName, creationDate string) error
 	ListAMIs(ctx context.Context, imageName string) ([]*ec2.Image, error)
 	CheckAMIStatus(ctx context.Context, imageName string) (string, string, error)
+	ListManagedAMIs(ctx context.Context, region string, tags map[string]string) ([]*ec2.Image, error)
+	DeleteAMI(ctx context.Context, region string, image *ec2.Image) error
+	CopyAMI(ctx context.Context, params awsforge.CopyAMIParams) (string, error)
+	FindRegionalAMI(ctx context.Context, region, imageID string) (*ec2.Image, error)
+	ShareAMI(ctx context.Context, region, imageID string, permissions infrav1.LaunchPermissions) error
+	UnshareAMI(ctx context.Context, region string, image *ec2.Image) error
 }
 
 // Scope defines the methods needed from the calling context (e.g., BuildScope).
@@ -43,6 +53,20 @@ type Scope interface {
 	IsReady() bool
 	SetArtifactRef(reference string)
 	CreationDate() string
+	Namespace() string
+	BuildUID() string
+	BuildLabels() map[string]string
+	SourceAMI() *string
+	ImageSpec() infrav1.ImageSpec
+	ImageName() *string
+	SetImageName(name string)
+	SetExpiredImages(ids []string)
+	IsConditionTrue(t clusterv1.ConditionType) bool
+	MarkConditionTrue(t clusterv1.ConditionType)
+	ImageCopies() []infrav1.ImageCopyStatus
+	SetImageCopy(status infrav1.ImageCopyStatus)
+	SharedImages() []string
+	AddSharedImage(id string)
 }
 
 // Service implements networks reconciler.
@@ -0,0 +1,51 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"context"
+	"slices"
+)
+
+// shareImages grants the launch permissions of the spec on the AMI and on its copies.
+// AMIs that were already shared are skipped.
+func (s *Service) shareImages(ctx context.Context, amiID string) error {
+	permissions := s.scope.ImageSpec().LaunchPermissions
+	if permissions == nil {
+		return nil
+	}
+
+	// The copies are shared in their region, the AMI in the region of the client
+	regions := map[string]string{amiID: ""}
+	ids := []string{amiID}
+	for _, status := range s.scope.ImageCopies() {
+		regions[status.ImageID] = status.Region
+		ids = append(ids, status.ImageID)
+	}
+
+	for _, id := range ids {
+		if slices.Contains(s.scope.SharedImages(), id) {
+			continue
+		}
+		s.Log.Info("Sharing AMI", "AMI ID", id, "region", regions[id])
+		if err := s.Client.ShareAMI(ctx, regions[id], id, *permissions); err != nil {
+			return err
+		}
+		s.scope.AddSharedImage(id)
+	}
+	return nil
+}
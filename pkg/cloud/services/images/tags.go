@@ -0,0 +1,73 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+// Tags set by the controller on the AMI and its snapshots.
+const (
+	buildNameTagKey      = "forge-build-name"
+	buildNamespaceTagKey = "forge-build-namespace"
+	buildUIDTagKey       = "forge-build-uid"
+	sourceAMITagKey      = "forge-source-ami"
+	instanceTypeTagKey   = "forge-instance-type"
+	creationTimeTagKey   = "forge-creation-time"
+)
+
+// imageTags returns the tags of the AMI created from the instance.
+// The labels of the build selected by the prefix come first, then the tags of the spec and the tags of the controller.
+func (s *Service) imageTags(ctx context.Context, instanceID, imageName string) (map[string]string, error) {
+	instance, err := s.Client.FindInstanceByID(ctx, aws.String(instanceID))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe instance %s", instanceID)
+	}
+	if instance == nil {
+		return nil, errors.Errorf("instance %s not found, cannot create image", instanceID)
+	}
+
+	spec := s.scope.ImageSpec()
+	tags := map[string]string{}
+	if spec.BuildLabelPrefix != "" {
+		for key, value := range s.scope.BuildLabels() {
+			if strings.HasPrefix(key, spec.BuildLabelPrefix) {
+				tags[key] = value
+			}
+		}
+	}
+	for key, value := range spec.Tags {
+		tags[key] = value
+	}
+
+	tags["Name"] = imageName
+	tags["forge-managed"] = "true"
+	tags[infrav1.BuildTagKey(s.scope.Name())] = string(infrav1.ResourceLifecycleOwned)
+	tags[buildNameTagKey] = s.scope.Name()
+	tags[buildNamespaceTagKey] = s.scope.Namespace()
+	tags[buildUIDTagKey] = s.scope.BuildUID()
+	tags[sourceAMITagKey] = aws.StringValue(s.scope.SourceAMI())
+	tags[instanceTypeTagKey] = aws.StringValue(instance.InstanceType)
+	tags[creationTimeTagKey] = time.Now().UTC().Format(time.RFC3339)
+	return tags, nil
+}
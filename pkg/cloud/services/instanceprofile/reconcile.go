@@ -0,0 +1,98 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instanceprofile
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
+	"github.com/pkg/errors"
+)
+
+// Reconcile resolves the IAM role of the build to an instance profile.
+func (s *Service) Reconcile(ctx context.Context) error {
+	s.Log.V(1).Info("Reconciling IAM instance profile")
+
+	if profile := s.scope.IAMInstanceProfile(); profile != nil {
+		s.Log.V(1).Info("Using instance profile", "InstanceProfile", *profile)
+		return nil
+	}
+
+	role := s.scope.IAMRole()
+	if role == "" {
+		s.Log.V(1).Info("No IAM role specified, skipping instance profile")
+		return nil
+	}
+
+	if s.scope.CreateInstanceProfile() {
+		profileName := s.scope.InstanceProfileName()
+		s.Log.Info("Creating instance profile for IAM role", "InstanceProfile", profileName, "IAMRole", role)
+		profile, err := s.Client.CreateInstanceProfileForRole(ctx, profileName, role)
+		if err != nil {
+			return errors.Wrap(err, "failed to create instance profile")
+		}
+		s.scope.SetIAMInstanceProfile(profile.InstanceProfileName)
+		return nil
+	}
+
+	profile, err := s.Client.FindInstanceProfileForRole(ctx, role)
+	if err != nil {
+		return errors.Wrap(err, "failed to find instance profile")
+	}
+	if profile == nil {
+		return errors.Errorf("IAM role %s has no instance profile, consider using spec.createInstanceProfile", role)
+	}
+
+	s.scope.SetIAMInstanceProfile(profile.InstanceProfileName)
+	s.Log.Info("Successfully resolved instance profile", "InstanceProfile", aws.StringValue(profile.InstanceProfileName), "IAMRole", role)
+	return nil
+}
+
+// Delete ensures the instance profile is deleted if managed by the system.
+func (s *Service) Delete(ctx context.Context) error {
+	s.Log.V(1).Info("Deleting IAM instance profile")
+
+	profile := s.scope.IAMInstanceProfile()
+	if profile == nil {
+		s.Log.Info("No instance profile to delete")
+		return nil
+	}
+
+	if state := s.scope.InstanceState(); state != nil && *state != v1alpha1.InstanceStatusTerminated {
+		return awserrors.ErrInstanceNotTerminated
+	}
+
+	isManaged, err := s.Client.IsManagedInstanceProfile(ctx, *profile)
+	if err != nil {
+		return errors.Wrap(err, "failed to check if instance profile is managed")
+	}
+
+	if !isManaged {
+		s.Log.Info("Instance profile is not managed by Forge, skipping deletion", "InstanceProfile", *profile)
+		return nil
+	}
+
+	s.Log.Info("Deleting instance profile", "InstanceProfile", *profile)
+	if err := s.Client.DeleteInstanceProfile(ctx, *profile); err != nil {
+		return err
+	}
+
+	s.Log.Info("Successfully deleted instance profile", "InstanceProfile", *profile)
+	return nil
+}
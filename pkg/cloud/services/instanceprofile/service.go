@@ -0,0 +1,60 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instanceprofile
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/forge-build/forge-provider-aws/pkg/cloud"
+	"github.com/go-logr/logr"
+)
+
+const ServiceName = "instance-profile-reconciler"
+
+type instanceProfileInterface interface {
+	FindInstanceProfileForRole(ctx context.Context, role string) (*iam.InstanceProfile, error)
+	CreateInstanceProfileForRole(ctx context.Context, profileName, role string) (*iam.InstanceProfile, error)
+	IsManagedInstanceProfile(ctx context.Context, profileName string) (bool, error)
+	DeleteInstanceProfile(ctx context.Context, profileName string) error
+}
+
+type Scope interface {
+	cloud.Build
+	IAMRole() string
+	IAMInstanceProfile() *string
+	SetIAMInstanceProfile(name *string)
+	CreateInstanceProfile() bool
+	InstanceProfileName() string
+}
+
+// Service implements instance profile reconciler.
+type Service struct {
+	scope  Scope
+	Client instanceProfileInterface
+	Log    logr.Logger
+}
+
+var _ cloud.Reconciler = &Service{}
+
+// New returns Service from given scope.
+func New(scope Scope) *Service {
+	return &Service{
+		scope:  scope,
+		Client: scope.Cloud(),
+		Log:    scope.Log(ServiceName),
+	}
+}
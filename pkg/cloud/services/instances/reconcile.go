@@ -27,6 +27,7 @@ import (
 	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
 	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
 	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 func (s *Service) Reconcile(ctx context.Context) error {
@@ -40,12 +41,18 @@ func (s *Service) Reconcile(ctx context.Context) error {
 	s.scope.SetInstanceID(instance.InstanceId)
 
 	var publicIP string
-	if len(instance.NetworkInterfaces) > 0 && instance.NetworkInterfaces[0].Association.PublicIp != nil {
+	if len(instance.NetworkInterfaces) > 0 && instance.NetworkInterfaces[0].Association != nil && instance.NetworkInterfaces[0].Association.PublicIp != nil {
 		publicIP = *instance.NetworkInterfaces[0].Association.PublicIp
 	}
 
+	// Instances without a public IP are reached on their private IP
+	host := publicIP
+	if !aws.BoolValue(s.scope.PublicIP()) {
+		host = aws.StringValue(instance.PrivateIpAddress)
+	}
+
 	// Ensure SSH credentials secret if applicable
-	err = s.scope.EnsureCredentialsSecret(ctx, publicIP)
+	err = s.scope.EnsureCredentialsSecret(ctx, host)
 	if err != nil {
 		return err
 	}
@@ -54,7 +61,7 @@ func (s *Service) Reconcile(ctx context.Context) error {
 	s.scope.SetInstanceID(instance.InstanceId)
 	s.scope.SetInstanceStatus(infrav1.InstanceStatus(strings.ToUpper(*instance.State.Name))) // e.g., "running", "pending", etc.
 
-	s.Log.Info("EC2 instance is ready", "InstanceID", *instance.InstanceId, "PublicIP", publicIP)
+	s.Log.Info("EC2 instance is ready", "InstanceID", *instance.InstanceId, "PublicIP", publicIP, "Host", host)
 	return nil
 }
 
@@ -68,7 +75,7 @@ func (s *Service) Delete(ctx context.Context) error {
 	}
 
 	// Check if the instance is managed
-	isManaged, err := s.Client.IsManagedInstance(instanceID)
+	isManaged, err := s.Client.IsManagedInstance(ctx, instanceID)
 	if err != nil {
 		return errors.Wrap(err, "failed to check if instance is managed")
 	}
@@ -79,7 +86,7 @@ func (s *Service) Delete(ctx context.Context) error {
 	}
 
 	// Check current state of the instance
-	instance, err := s.Client.FindInstanceByID(instanceID)
+	instance, err := s.Client.FindInstanceByID(ctx, instanceID)
 	if err != nil {
 		if awserrors.IsNotFound(err) {
 			s.Log.Info("Instance already deleted", "InstanceID", *instanceID)
@@ -94,13 +101,21 @@ func (s *Service) Delete(ctx context.Context) error {
 	s.Log.V(1).Info(fmt.Sprintf("Instance is %s", state), "InstanceID", *instanceID)
 	s.scope.SetInstanceStatus(infrav1.InstanceStatus(strings.ToUpper(state)))
 
+	// A persistent Spot request would launch a new instance once this one is gone.
+	if instance.SpotInstanceRequestId != nil {
+		s.Log.V(1).Info("Cancelling Spot instance request", "SpotInstanceRequestID", *instance.SpotInstanceRequestId)
+		if err := s.Client.CancelSpotInstanceRequest(ctx, instance.SpotInstanceRequestId); err != nil && !awserrors.IsNotFound(err) {
+			return err
+		}
+	}
+
 	if state == ec2.InstanceStateNameTerminated || state == ec2.InstanceStateNameShuttingDown {
 		return nil
 	}
 
 	// Initiate termination if not already in progress
 	s.Log.V(1).Info("Terminating EC2 instance", "InstanceID", *instanceID)
-	err = s.Client.TerminateInstance(instanceID)
+	err = s.Client.TerminateInstance(ctx, instanceID)
 	if err != nil {
 		return err
 	}
@@ -112,35 +127,111 @@ func (s *Service) Delete(ctx context.Context) error {
 	return nil
 }
 
-func (s *Service) createOrGetInstance(_ context.Context) (*ec2.Instance, error) {
+func (s *Service) createOrGetInstance(ctx context.Context) (*ec2.Instance, error) {
 	instanceID := s.scope.GetInstanceID()
 	// Check if we already have an InstanceID
 	if instanceID != nil {
 		// Describe the instance
 		s.Log.V(1).Info("Getting Instance by ID", "instanceID", *instanceID)
-		instance, err := s.Client.FindInstanceByID(instanceID)
+		instance, err := s.Client.FindInstanceByID(ctx, instanceID)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to find instance by ID")
 		}
-		if instance != nil {
+		if instance != nil && !isSpotInterrupted(instance) {
+			if spotStateReason(instance) == spotShutdownReason {
+				s.scope.MarkConditionFalse(infrav1.SpotInstanceCondition, infrav1.SpotInstanceInterruptedReason, clusterv1.ConditionSeverityWarning,
+					"Spot instance %s was interrupted, waiting for capacity to resume it", *instanceID)
+			}
 			return instance, nil
 		}
-	}
-	// Update scope with InstanceID
-	params := awsforge.CreateInstanceParams{
-		Name:            s.scope.Name(),
-		InstanceType:    s.scope.InstanceType(),
-		AmiID:           s.scope.AMI(),
-		SubnetID:        *s.scope.SubnetID(),
-		SecurityGroupID: *s.scope.SecurityGroupID(),
-		Userdata:        *s.scope.UserData(),
-		PublicIP:        *s.scope.PublicIP(),
+		if instance != nil {
+			// A replacement would be captured without the changes of the provisioners
+			if s.scope.IsProvisionerReady() {
+				return nil, awserrors.NewTerminalError(infrav1.SpotInstanceInterruptedReason,
+					errors.Errorf("Spot instance %s was interrupted after it was provisioned", *instanceID))
+			}
+			s.Log.Info("Spot instance was interrupted, replacing it", "InstanceID", *instanceID)
+			if err := s.nextLaunchOption(infrav1.SpotInstanceInterruptedReason, fmt.Sprintf("Spot instance %s was interrupted", *instanceID)); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	s.Log.V(1).Info("Creating an EC2 Instance...")
-	instance, err := s.Client.CreateInstance(params)
-	if err != nil {
+	if err := s.resolveSourceAMI(ctx); err != nil {
 		return nil, err
 	}
-	return instance, nil
+
+	for {
+		option, err := s.currentLaunchOption()
+		if err != nil {
+			return nil, err
+		}
+
+		// Update scope with InstanceID
+		params := awsforge.CreateInstanceParams{
+			Name:                       s.scope.Name(),
+			InstanceType:               option.instanceType,
+			AmiID:                      s.scope.AMI(),
+			SubnetID:                   *s.scope.SubnetID(),
+			SecurityGroupID:            *s.scope.SecurityGroupID(),
+			AdditionalSecurityGroupIDs: s.scope.AdditionalSecurityGroupIDs(),
+			Userdata:                   *s.scope.UserData(),
+			PublicIP:                   *s.scope.PublicIP(),
+			SpotOptions:                option.spotOptions,
+			IAMInstanceProfile:         aws.StringValue(s.scope.IAMInstanceProfile()),
+			RootVolume:                 s.scope.RootVolume(),
+			AdditionalVolumes:          s.scope.AdditionalVolumes(),
+			AvailabilityZone:           aws.StringValue(s.scope.AvailabilityZone()),
+		}
+
+		s.Log.V(1).Info("Creating an EC2 Instance...", "InstanceType", option.instanceType, "Spot", option.spotOptions != nil)
+		instance, err := s.Client.CreateInstance(ctx, params)
+		if err != nil {
+			if option.spotOptions != nil && awserrors.IsSpotCapacityError(err) {
+				if err := s.nextLaunchOption(infrav1.SpotCapacityUnavailableReason, fmt.Sprintf("no Spot capacity for %s", option.instanceType)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if awserrors.IsInsufficientCapacity(err) {
+				return nil, s.nextAvailabilityZone(ctx, option.instanceType, err)
+			}
+			// The error is terminal for instance profiles given by the user
+			if s.scope.CreateInstanceProfile() && awserrors.IsInstanceProfileNotPropagated(err) {
+				return nil, errors.Errorf("instance profile %s is not propagated to EC2 yet", params.IAMInstanceProfile)
+			}
+			return nil, err
+		}
+
+		if option.spotOptions != nil {
+			s.scope.MarkConditionTrue(infrav1.SpotInstanceCondition)
+		}
+		return instance, nil
+	}
+}
+
+// resolveSourceAMI resolves the AMI the instance is launched from and records it in the status.
+// It is resolved only once so that later reconciles keep using the same image.
+func (s *Service) resolveSourceAMI(ctx context.Context) error {
+	if s.scope.SourceAMI() != nil {
+		return nil
+	}
+
+	amiID := s.scope.AMI()
+	if amiID == "" {
+		selector := s.scope.AMISelector()
+		if selector == nil {
+			return errors.New("neither spec.ami nor spec.amiSelector is set")
+		}
+
+		var err error
+		amiID, err = s.Client.ResolveAMI(ctx, *selector)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve AMI from selector")
+		}
+		s.Log.Info("Resolved source AMI from selector", "AMI", amiID)
+	}
+
+	s.scope.SetSourceAMI(aws.String(amiID))
+	return nil
 }
@@ -0,0 +1,516 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instances
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+	"github.com/forge-build/forge-provider-aws/pkg/aws/fake"
+	"github.com/forge-build/forge-provider-aws/pkg/cloud/scope"
+	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
+)
+
+const instanceType = "t3.micro"
+
+// fixture holds the resources the instance of the build is launched with.
+type fixture struct {
+	client   *fake.AWSClient
+	awsBuild *infrav1.AWSBuild
+	amiID    string
+
+	// provisionersReady is the readiness of the provisioners of the Build.
+	provisionersReady bool
+}
+
+// testScope records the host the SSH credentials are stored for, the Build isn't stored in the API server.
+type testScope struct {
+	*scope.AWSBuildScope
+	host *string
+}
+
+func (s *testScope) EnsureCredentialsSecret(_ context.Context, host string) error {
+	s.host = &host
+	return nil
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, f *fixture)
+		errors  map[string]error
+		wantErr string
+		// wantReason is the reason of the terminal error or the code of the AWS error.
+		wantReason string
+		// wantNotReady checks the error asks to reconcile the network again.
+		wantNotReady bool
+		verify       func(t *testing.T, f *fixture, instance *ec2.Instance, host *string)
+	}{
+		{
+			name: "on-demand instance is launched from the AMI and reached on its public IP",
+			verify: func(t *testing.T, f *fixture, instance *ec2.Instance, host *string) {
+				if id := aws.StringValue(instance.ImageId); id != f.amiID {
+					t.Errorf("instance AMI = %s, want %s", id, f.amiID)
+				}
+				if lifecycle := aws.StringValue(instance.InstanceLifecycle); lifecycle != "" {
+					t.Errorf("instance lifecycle = %s, want on-demand", lifecycle)
+				}
+				if ip := aws.StringValue(instance.PublicIpAddress); ip == "" || aws.StringValue(host) != ip {
+					t.Errorf("host = %s, want the public IP %q", aws.StringValue(host), ip)
+				}
+				if status := aws.StringValue((*string)(f.awsBuild.Status.InstanceStatus)); status != "PENDING" {
+					t.Errorf("instance status = %s, want PENDING", status)
+				}
+				if ami := aws.StringValue(f.awsBuild.Status.SourceAMI); ami != f.amiID {
+					t.Errorf("source AMI = %s, want %s", ami, f.amiID)
+				}
+			},
+		},
+		{
+			name: "private instance is reached on its private IP",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.Network.Private = &infrav1.PrivateNetworkSpec{}
+			},
+			verify: func(t *testing.T, f *fixture, instance *ec2.Instance, host *string) {
+				if instance.PublicIpAddress != nil {
+					t.Errorf("public IP = %s, want none", aws.StringValue(instance.PublicIpAddress))
+				}
+				if ip := aws.StringValue(instance.PrivateIpAddress); ip == "" || aws.StringValue(host) != ip {
+					t.Errorf("host = %s, want the private IP %q", aws.StringValue(host), ip)
+				}
+			},
+		},
+		{
+			name: "AMI is resolved from the selector",
+			setup: func(t *testing.T, f *fixture) {
+				f.client.AddImage(&ec2.Image{Name: aws.String("base-1"), CreationDate: aws.String("2024-01-01T00:00:00Z")})
+				f.amiID = f.client.AddImage(&ec2.Image{Name: aws.String("base-2"), CreationDate: aws.String("2024-02-01T00:00:00Z")})
+				f.awsBuild.Spec.AMI = nil
+				f.awsBuild.Spec.AMISelector = &infrav1.AMISelector{Name: "base-*", Owners: []string{"self"}}
+			},
+			verify: func(t *testing.T, f *fixture, instance *ec2.Instance, host *string) {
+				if id := aws.StringValue(instance.ImageId); id != f.amiID {
+					t.Errorf("instance AMI = %s, want the newest AMI %s", id, f.amiID)
+				}
+				if ami := aws.StringValue(f.awsBuild.Status.SourceAMI); ami != f.amiID {
+					t.Errorf("source AMI = %s, want %s", ami, f.amiID)
+				}
+			},
+		},
+		{
+			name: "AMI is resolved from the SSM parameter of the selector",
+			setup: func(t *testing.T, f *fixture) {
+				f.client.SSMParameters["/images/base"] = f.amiID
+				f.awsBuild.Spec.AMI = nil
+				f.awsBuild.Spec.AMISelector = &infrav1.AMISelector{SSMParameter: "/images/base"}
+			},
+			verify: func(t *testing.T, f *fixture, instance *ec2.Instance, host *string) {
+				if id := aws.StringValue(instance.ImageId); id != f.amiID {
+					t.Errorf("instance AMI = %s, want %s", id, f.amiID)
+				}
+			},
+		},
+		{
+			name: "build without AMI nor selector is an error",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.AMI = nil
+			},
+			wantErr: "neither spec.ami nor spec.amiSelector is set",
+		},
+		{
+			name: "existing instance is used",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.InstanceID = launch(t, f, nil)
+				// A new instance can't be launched without AMI
+				f.awsBuild.Spec.AMI = nil
+			},
+			verify: func(t *testing.T, f *fixture, instance *ec2.Instance, host *string) {
+				if status := aws.StringValue((*string)(f.awsBuild.Status.InstanceStatus)); status != "RUNNING" {
+					t.Errorf("instance status = %s, want RUNNING", status)
+				}
+			},
+		},
+		{
+			name: "Spot instance is launched",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.MarketType = infrav1.MarketTypeSpot
+			},
+			verify: func(t *testing.T, f *fixture, instance *ec2.Instance, host *string) {
+				if lifecycle := aws.StringValue(instance.InstanceLifecycle); lifecycle != ec2.InstanceLifecycleTypeSpot {
+					t.Errorf("instance lifecycle = %s, want spot", lifecycle)
+				}
+				if !conditions.IsTrue(f.awsBuild, infrav1.SpotInstanceCondition) {
+					t.Errorf("condition %s is not true", infrav1.SpotInstanceCondition)
+				}
+			},
+		},
+		{
+			name: "Spot instance without capacity fails the build when all launch options are exhausted",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.MarketType = infrav1.MarketTypeSpot
+				t.Cleanup(func() {
+					if reason := conditions.GetReason(f.awsBuild, infrav1.SpotInstanceCondition); reason != infrav1.SpotCapacityUnavailableReason {
+						t.Errorf("condition %s reason = %s, want %s", infrav1.SpotInstanceCondition, reason, infrav1.SpotCapacityUnavailableReason)
+					}
+				})
+			},
+			errors:     map[string]error{"CreateInstance": awserr.New("InsufficientInstanceCapacity", "There is no Spot capacity available.", nil)},
+			wantErr:    "all launch options are exhausted",
+			wantReason: infrav1.SpotCapacityUnavailableReason,
+		},
+		{
+			name: "Spot instance without capacity falls back to on-demand in the next zone",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.MarketType = infrav1.MarketTypeSpot
+				f.awsBuild.Spec.SpotOptions = &infrav1.SpotOptions{FallbackToOnDemand: true}
+				t.Cleanup(func() {
+					if attempt := f.awsBuild.Status.LaunchAttempt; attempt != 1 {
+						t.Errorf("launch attempt = %d, want 1", attempt)
+					}
+					if zone := aws.StringValue(f.awsBuild.Status.AvailabilityZone); zone != "us-east-1b" {
+						t.Errorf("availability zone = %s, want us-east-1b", zone)
+					}
+				})
+			},
+			errors:       map[string]error{"CreateInstance": awserr.New("InsufficientInstanceCapacity", "There is no capacity available.", nil)},
+			wantErr:      "moving to us-east-1b",
+			wantNotReady: true,
+		},
+		{
+			name: "instance type not offered in the zone of the subnet moves to the next offering zone",
+			setup: func(t *testing.T, f *fixture) {
+				f.client.Offerings[instanceType] = []string{"us-east-1b", "us-east-1c"}
+				t.Cleanup(func() {
+					if zone := aws.StringValue(f.awsBuild.Status.AvailabilityZone); zone != "us-east-1b" {
+						t.Errorf("availability zone = %s, want us-east-1b", zone)
+					}
+				})
+			},
+			wantErr:      "moving to us-east-1b",
+			wantNotReady: true,
+		},
+		{
+			name: "instance type not offered in the zone of the subnet of the user is an error",
+			setup: func(t *testing.T, f *fixture) {
+				subnetID, err := f.client.AddSubnet(aws.StringValue(f.awsBuild.Spec.Network.VPCID), "10.0.200.0/24", "us-east-1a")
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.awsBuild.Spec.Network.SubnetID = aws.String(subnetID)
+				f.client.Offerings[instanceType] = []string{"us-east-1b"}
+			},
+			wantErr:    "availability zone us-east-1a of the subnet",
+			wantReason: "Unsupported",
+		},
+		{
+			name: "unsupported instance options are an error without moving to another zone",
+			setup: func(t *testing.T, f *fixture) {
+				t.Cleanup(func() {
+					if zone := aws.StringValue(f.awsBuild.Status.AvailabilityZone); zone != "us-east-1a" {
+						t.Errorf("availability zone = %s, want us-east-1a", zone)
+					}
+				})
+			},
+			errors:     map[string]error{"CreateInstance": awserr.New("Unsupported", "The requested configuration is currently not supported.", nil)},
+			wantErr:    "not supported",
+			wantReason: "Unsupported",
+		},
+		{
+			name: "interrupted Spot instance is replaced before the provisioners are ready",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.MarketType = infrav1.MarketTypeSpot
+				f.awsBuild.Spec.SpotOptions = &infrav1.SpotOptions{FallbackToOnDemand: true}
+				interrupted := launch(t, f, &infrav1.SpotOptions{})
+				if err := f.client.InterruptSpotInstance(aws.StringValue(interrupted), ec2.InstanceInterruptionBehaviorTerminate); err != nil {
+					t.Fatal(err)
+				}
+				f.awsBuild.Spec.InstanceID = interrupted
+				t.Cleanup(func() {
+					if id := aws.StringValue(f.awsBuild.Spec.InstanceID); id == aws.StringValue(interrupted) {
+						t.Errorf("instance ID = %s, want the replacement", id)
+					}
+				})
+			},
+			verify: func(t *testing.T, f *fixture, instance *ec2.Instance, host *string) {
+				if lifecycle := aws.StringValue(instance.InstanceLifecycle); lifecycle != "" {
+					t.Errorf("instance lifecycle = %s, want on-demand", lifecycle)
+				}
+				if reason := conditions.GetReason(f.awsBuild, infrav1.SpotInstanceCondition); reason != infrav1.FallbackToOnDemandReason {
+					t.Errorf("condition %s reason = %s, want %s", infrav1.SpotInstanceCondition, reason, infrav1.FallbackToOnDemandReason)
+				}
+			},
+		},
+		{
+			name: "interrupted Spot instance is terminal after the provisioners are ready",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.MarketType = infrav1.MarketTypeSpot
+				f.awsBuild.Spec.InstanceID = launch(t, f, &infrav1.SpotOptions{})
+				if err := f.client.InterruptSpotInstance(aws.StringValue(f.awsBuild.Spec.InstanceID), ec2.InstanceInterruptionBehaviorTerminate); err != nil {
+					t.Fatal(err)
+				}
+				f.provisionersReady = true
+			},
+			wantErr:    "was interrupted after it was provisioned",
+			wantReason: infrav1.SpotInstanceInterruptedReason,
+		},
+		{
+			name: "instance profile created for the build and not propagated yet is retried",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.IAMInstanceProfile = aws.String("build-forge-build-uid")
+				f.awsBuild.Spec.CreateInstanceProfile = true
+			},
+			wantErr: "is not propagated to EC2 yet",
+		},
+		{
+			name: "instance profile of the user that doesn't exist is an error",
+			setup: func(t *testing.T, f *fixture) {
+				f.awsBuild.Spec.IAMInstanceProfile = aws.String("missing")
+			},
+			wantErr:    "iamInstanceProfile",
+			wantReason: "InvalidParameterValue",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t)
+			if tt.setup != nil {
+				tt.setup(t, f)
+			}
+			for method, err := range tt.errors {
+				f.client.Errors[method] = err
+			}
+
+			s := &testScope{AWSBuildScope: newScope(t, f)}
+			err := New(s).Reconcile(ctx)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Reconcile() error = %v, want %q", err, tt.wantErr)
+				}
+				if reason := awserrors.Reason(err); tt.wantReason != "" && reason != tt.wantReason {
+					t.Errorf("Reconcile() error reason = %q, want %q", reason, tt.wantReason)
+				}
+				if notReady := awserrors.IsNetworkNotReady(err); notReady != tt.wantNotReady {
+					t.Errorf("Reconcile() error is network not ready = %t, want %t", notReady, tt.wantNotReady)
+				}
+				if s.host != nil {
+					t.Errorf("credentials stored for host %q after an error", *s.host)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			instance := f.client.Instance(aws.StringValue(f.awsBuild.Spec.InstanceID))
+			if instance == nil {
+				t.Fatalf("instance %q not found", aws.StringValue(f.awsBuild.Spec.InstanceID))
+			}
+			if s.host == nil {
+				t.Fatal("credentials are not stored")
+			}
+			if tt.verify != nil {
+				tt.verify(t, f, instance, s.host)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup returns the instance of the build, if any.
+		setup   func(t *testing.T, f *fixture) *string
+		errors  map[string]error
+		wantErr string
+		// wantStatus is the instance status of the build, none when empty.
+		wantStatus string
+		// wantState is the state of the instance of the build in EC2.
+		wantState string
+		// wantSpotRequest is the state of the Spot request of the instance of the build.
+		wantSpotRequest string
+	}{
+		{
+			name: "build without instance has nothing to delete",
+			setup: func(t *testing.T, f *fixture) *string {
+				return nil
+			},
+		},
+		{
+			name: "instance unknown to EC2 is skipped",
+			setup: func(t *testing.T, f *fixture) *string {
+				return aws.String("i-0123456789abcdef0")
+			},
+		},
+		{
+			name: "running instance is terminated",
+			setup: func(t *testing.T, f *fixture) *string {
+				return launch(t, f, nil)
+			},
+			wantStatus: "Terminating",
+			wantState:  ec2.InstanceStateNameShuttingDown,
+		},
+		{
+			name: "terminated instance is reported",
+			setup: func(t *testing.T, f *fixture) *string {
+				instanceID := launch(t, f, nil)
+				if err := f.client.TerminateInstance(context.Background(), instanceID); err != nil {
+					t.Fatal(err)
+				}
+				return instanceID
+			},
+			wantStatus: string(infrav1.InstanceStatusTerminated),
+			wantState:  ec2.InstanceStateNameTerminated,
+		},
+		{
+			name: "Spot request of the instance is cancelled",
+			setup: func(t *testing.T, f *fixture) *string {
+				return launch(t, f, &infrav1.SpotOptions{})
+			},
+			wantStatus:      "Terminating",
+			wantState:       ec2.InstanceStateNameShuttingDown,
+			wantSpotRequest: ec2.SpotInstanceStateCancelled,
+		},
+		{
+			name: "instance termination error is returned",
+			setup: func(t *testing.T, f *fixture) *string {
+				return launch(t, f, nil)
+			},
+			errors:     map[string]error{"TerminateInstance": awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil)},
+			wantErr:    "UnauthorizedOperation",
+			wantStatus: string(infrav1.InstanceStatusRunning),
+			wantState:  ec2.InstanceStateNameRunning,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t)
+			f.awsBuild.Spec.InstanceID = tt.setup(t, f)
+			for method, err := range tt.errors {
+				f.client.Errors[method] = err
+			}
+
+			err := New(&testScope{AWSBuildScope: newScope(t, f)}).Delete(ctx)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Delete() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+
+			if status := aws.StringValue((*string)(f.awsBuild.Status.InstanceStatus)); status != tt.wantStatus {
+				t.Errorf("instance status = %q, want %q", status, tt.wantStatus)
+			}
+			if tt.wantState == "" {
+				return
+			}
+			instance := f.client.Instance(aws.StringValue(f.awsBuild.Spec.InstanceID))
+			if state := aws.StringValue(instance.State.Name); state != tt.wantState {
+				t.Errorf("instance state = %s, want %s", state, tt.wantState)
+			}
+			if requestID := aws.StringValue(instance.SpotInstanceRequestId); tt.wantSpotRequest != "" || requestID != "" {
+				if state := f.client.SpotRequestState(requestID); state != tt.wantSpotRequest {
+					t.Errorf("Spot request state = %s, want %s", state, tt.wantSpotRequest)
+				}
+			}
+		})
+	}
+}
+
+// newFixture returns a public build with a managed VPC, subnet and security group and an AMI to launch from.
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	f := &fixture{
+		client: fake.New(),
+		awsBuild: &infrav1.AWSBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "default"},
+			Spec: infrav1.AWSBuildSpec{
+				Region:       fake.DefaultRegion,
+				InstanceType: instanceType,
+				PublicIP:     aws.Bool(true),
+			},
+		},
+	}
+
+	vpc, err := f.client.CreateVPC(ctx, newScope(t, f).VPCSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	subnet, err := f.client.CreateSubnet(ctx, awsforge.CreateSubnetParams{
+		VPCName:          "build-forge-vpc",
+		VPCID:            vpc.VpcId,
+		AvailabilityZone: "us-east-1a",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sg, err := f.client.CreateSecurityGroup(ctx, vpc.VpcId, aws.String("build-forge"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.awsBuild.Spec.Username = "forge"
+	f.amiID = f.client.AddImage(&ec2.Image{Name: aws.String("base")})
+	f.awsBuild.Spec.AMI = aws.String(f.amiID)
+	f.awsBuild.Spec.Network.VPCID = vpc.VpcId
+	f.awsBuild.Spec.Network.SubnetID = subnet.SubnetId
+	f.awsBuild.Spec.Network.SecurityGroupID = sg.GroupId
+	f.awsBuild.Status.AvailabilityZone = subnet.AvailabilityZone
+	return f
+}
+
+// newScope returns the scope of the build of the fixture.
+func newScope(t *testing.T, f *fixture) *scope.AWSBuildScope {
+	t.Helper()
+	build := fake.NewBuild()
+	build.Status.ProvisionersReady = f.provisionersReady
+	return fake.NewScope(t, f.client, build, f.awsBuild)
+}
+
+// launch launches a managed instance of the build, a Spot instance when the Spot options are set.
+func launch(t *testing.T, f *fixture, spotOptions *infrav1.SpotOptions) *string {
+	t.Helper()
+	instance, err := f.client.CreateInstance(context.Background(), awsforge.CreateInstanceParams{
+		Name:            "build",
+		InstanceType:    instanceType,
+		AmiID:           f.amiID,
+		SubnetID:        aws.StringValue(f.awsBuild.Spec.Network.SubnetID),
+		SecurityGroupID: aws.StringValue(f.awsBuild.Spec.Network.SecurityGroupID),
+		SpotOptions:     spotOptions,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.client.FindInstanceByID(context.Background(), instance.InstanceId); err != nil {
+		t.Fatal(err)
+	}
+	return instance.InstanceId
+}
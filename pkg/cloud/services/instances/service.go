@@ -20,27 +20,51 @@ import (
 	"context"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
 	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
 	"github.com/forge-build/forge-provider-aws/pkg/cloud"
 	"github.com/go-logr/logr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 const ServiceName = "instance-reconciler"
 
 // instancesInterface defines the EC2 operations needed for instances.
 type instancesInterface interface {
-	IsManagedInstance(instanceID *string) (bool, error)
-	FindInstanceByID(instanceID *string) (*ec2.Instance, error)
-	CreateInstance(input awsforge.CreateInstanceParams) (*ec2.Instance, error)
-	TerminateInstance(instanceID *string) error
+	IsManagedInstance(ctx context.Context, instanceID *string) (bool, error)
+	FindInstanceByID(ctx context.Context, instanceID *string) (*ec2.Instance, error)
+	CreateInstance(ctx context.Context, input awsforge.CreateInstanceParams) (*ec2.Instance, error)
+	TerminateInstance(ctx context.Context, instanceID *string) error
+	CancelSpotInstanceRequest(ctx context.Context, requestID *string) error
+	ResolveAMI(ctx context.Context, selector infrav1.AMISelector) (string, error)
+	IsManagedSubnet(ctx context.Context, subnetID string) (bool, error)
+	FindAvailabilityZones(ctx context.Context, instanceType string, preferred []string) ([]string, error)
 }
 
 // Scope defines the methods needed from the calling context (e.g., BuildScope).
 // This should return parameters needed to create, identify, and configure the instance.
 type Scope interface {
 	cloud.Build
+	IsProvisionerReady() bool
 	UserData() *string
 	PublicIP() *bool
+	AdditionalSecurityGroupIDs() []string
+	AMISelector() *infrav1.AMISelector
+	SourceAMI() *string
+	SetSourceAMI(id *string)
+	IAMInstanceProfile() *string
+	CreateInstanceProfile() bool
+	RootVolume() *infrav1.AttachedVolumeSpec
+	AdditionalVolumes() []infrav1.AttachedVolumeSpec
+	MarketType() infrav1.MarketType
+	SpotOptions() *infrav1.SpotOptions
+	LaunchAttempt() int32
+	SetLaunchAttempt(attempt int32)
+	AvailabilityZones() []string
+	AvailabilityZone() *string
+	SetAvailabilityZone(zone *string)
+	MarkConditionTrue(t clusterv1.ConditionType)
+	MarkConditionFalse(t clusterv1.ConditionType, reason string, severity clusterv1.ConditionSeverity, messageFormat string, messageArgs ...interface{})
 	EnsureCredentialsSecret(ctx context.Context, host string) error
 }
 
@@ -0,0 +1,115 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instances
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
+	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// spotTerminationReason is the state reason of a Spot instance terminated by AWS.
+	spotTerminationReason = "Server.SpotInstanceTermination"
+	// spotShutdownReason is the state reason of a Spot instance stopped or hibernated by AWS.
+	spotShutdownReason = "Server.SpotInstanceShutdown"
+)
+
+// launchOption describes how the instance is purchased for a single launch attempt.
+type launchOption struct {
+	instanceType string
+	spotOptions  *infrav1.SpotOptions
+}
+
+// launchOptions returns the launch options in the order they are tried.
+// On-demand builds only have a single option.
+func (s *Service) launchOptions() []launchOption {
+	if s.scope.MarketType() != infrav1.MarketTypeSpot {
+		return []launchOption{{instanceType: s.scope.InstanceType()}}
+	}
+
+	spotOptions := s.scope.SpotOptions()
+	if spotOptions == nil {
+		spotOptions = &infrav1.SpotOptions{}
+	}
+
+	options := []launchOption{{instanceType: s.scope.InstanceType(), spotOptions: spotOptions}}
+	for _, instanceType := range spotOptions.FallbackInstanceTypes {
+		options = append(options, launchOption{instanceType: instanceType, spotOptions: spotOptions})
+	}
+	if spotOptions.FallbackToOnDemand {
+		options = append(options, launchOption{instanceType: s.scope.InstanceType()})
+	}
+
+	return options
+}
+
+// currentLaunchOption returns the launch option of the current attempt.
+// The build fails for good once every launch option was tried.
+func (s *Service) currentLaunchOption() (launchOption, error) {
+	options := s.launchOptions()
+	attempt := int(s.scope.LaunchAttempt())
+	if attempt >= len(options) {
+		return launchOption{}, awserrors.NewTerminalError(infrav1.SpotCapacityUnavailableReason,
+			errors.New("no Spot capacity available and all launch options are exhausted"))
+	}
+	return options[attempt], nil
+}
+
+// nextLaunchOption moves on to the next launch option after a Spot failure and reports it in the SpotInstance condition.
+// It returns a terminal error with the reason once every launch option was tried.
+func (s *Service) nextLaunchOption(reason, message string) error {
+	options := s.launchOptions()
+	attempt := s.scope.LaunchAttempt() + 1
+	s.scope.SetLaunchAttempt(attempt)
+
+	if int(attempt) >= len(options) {
+		s.scope.MarkConditionFalse(infrav1.SpotInstanceCondition, reason, clusterv1.ConditionSeverityError, "%s, all launch options are exhausted", message)
+		return awserrors.NewTerminalError(reason, errors.Errorf("%s, all launch options are exhausted", message))
+	}
+
+	next := options[attempt]
+	if next.spotOptions == nil {
+		s.Log.Info("Falling back to on-demand instance", "Reason", message, "InstanceType", next.instanceType)
+		s.scope.MarkConditionFalse(infrav1.SpotInstanceCondition, infrav1.FallbackToOnDemandReason, clusterv1.ConditionSeverityInfo, "%s, falling back to on-demand %s", message, next.instanceType)
+		return nil
+	}
+
+	s.Log.Info("Retrying Spot instance with another instance type", "Reason", message, "InstanceType", next.instanceType)
+	s.scope.MarkConditionFalse(infrav1.SpotInstanceCondition, reason, clusterv1.ConditionSeverityWarning, "%s, retrying with Spot %s", message, next.instanceType)
+	return nil
+}
+
+// spotStateReason returns the state reason code of a Spot instance, or an empty string for on-demand instances.
+func spotStateReason(instance *ec2.Instance) string {
+	if aws.StringValue(instance.InstanceLifecycle) != ec2.InstanceLifecycleTypeSpot || instance.StateReason == nil {
+		return ""
+	}
+	return aws.StringValue(instance.StateReason.Code)
+}
+
+// isSpotInterrupted checks if the Spot instance was terminated by AWS and has to be replaced.
+func isSpotInterrupted(instance *ec2.Instance) bool {
+	state := aws.StringValue(instance.State.Name)
+	if state != ec2.InstanceStateNameTerminated && state != ec2.InstanceStateNameShuttingDown {
+		return false
+	}
+	return spotStateReason(instance) == spotTerminationReason
+}
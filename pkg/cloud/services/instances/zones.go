@@ -0,0 +1,63 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instances
+
+import (
+	"context"
+	"slices"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
+	"github.com/pkg/errors"
+)
+
+// nextAvailabilityZone moves the instance to the next availability zone offering the instance type after a capacity error.
+// The subnet reconciler recreates the managed subnet in that zone and the launch is retried on the next reconcile.
+func (s *Service) nextAvailabilityZone(ctx context.Context, instanceType string, cause error) error {
+	current := aws.StringValue(s.scope.AvailabilityZone())
+
+	// The zone of a subnet provided by the user can't be changed
+	isManaged, err := s.Client.IsManagedSubnet(ctx, aws.StringValue(s.scope.SubnetID()))
+	if err != nil {
+		return errors.Wrap(err, "failed to check if subnet is managed")
+	}
+	if !isManaged {
+		return errors.Wrapf(cause, "no capacity for %s in availability zone %s of the subnet", instanceType, current)
+	}
+
+	zones, err := s.Client.FindAvailabilityZones(ctx, instanceType, s.scope.AvailabilityZones())
+	if err != nil {
+		return errors.Wrap(err, "failed to select availability zone")
+	}
+
+	// Zones are tried in order, from the first offered one when the current zone is not offered
+	next := ""
+	if i := slices.Index(zones, current); i < 0 {
+		if len(zones) > 0 {
+			next = zones[0]
+		}
+	} else if i+1 < len(zones) {
+		next = zones[i+1]
+	}
+	if next == "" {
+		return errors.Wrapf(cause, "no capacity for %s in availability zone %s and all availability zones are exhausted", instanceType, current)
+	}
+
+	s.Log.Info("Retrying instance in another availability zone", "InstanceType", instanceType, "AvailabilityZone", current, "NextAvailabilityZone", next)
+	s.scope.SetAvailabilityZone(aws.String(next))
+	return errors.Wrapf(awserrors.ErrNetworkNotReady, "no capacity for %s in availability zone %s, moving to %s", instanceType, current, next)
+}
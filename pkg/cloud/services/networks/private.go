@@ -0,0 +1,288 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
+	"github.com/pkg/errors"
+)
+
+// InvalidNetworkSpecReason is the failure reason of the builds with a network spec that can't be reconciled.
+const InvalidNetworkSpecReason = "InvalidNetworkSpec"
+
+// egressSubnetPrefixLength is the size of the subnet holding the NAT gateway or the interface endpoints.
+const egressSubnetPrefixLength = 28
+
+// reconcilePrivateEgress ensures the egress resources of a private network exist.
+// They can only be created in VPCs managed by Forge, private networks in other VPCs are rejected.
+func (s *Service) reconcilePrivateEgress(ctx context.Context, vpc *ec2.Vpc, egress infrav1.PrivateEgressMode) error {
+	vpcID := aws.StringValue(vpc.VpcId)
+	isManagedVPC, err := s.Client.IsManagedVPC(ctx, vpc.VpcId)
+	if err != nil {
+		return errors.Wrap(err, "failed to check if VPC is managed")
+	}
+	if !isManagedVPC {
+		return awserrors.NewTerminalError(InvalidNetworkSpecReason,
+			errors.Errorf("spec.network.private requires a VPC created by Forge, VPC %s is not managed", vpcID))
+	}
+
+	subnet, err := s.reconcileEgressSubnet(ctx, vpcID)
+	if err != nil {
+		return errors.Wrap(err, "failed to reconcile egress subnet")
+	}
+
+	switch egress {
+	case infrav1.PrivateEgressNATGateway:
+		return s.reconcileNATGateway(ctx, vpcID, aws.StringValue(subnet.SubnetId))
+	case infrav1.PrivateEgressVPCEndpoints:
+		return s.reconcileVPCEndpoints(ctx, vpc, aws.StringValue(subnet.SubnetId))
+	}
+	return errors.Errorf("unsupported private egress %q", egress)
+}
+
+// reconcileEgressSubnet ensures the subnet holding the egress resources exists.
+// The CIDR requested for the build subnet is kept free.
+func (s *Service) reconcileEgressSubnet(ctx context.Context, vpcID string) (*ec2.Subnet, error) {
+	subnet, err := s.Client.FindSubnetByName(ctx, vpcID, s.egressName())
+	if err != nil {
+		return nil, err
+	}
+	if subnet != nil {
+		return subnet, nil
+	}
+
+	var reservedCIDRs []string
+	if cidr := s.scope.SubnetCIDR(); cidr != "" {
+		reservedCIDRs = append(reservedCIDRs, cidr)
+	}
+
+	s.Log.Info("Creating egress subnet", "VPCID", vpcID)
+	return s.Client.CreateSubnet(ctx, awsforge.CreateSubnetParams{
+		VPCName:       *s.scope.VPCName(),
+		VPCID:         aws.String(vpcID),
+		Name:          s.egressName(),
+		PrefixLength:  egressSubnetPrefixLength,
+		ReservedCIDRs: reservedCIDRs,
+	})
+}
+
+// reconcileNATGateway ensures the private subnets reach the internet through a NAT gateway in the egress subnet.
+func (s *Service) reconcileNATGateway(ctx context.Context, vpcID, subnetID string) error {
+	// The NAT gateway needs an Internet Gateway, only its own subnet is routed through it
+	igw, err := s.Client.CreateOrGetInternetGateway(ctx, vpcID)
+	if err != nil {
+		return errors.Wrap(err, "failed to reconcile Internet Gateway")
+	}
+
+	err = s.reconcileEgressRouteTable(ctx, vpcID, subnetID, aws.StringValue(igw.InternetGatewayId))
+	if err != nil {
+		return errors.Wrap(err, "failed to reconcile egress route table")
+	}
+
+	natGateway, err := s.Client.CreateOrGetNATGateway(ctx, awsforge.NATGatewayParams{
+		VPCID:    vpcID,
+		SubnetID: subnetID,
+		Name:     s.egressName(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to reconcile NAT gateway")
+	}
+
+	natGatewayID := aws.StringValue(natGateway.NatGatewayId)
+	if state := aws.StringValue(natGateway.State); state != ec2.NatGatewayStateAvailable {
+		s.Log.V(1).Info("NAT gateway is not available yet", "NATGatewayID", natGatewayID, "State", state)
+		return errors.Wrapf(awserrors.ErrNetworkNotReady, "NAT gateway %s is %s", natGatewayID, state)
+	}
+
+	_, err = s.reconcileRouteTable(ctx, vpcID, &awsforge.RouteTarget{NATGatewayID: natGatewayID})
+	if err != nil {
+		return errors.Wrap(err, "failed to reconcile route table")
+	}
+
+	s.Log.Info("NAT gateway is ready", "NATGatewayID", natGatewayID)
+	return nil
+}
+
+// reconcileEgressRouteTable ensures the egress subnet is routed to the Internet Gateway.
+func (s *Service) reconcileEgressRouteTable(ctx context.Context, vpcID, subnetID, igwID string) error {
+	routeTable, err := s.Client.CreateOrGetRouteTable(ctx, vpcID, s.egressName())
+	if err != nil {
+		return err
+	}
+
+	err = s.Client.EnsureDefaultRoute(ctx, routeTable, awsforge.RouteTarget{InternetGatewayID: igwID})
+	if err != nil {
+		return err
+	}
+
+	return s.Client.AssociateRouteTable(ctx, aws.StringValue(routeTable.RouteTableId), subnetID)
+}
+
+// reconcileVPCEndpoints ensures the private subnets reach the AWS services through VPC endpoints.
+func (s *Service) reconcileVPCEndpoints(ctx context.Context, vpc *ec2.Vpc, subnetID string) error {
+	vpcID := aws.StringValue(vpc.VpcId)
+
+	// Private DNS of interface endpoints resolves only with DNS hostnames enabled
+	err := s.Client.EnableVPCDNSHostnames(ctx, vpcID)
+	if err != nil {
+		return err
+	}
+
+	sgID, err := s.reconcileEndpointSecurityGroup(ctx, vpc)
+	if err != nil {
+		return errors.Wrap(err, "failed to reconcile VPC endpoints Security Group")
+	}
+
+	// The build subnet has no default route, gateway endpoints are its only routes out of the VPC
+	routeTable, err := s.reconcileRouteTable(ctx, vpcID, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to reconcile route table")
+	}
+
+	endpoints, err := s.Client.CreateOrGetVPCEndpoints(ctx, awsforge.VPCEndpointsParams{
+		VPCID:           vpcID,
+		Region:          s.scope.Region(),
+		Services:        s.scope.VPCEndpointServices(),
+		SubnetID:        subnetID,
+		SecurityGroupID: sgID,
+		RouteTableIDs:   []string{aws.StringValue(routeTable.RouteTableId)},
+		Name:            s.egressName(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to reconcile VPC endpoints")
+	}
+
+	s.Log.Info("VPC endpoints are ready", "VPCID", vpcID, "Endpoints", len(endpoints))
+	return nil
+}
+
+// reconcileEndpointSecurityGroup ensures the Security Group of the interface endpoints allows HTTPS from the VPC.
+func (s *Service) reconcileEndpointSecurityGroup(ctx context.Context, vpc *ec2.Vpc) (string, error) {
+	vpcID := aws.StringValue(vpc.VpcId)
+	sgs, err := s.Client.FindSecurityGroupsInVPC(ctx, vpcID, nil, map[string]string{"Name": s.egressName()})
+	if err != nil {
+		return "", err
+	}
+
+	var sg *ec2.SecurityGroup
+	if len(sgs) > 0 {
+		sg = sgs[0]
+	} else {
+		s.Log.Info("Creating VPC endpoints Security Group", "VPCID", vpcID)
+		output, err := s.Client.CreateSecurityGroup(ctx, vpc.VpcId, aws.String(s.egressName()))
+		if err != nil {
+			return "", err
+		}
+		sg = &ec2.SecurityGroup{GroupId: output.GroupId}
+	}
+
+	sgID := aws.StringValue(sg.GroupId)
+	if len(sg.IpPermissions) > 0 {
+		return sgID, nil
+	}
+
+	err = s.Client.AuthorizeSecurityGroupIngress(ctx, sgID, []*ec2.IpPermission{
+		{
+			IpProtocol: aws.String("tcp"),
+			FromPort:   aws.Int64(443),
+			ToPort:     aws.Int64(443),
+			IpRanges: []*ec2.IpRange{
+				{CidrIp: vpc.CidrBlock, Description: aws.String("HTTPS to the VPC endpoints")},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return sgID, nil
+}
+
+// deletePrivateEgress deletes the egress resources of the managed VPC in dependency order.
+// VPC endpoints and NAT gateways are deleted asynchronously, the addresses and subnet they use are deleted once they are gone.
+func (s *Service) deletePrivateEgress(ctx context.Context, vpcID string) error {
+	endpointsDeleted, err := s.Client.DeleteVPCEndpoints(ctx, vpcID)
+	if err != nil {
+		return err
+	}
+	natGatewaysDeleted, err := s.Client.DeleteNATGateways(ctx, vpcID)
+	if err != nil {
+		return err
+	}
+	if !endpointsDeleted || !natGatewaysDeleted {
+		s.Log.V(1).Info("Waiting for VPC endpoints and NAT gateways to be deleted", "VPCID", vpcID)
+		return errors.Wrap(awserrors.ErrNetworkNotDeleted, "VPC endpoints and NAT gateways are being deleted")
+	}
+
+	released, err := s.Client.ReleaseAddresses(ctx, vpcID)
+	if err != nil {
+		return err
+	}
+	if !released {
+		return errors.Wrap(awserrors.ErrNetworkNotDeleted, "Elastic IPs are still associated")
+	}
+
+	subnet, err := s.Client.FindSubnetByName(ctx, vpcID, s.egressName())
+	if err != nil {
+		return err
+	}
+	if subnet != nil {
+		s.Log.Info("Deleting egress subnet", "SubnetID", aws.StringValue(subnet.SubnetId))
+		err = s.Client.DeleteSubnet(ctx, subnet.SubnetId)
+		if awserrors.IsDependencyViolation(err) {
+			return errors.Wrap(awserrors.ErrNetworkNotDeleted, "egress subnet is still in use")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.Client.DeleteRouteTables(ctx, vpcID)
+	if err != nil {
+		return err
+	}
+	s.scope.SetRouteTableID(nil)
+
+	sgs, err := s.Client.FindSecurityGroupsInVPC(ctx, vpcID, nil, map[string]string{"Name": s.egressName()})
+	if err != nil {
+		return err
+	}
+	for _, sg := range sgs {
+		s.Log.Info("Deleting VPC endpoints Security Group", "SecurityGroupID", aws.StringValue(sg.GroupId))
+		err = s.Client.DeleteSecurityGroup(ctx, sg.GroupId)
+		if awserrors.IsDependencyViolation(err) {
+			return errors.Wrap(awserrors.ErrNetworkNotDeleted, "VPC endpoints Security Group is still in use")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// egressName returns the name of the egress resources of the VPC.
+func (s *Service) egressName() string {
+	return fmt.Sprintf("%s-egress", *s.scope.VPCName())
+}
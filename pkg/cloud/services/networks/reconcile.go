@@ -18,6 +18,7 @@ package networks
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
 	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
@@ -30,12 +31,34 @@ import (
 func (s *Service) Reconcile(ctx context.Context) error {
 	s.Log.V(1).Info("Reconciling AWS VPC resources")
 
+	// Reject invalid CIDR blocks before creating anything
+	if err := s.validateCIDRs(); err != nil {
+		return errors.Wrap(err, "invalid network spec")
+	}
+
 	// Ensure VPC exists
 	vpc, err := s.createOrGetVPC(ctx)
 	if err != nil {
 		return errors.Wrap(err, "failed to reconcile VPC")
 	}
 
+	// Private networks reach the internet and AWS without a public route
+	if egress := s.scope.PrivateEgress(); egress != "" {
+		return s.reconcilePrivateEgress(ctx, vpc, egress)
+	}
+
+	// Subnets of the user keep their own routes, the gateway and the route table are only used by subnets created by Forge
+	if subnetID := s.scope.SubnetID(); subnetID != nil {
+		isManaged, err := s.Client.IsManagedSubnet(ctx, *subnetID)
+		if err != nil {
+			return errors.Wrap(err, "failed to check if subnet is managed")
+		}
+		if !isManaged {
+			s.Log.Info("Subnet is not managed by Forge, skipping Internet Gateway and route table", "SubnetID", *subnetID)
+			return nil
+		}
+	}
+
 	// Ensure Internet Gateway exists
 	vpcID := *vpc.VpcId
 	s.Log.V(1).Info("Reconciling Internet Gateway for VPC", "VPCID", vpcID)
@@ -45,6 +68,12 @@ func (s *Service) Reconcile(ctx context.Context) error {
 	}
 	s.Log.Info("Internet Gateway is ready", "IGWID", aws.StringValue(igw.InternetGatewayId))
 
+	// Route the build subnet through its own route table, the main route table of the VPC is left untouched
+	_, err = s.reconcileRouteTable(ctx, vpcID, &awsforge.RouteTarget{InternetGatewayID: aws.StringValue(igw.InternetGatewayId)})
+	if err != nil {
+		return errors.Wrap(err, "failed to reconcile route table")
+	}
+
 	return nil
 }
 
@@ -59,25 +88,40 @@ func (s *Service) Delete(ctx context.Context) error {
 	}
 
 	// Check if the VPC is managed
-	isManagedVPC, err := s.Client.IsManagedVPC(vpcID)
+	isManagedVPC, err := s.Client.IsManagedVPC(ctx, vpcID)
 	if err != nil {
 		return errors.Wrap(err, "failed to check if VPC is managed")
 	}
 
 	if !isManagedVPC {
+		// The route table is the only resource created by Forge in a VPC it doesn't manage
+		if routeTableID := s.scope.RouteTableID(); routeTableID != nil {
+			s.Log.Info("Deleting route table", "RouteTableID", *routeTableID)
+			err = s.Client.DeleteRouteTable(ctx, *routeTableID)
+			if err != nil {
+				return errors.Wrap(err, "failed to delete route table")
+			}
+			s.scope.SetRouteTableID(nil)
+		}
 		s.Log.Info("VPC is not managed by the system. Skipping deletion.", "VPCID", *vpcID)
 		return nil
 	}
 
+	// Delete the private egress resources before the gateway and the VPC they depend on
+	err = s.deletePrivateEgress(ctx, *vpcID)
+	if err != nil {
+		return err
+	}
+
 	// Detach and delete the Internet Gateway
-	err = s.Client.DetachAndDeleteInternetGateway(vpcID)
+	err = s.Client.DetachAndDeleteInternetGateway(ctx, vpcID)
 	if err != nil {
 		return errors.Wrap(err, "failed to detach and delete Internet Gateway")
 	}
 
 	// Delete the VPC
 	s.Log.V(1).Info("Deleting VPC", "VPCID", *vpcID)
-	err = s.Client.DeleteVPC(vpcID)
+	err = s.Client.DeleteVPC(ctx, vpcID)
 	if err != nil {
 		return err
 	}
@@ -86,12 +130,37 @@ func (s *Service) Delete(ctx context.Context) error {
 	return nil
 }
 
+// reconcileRouteTable ensures the route table of the build subnet exists with a default route to the target, if any.
+func (s *Service) reconcileRouteTable(ctx context.Context, vpcID string, target *awsforge.RouteTarget) (*ec2.RouteTable, error) {
+	routeTable, err := s.Client.CreateOrGetRouteTable(ctx, vpcID, s.routeTableName())
+	if err != nil {
+		return nil, err
+	}
+
+	if target != nil {
+		err = s.Client.EnsureDefaultRoute(ctx, routeTable, *target)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.scope.SetRouteTableID(routeTable.RouteTableId)
+	s.Log.Info("Route table is ready", "RouteTableID", aws.StringValue(routeTable.RouteTableId))
+	return routeTable, nil
+}
+
+// routeTableName returns the name of the route table of the build subnet.
+// Builds of the same name in different namespaces may share the VPC, the UID of the build keeps their route tables apart.
+func (s *Service) routeTableName() string {
+	return fmt.Sprintf("%s-%s-rt", s.scope.Name(), s.scope.BuildUID())
+}
+
 // createOrGetVPC creates a VPC if it doesn't exist.
 func (s *Service) createOrGetVPC(ctx context.Context) (*ec2.Vpc, error) {
 	// Try to find the VPC by ID or Name
 	vpcID := s.scope.VPCID()
 	vpcName := s.scope.VPCName()
-	vpc, err := s.Client.FindVPCByIDOrName(vpcID, vpcName)
+	vpc, err := s.Client.FindVPCByIDOrName(ctx, vpcID, vpcName)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to search for VPC")
 	}
@@ -105,7 +174,7 @@ func (s *Service) createOrGetVPC(ctx context.Context) (*ec2.Vpc, error) {
 
 	// No existing VPC found; create a new one
 	vpcSpec := s.scope.VPCSpec()
-	vpc, err = s.Client.CreateVPC(vpcSpec)
+	vpc, err = s.Client.CreateVPC(ctx, vpcSpec)
 	if err != nil {
 		return nil, err
 	}
@@ -116,3 +185,13 @@ func (s *Service) createOrGetVPC(ctx context.Context) (*ec2.Vpc, error) {
 
 	return vpc, nil
 }
+
+// validateCIDRs checks the requested VPC and subnet CIDR blocks.
+// The VPC CIDR is only used when the VPC is created, otherwise the subnet is validated against the VPC at creation.
+func (s *Service) validateCIDRs() error {
+	vpcCIDR := s.scope.VPCCIDR()
+	if s.scope.VPCID() != nil {
+		vpcCIDR = ""
+	}
+	return awsforge.ValidateNetworkCIDRs(vpcCIDR, s.scope.SubnetCIDR(), s.scope.SubnetPrefixLength())
+}
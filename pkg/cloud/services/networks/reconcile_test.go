@@ -0,0 +1,400 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networks
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+	"github.com/forge-build/forge-provider-aws/pkg/aws/fake"
+	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
+)
+
+// maxReconciles bounds the reconciles waiting for the gateways and endpoints of the fake to change state.
+const maxReconciles = 5
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name    string
+		network infrav1.NetworkSpec
+		// setup creates the resources existing before the reconcile.
+		setup      func(t *testing.T, c *fake.AWSClient, network *infrav1.NetworkSpec)
+		errors     map[string]error
+		wantErr    string
+		wantReason string
+		verify     func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild)
+	}{
+		{
+			name: "public network routes the build subnet through the Internet Gateway",
+			verify: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				vpc := c.VPC(aws.StringValue(awsBuild.Spec.Network.VPCID))
+				if vpc == nil {
+					t.Fatal("VPC was not created")
+				}
+				if cidr := aws.StringValue(vpc.CidrBlock); cidr != awsforge.DefaultVPCCIDR {
+					t.Errorf("VPC CIDR = %s, want %s", cidr, awsforge.DefaultVPCCIDR)
+				}
+				if name := awsBuild.Spec.Network.Name; name != "build-forge-vpc" {
+					t.Errorf("VPC name = %s, want build-forge-vpc", name)
+				}
+				route := defaultRoute(t, c, awsBuild)
+				if !strings.HasPrefix(aws.StringValue(route.GatewayId), "igw-") {
+					t.Errorf("default route target = %s, want an Internet Gateway", aws.StringValue(route.GatewayId))
+				}
+			},
+		},
+		{
+			name: "existing VPC is used without being modified",
+			setup: func(t *testing.T, c *fake.AWSClient, network *infrav1.NetworkSpec) {
+				network.VPCID = unmanagedVPC(t, c)
+			},
+			verify: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				vpcID := aws.StringValue(awsBuild.Spec.Network.VPCID)
+				if cidr := aws.StringValue(c.VPC(vpcID).CidrBlock); cidr != "10.1.0.0/16" {
+					t.Errorf("VPC CIDR = %s, want 10.1.0.0/16", cidr)
+				}
+				routeTable := routeTable(t, c, awsBuild)
+				if aws.StringValue(routeTable.VpcId) != vpcID {
+					t.Errorf("route table is in VPC %s, want %s", aws.StringValue(routeTable.VpcId), vpcID)
+				}
+				if name := tagValue(routeTable.Tags, "Name"); name != "build-build-uid-rt" {
+					t.Errorf("route table name = %s, want build-build-uid-rt", name)
+				}
+			},
+		},
+		{
+			name: "subnet of the user is used without Internet Gateway and route table",
+			setup: func(t *testing.T, c *fake.AWSClient, network *infrav1.NetworkSpec) {
+				network.VPCID = unmanagedVPC(t, c)
+				subnetID, err := c.AddSubnet(aws.StringValue(network.VPCID), "10.1.0.0/24", "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				network.SubnetID = aws.String(subnetID)
+			},
+			verify: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				vpcID := aws.StringValue(awsBuild.Spec.Network.VPCID)
+				if igws := c.InternetGateways(vpcID); len(igws) != 0 {
+					t.Errorf("VPC has %d Internet Gateways, want none", len(igws))
+				}
+				if routeTables := c.RouteTables(vpcID); len(routeTables) != 1 {
+					t.Errorf("VPC has %d route tables, want only its main route table", len(routeTables))
+				}
+				if id := awsBuild.Status.RouteTableID; id != nil {
+					t.Errorf("route table ID = %s, want none", *id)
+				}
+			},
+		},
+		{
+			name:    "NAT gateway egress routes the build subnet through the NAT gateway",
+			network: infrav1.NetworkSpec{Private: &infrav1.PrivateNetworkSpec{Egress: infrav1.PrivateEgressNATGateway}},
+			verify: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				route := defaultRoute(t, c, awsBuild)
+				if !strings.HasPrefix(aws.StringValue(route.NatGatewayId), "nat-") {
+					t.Errorf("default route target = %s, want a NAT gateway", aws.StringValue(route.NatGatewayId))
+				}
+			},
+		},
+		{
+			name:    "VPC endpoints egress leaves the build subnet without default route",
+			network: infrav1.NetworkSpec{Private: &infrav1.PrivateNetworkSpec{Egress: infrav1.PrivateEgressVPCEndpoints}},
+			verify: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				for _, route := range routeTable(t, c, awsBuild).Routes {
+					if aws.StringValue(route.DestinationCidrBlock) == "0.0.0.0/0" {
+						t.Errorf("route table has a default route to %s", routeTarget(route))
+					}
+				}
+			},
+		},
+		{
+			name:    "private network in a VPC not managed by Forge is rejected",
+			network: infrav1.NetworkSpec{Private: &infrav1.PrivateNetworkSpec{}},
+			setup: func(t *testing.T, c *fake.AWSClient, network *infrav1.NetworkSpec) {
+				network.VPCID = unmanagedVPC(t, c)
+			},
+			wantErr:    "requires a VPC created by Forge",
+			wantReason: InvalidNetworkSpecReason,
+		},
+		{
+			name:    "invalid subnet CIDR is rejected before creating the VPC",
+			network: infrav1.NetworkSpec{SubnetCIDR: aws.String("10.0.0.0/33")},
+			wantErr: "invalid network spec",
+			verify: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				if awsBuild.Spec.Network.VPCID != nil {
+					t.Errorf("VPC %s was created", *awsBuild.Spec.Network.VPCID)
+				}
+			},
+		},
+		{
+			name:    "VPC creation error is returned",
+			errors:  map[string]error{"CreateVPC": awserr.New("VpcLimitExceeded", "The maximum number of VPCs has been reached.", nil)},
+			wantErr: "VpcLimitExceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := fake.New()
+			awsBuild := newAWSBuild(tt.network)
+			if tt.setup != nil {
+				tt.setup(t, c, &awsBuild.Spec.Network)
+			}
+			for method, err := range tt.errors {
+				c.Errors[method] = err
+			}
+
+			err := reconcile(ctx, New(fake.NewScope(t, c, nil, awsBuild)))
+			checkError(t, err, tt.wantErr, tt.wantReason)
+			if tt.verify != nil {
+				tt.verify(t, c, awsBuild)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		network infrav1.NetworkSpec
+		setup   func(t *testing.T, c *fake.AWSClient, network *infrav1.NetworkSpec)
+		// reconcile creates the network before it is deleted.
+		reconcile bool
+		// beforeDelete changes the network once it is reconciled.
+		beforeDelete func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild)
+		errors       map[string]error
+		wantErr      string
+		verify       func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild)
+	}{
+		{
+			name: "build without VPC has nothing to delete",
+		},
+		{
+			name:      "managed VPC is deleted with its gateway and route table",
+			reconcile: true,
+			verify:    vpcDeleted,
+		},
+		{
+			name:      "VPC not managed by Forge is kept and its route table deleted",
+			reconcile: true,
+			setup: func(t *testing.T, c *fake.AWSClient, network *infrav1.NetworkSpec) {
+				network.VPCID = unmanagedVPC(t, c)
+			},
+			verify: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				vpcID := aws.StringValue(awsBuild.Spec.Network.VPCID)
+				if c.VPC(vpcID) == nil {
+					t.Fatalf("VPC %s was deleted", vpcID)
+				}
+				if routeTables := c.RouteTables(vpcID); len(routeTables) != 1 {
+					t.Errorf("VPC has %d route tables, want only its main route table", len(routeTables))
+				}
+				if id := awsBuild.Status.RouteTableID; id != nil {
+					t.Errorf("route table ID = %s, want none", *id)
+				}
+			},
+		},
+		{
+			name:      "NAT gateway egress is deleted before the VPC",
+			network:   infrav1.NetworkSpec{Private: &infrav1.PrivateNetworkSpec{Egress: infrav1.PrivateEgressNATGateway}},
+			reconcile: true,
+			verify:    vpcDeleted,
+		},
+		{
+			name:      "VPC endpoints egress is deleted before the VPC",
+			network:   infrav1.NetworkSpec{Private: &infrav1.PrivateNetworkSpec{Egress: infrav1.PrivateEgressVPCEndpoints}},
+			reconcile: true,
+			verify:    vpcDeleted,
+		},
+		{
+			name:      "NAT gateway and VPC endpoint of the user are not deleted with the egress of the build",
+			network:   infrav1.NetworkSpec{Private: &infrav1.PrivateNetworkSpec{Egress: infrav1.PrivateEgressNATGateway}},
+			reconcile: true,
+			beforeDelete: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				vpcID := aws.StringValue(awsBuild.Spec.Network.VPCID)
+				subnetID, err := c.AddSubnet(vpcID, "10.0.200.0/24", "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				natGatewayID, err := c.AddNATGateway(subnetID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				endpointID, err := c.AddVPCEndpoint(vpcID, "com.amazonaws.us-east-1.dynamodb")
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() {
+					if state := aws.StringValue(c.NATGateway(natGatewayID).State); state != ec2.NatGatewayStateAvailable {
+						t.Errorf("NAT gateway of the user is %s, want %s", state, ec2.NatGatewayStateAvailable)
+					}
+					if state := aws.StringValue(c.VPCEndpoint(endpointID).State); state != "available" {
+						t.Errorf("VPC endpoint of the user is %s, want available", state)
+					}
+				})
+			},
+			// The resources of the user keep the VPC from being deleted
+			wantErr: "DependencyViolation",
+		},
+		{
+			name:      "VPC deletion error is returned",
+			reconcile: true,
+			errors:    map[string]error{"DeleteVPC": awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil)},
+			wantErr:   "UnauthorizedOperation",
+			verify: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				if c.VPC(aws.StringValue(awsBuild.Spec.Network.VPCID)) == nil {
+					t.Error("VPC was deleted")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := fake.New()
+			awsBuild := newAWSBuild(tt.network)
+			if tt.setup != nil {
+				tt.setup(t, c, &awsBuild.Spec.Network)
+			}
+			svc := New(fake.NewScope(t, c, nil, awsBuild))
+			if tt.reconcile {
+				if err := reconcile(ctx, svc); err != nil {
+					t.Fatalf("Reconcile() error = %v", err)
+				}
+			}
+			if tt.beforeDelete != nil {
+				tt.beforeDelete(t, c, awsBuild)
+			}
+			for method, err := range tt.errors {
+				c.Errors[method] = err
+			}
+
+			err := svc.Delete(ctx)
+			for i := 0; i < maxReconciles && awserrors.IsNetworkNotDeleted(err); i++ {
+				err = svc.Delete(ctx)
+			}
+			checkError(t, err, tt.wantErr, "")
+			if tt.verify != nil {
+				tt.verify(t, c, awsBuild)
+			}
+		})
+	}
+}
+
+// reconcile reconciles the network until its gateways and endpoints are ready.
+func reconcile(ctx context.Context, svc *Service) error {
+	err := svc.Reconcile(ctx)
+	for i := 0; i < maxReconciles && awserrors.IsNetworkNotReady(err); i++ {
+		err = svc.Reconcile(ctx)
+	}
+	return err
+}
+
+func newAWSBuild(network infrav1.NetworkSpec) *infrav1.AWSBuild {
+	return &infrav1.AWSBuild{
+		ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "default"},
+		Spec: infrav1.AWSBuildSpec{
+			Region:       fake.DefaultRegion,
+			InstanceType: "t3.micro",
+			Network:      network,
+		},
+	}
+}
+
+// unmanagedVPC creates a VPC without the tags of Forge, like a VPC of the user.
+func unmanagedVPC(t *testing.T, c *fake.AWSClient) *string {
+	t.Helper()
+	vpc, err := c.CreateVPC(context.Background(), &ec2.CreateVpcInput{CidrBlock: aws.String("10.1.0.0/16")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vpc.VpcId
+}
+
+// routeTable returns the route table of the build subnet.
+func routeTable(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) *ec2.RouteTable {
+	t.Helper()
+	id := aws.StringValue(awsBuild.Status.RouteTableID)
+	for _, routeTable := range c.RouteTables(aws.StringValue(awsBuild.Spec.Network.VPCID)) {
+		if aws.StringValue(routeTable.RouteTableId) == id {
+			return routeTable
+		}
+	}
+	t.Fatalf("route table %q not found", id)
+	return nil
+}
+
+// defaultRoute returns the default route of the route table of the build subnet.
+func defaultRoute(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) *ec2.Route {
+	t.Helper()
+	for _, route := range routeTable(t, c, awsBuild).Routes {
+		if aws.StringValue(route.DestinationCidrBlock) == "0.0.0.0/0" {
+			return route
+		}
+	}
+	t.Fatal("route table has no default route")
+	return nil
+}
+
+// routeTarget returns the gateway the route sends its traffic to.
+func routeTarget(route *ec2.Route) string {
+	if route.NatGatewayId != nil {
+		return *route.NatGatewayId
+	}
+	return aws.StringValue(route.GatewayId)
+}
+
+func tagValue(tags []*ec2.Tag, key string) string {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key {
+			return aws.StringValue(tag.Value)
+		}
+	}
+	return ""
+}
+
+func vpcDeleted(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+	t.Helper()
+	if vpcID := aws.StringValue(awsBuild.Spec.Network.VPCID); c.VPC(vpcID) != nil {
+		t.Errorf("VPC %s was not deleted", vpcID)
+	}
+}
+
+// checkError checks that the error contains wantErr and has the terminal reason, or that there is none.
+func checkError(t *testing.T, err error, wantErr, wantReason string) {
+	t.Helper()
+	if wantErr == "" {
+		if err != nil {
+			t.Fatalf("error = %v, want none", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Fatalf("error = %v, want %q", err, wantErr)
+	}
+	if reason := awserrors.Reason(err); wantReason != "" && reason != wantReason {
+		t.Errorf("reason = %q, want %q", reason, wantReason)
+	}
+}
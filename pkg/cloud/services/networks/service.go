@@ -20,6 +20,8 @@ import (
 	"context"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
 	"github.com/forge-build/forge-provider-aws/pkg/cloud"
 	"github.com/go-logr/logr"
 )
@@ -27,26 +29,66 @@ import (
 const ServiceName = "networks-reconciler"
 
 type vpcsInterface interface {
-	FindVPCByIDOrName(vpcID, vpcName *string) (*ec2.Vpc, error)
-	IsManagedVPC(vpcID *string) (bool, error)
-	DeleteVPC(vpcID *string) error
-	CreateVPC(input *ec2.CreateVpcInput) (*ec2.Vpc, error)
-	DetachAndDeleteInternetGateway(vpcID *string) error
+	FindVPCByIDOrName(ctx context.Context, vpcID, vpcName *string) (*ec2.Vpc, error)
+	IsManagedVPC(ctx context.Context, vpcID *string) (bool, error)
+	DeleteVPC(ctx context.Context, vpcID *string) error
+	CreateVPC(ctx context.Context, input *ec2.CreateVpcInput) (*ec2.Vpc, error)
+	DetachAndDeleteInternetGateway(ctx context.Context, vpcID *string) error
 	CreateOrGetInternetGateway(ctx context.Context, vpcID string) (*ec2.InternetGateway, error)
+	IsManagedSubnet(ctx context.Context, subnetID string) (bool, error)
+}
+
+type routeTablesInterface interface {
+	CreateOrGetRouteTable(ctx context.Context, vpcID, name string) (*ec2.RouteTable, error)
+	EnsureDefaultRoute(ctx context.Context, routeTable *ec2.RouteTable, target awsforge.RouteTarget) error
+	AssociateRouteTable(ctx context.Context, routeTableID, subnetID string) error
+	DeleteRouteTable(ctx context.Context, routeTableID string) error
+	DeleteRouteTables(ctx context.Context, vpcID string) error
+}
+
+type egressInterface interface {
+	CreateSubnet(ctx context.Context, params awsforge.CreateSubnetParams) (*ec2.Subnet, error)
+	DeleteSubnet(ctx context.Context, subnetID *string) error
+	FindSubnetByName(ctx context.Context, vpcID, name string) (*ec2.Subnet, error)
+	EnableVPCDNSHostnames(ctx context.Context, vpcID string) error
+	CreateOrGetNATGateway(ctx context.Context, params awsforge.NATGatewayParams) (*ec2.NatGateway, error)
+	CreateOrGetVPCEndpoints(ctx context.Context, params awsforge.VPCEndpointsParams) ([]*ec2.VpcEndpoint, error)
+	CreateSecurityGroup(ctx context.Context, vpcID, sgName *string) (*ec2.CreateSecurityGroupOutput, error)
+	FindSecurityGroupsInVPC(ctx context.Context, vpcID string, sgIDs []string, tags map[string]string) ([]*ec2.SecurityGroup, error)
+	AuthorizeSecurityGroupIngress(ctx context.Context, sgID string, permissions []*ec2.IpPermission) error
+	DeleteSecurityGroup(ctx context.Context, sgID *string) error
+	DeleteVPCEndpoints(ctx context.Context, vpcID string) (bool, error)
+	DeleteNATGateways(ctx context.Context, vpcID string) (bool, error)
+	ReleaseAddresses(ctx context.Context, vpcID string) (bool, error)
+}
+
+type client interface {
+	vpcsInterface
+	routeTablesInterface
+	egressInterface
 }
 
 type Scope interface {
 	cloud.Build
+	BuildUID() string
 	Region() string
 	VPCSpec() *ec2.CreateVpcInput
 	VPCID() *string
 	VPCName() *string
+	VPCCIDR() string
+	SubnetID() *string
+	SubnetCIDR() string
+	SubnetPrefixLength() int
+	PrivateEgress() infrav1.PrivateEgressMode
+	VPCEndpointServices() []string
+	RouteTableID() *string
+	SetRouteTableID(id *string)
 }
 
 // Service implements networks reconciler.
 type Service struct {
 	scope  Scope
-	Client vpcsInterface
+	Client client
 	Log    logr.Logger
 }
 
@@ -19,7 +19,8 @@ package securitygroup
 import (
 	"context"
 
-	"github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	"github.com/aws/aws-sdk-go/aws"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
 	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
 	"github.com/pkg/errors"
 )
@@ -28,43 +29,232 @@ import (
 func (s *Service) Reconcile(ctx context.Context) error {
 	s.Log.V(1).Info("Reconciling Security Group resources")
 
-	// Check if the Security Group ID is defined by the user
+	if err := s.reconcileAdditionalSecurityGroups(ctx); err != nil {
+		return err
+	}
+
+	// Check if the Security Group ID is defined by the user or created before
 	sgID := s.scope.SecurityGroupID()
 	if sgID != nil {
-		s.Log.Info("Using existing Security Group", "SecurityGroupID", *sgID)
+		isManaged, err := s.Client.IsManagedSecurityGroup(ctx, *sgID)
+		if err != nil {
+			return errors.Wrap(err, "failed to check if Security Group is managed")
+		}
+		if !isManaged {
+			s.Log.Info("Using existing Security Group", "SecurityGroupID", *sgID)
+			return nil
+		}
+	} else {
+		// Create a new Security Group if not specified
+		vpcID := s.scope.VPCID()
+		if vpcID == nil {
+			return errors.New("VPC ID is required to create a Security Group")
+		}
+
+		s.Log.Info("Creating Security Group", "VPCID", vpcID)
+		sg, err := s.Client.CreateSecurityGroup(ctx, vpcID, s.scope.SecurityGroupName())
+		if err != nil {
+			return errors.Wrap(err, "failed to create Security Group")
+		}
+
+		// Update the scope with the created Security Group ID
+		sgID = sg.GroupId
+		s.scope.SetSecurityGroupID(sgID)
+	}
+
+	if err := s.reconcileRules(ctx, *sgID); err != nil {
+		return err
+	}
+
+	s.Log.Info("Successfully reconciled Security Group", "SecurityGroupID", *sgID)
+	return nil
+}
+
+// reconcileAdditionalSecurityGroups resolves the additional Security Groups and validates they belong to the VPC.
+// They are only referenced, never modified or deleted.
+func (s *Service) reconcileAdditionalSecurityGroups(ctx context.Context) error {
+	refs := s.scope.AdditionalSecurityGroups()
+	if len(refs) == 0 {
+		s.scope.SetAdditionalSecurityGroupIDs(nil)
 		return nil
 	}
 
-	// Create a new Security Group if not specified
 	vpcID := s.scope.VPCID()
 	if vpcID == nil {
-		return errors.New("VPC ID is required to create a Security Group")
+		return errors.New("VPC ID is required to resolve additional Security Groups")
 	}
 
-	s.Log.Info("Creating Security Group", "VPCID", vpcID)
-	sg, err := s.Client.CreateSecurityGroup(vpcID, s.scope.SecurityGroupName())
+	var ids []string
+	seen := map[string]bool{}
+	for i, ref := range refs {
+		if ref.ID == nil && len(ref.Tags) == 0 {
+			return errors.Errorf("additional Security Group %d must set either an ID or tags", i)
+		}
+
+		var sgIDs []string
+		if ref.ID != nil {
+			sgIDs = []string{*ref.ID}
+		}
+		sgs, err := s.Client.FindSecurityGroupsInVPC(ctx, *vpcID, sgIDs, ref.Tags)
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve additional Security Group %d", i)
+		}
+		if len(sgs) == 0 {
+			return errors.Errorf("no Security Group in VPC %s matches additional Security Group %d", *vpcID, i)
+		}
+
+		for _, sg := range sgs {
+			id := aws.StringValue(sg.GroupId)
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	s.Log.V(1).Info("Resolved additional Security Groups", "SecurityGroupIDs", ids)
+	s.scope.SetAdditionalSecurityGroupIDs(ids)
+	return nil
+}
+
+// reconcileRules makes the ingress and egress rules of the managed Security Group match the spec.
+func (s *Service) reconcileRules(ctx context.Context, sgID string) error {
+	desiredIngress, err := s.desiredIngress(ctx)
 	if err != nil {
-		return errors.Wrap(err, "failed to create Security Group")
+		return err
+	}
+	desiredEgress, err := s.desiredEgress()
+	if err != nil {
+		return err
 	}
 
-	// Add SSH ingress rule
-	s.Log.V(1).Info("Adding SSH ingress rule to Security Group", "SecurityGroupID", sgID)
-	err = s.Client.AuthorizeSecurityGroupIngress(*sg.GroupId)
+	sg, err := s.Client.FindSecurityGroupByID(ctx, sgID)
 	if err != nil {
-		return errors.Wrap(err, "failed to add SSH ingress rule to Security Group")
+		return err
+	}
+
+	toAdd, toRemove := diffPermissions(fromIPPermissions(sg.IpPermissions), desiredIngress)
+	if len(toAdd) > 0 {
+		s.Log.V(1).Info("Adding ingress rules to Security Group", "SecurityGroupID", sgID, "Rules", len(toAdd))
+		if err := s.Client.AuthorizeSecurityGroupIngress(ctx, sgID, toAdd); err != nil {
+			return err
+		}
+	}
+	if len(toRemove) > 0 {
+		s.Log.V(1).Info("Removing ingress rules from Security Group", "SecurityGroupID", sgID, "Rules", len(toRemove))
+		if err := s.Client.RevokeSecurityGroupIngress(ctx, sgID, toRemove); err != nil {
+			return err
+		}
+	}
+
+	toAdd, toRemove = diffPermissions(fromIPPermissions(sg.IpPermissionsEgress), desiredEgress)
+	if len(toAdd) > 0 {
+		s.Log.V(1).Info("Adding egress rules to Security Group", "SecurityGroupID", sgID, "Rules", len(toAdd))
+		if err := s.Client.AuthorizeSecurityGroupEgress(ctx, sgID, toAdd); err != nil {
+			return err
+		}
+	}
+	if len(toRemove) > 0 {
+		s.Log.V(1).Info("Removing egress rules from Security Group", "SecurityGroupID", sgID, "Rules", len(toRemove))
+		if err := s.Client.RevokeSecurityGroupEgress(ctx, sgID, toRemove); err != nil {
+			return err
+		}
 	}
 
-	// Update the scope with the created Security Group ID
-	s.scope.SetSecurityGroupID(sg.GroupId)
-	s.Log.Info("Successfully reconciled Security Group", "SecurityGroupID", *sg.GroupId)
 	return nil
 }
 
+// desiredIngress returns the SSH rule and the additional ingress rules.
+func (s *Service) desiredIngress(ctx context.Context) ([]permission, error) {
+	sshPeers, err := s.sshPeers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []infrav1.SecurityGroupRule{{
+		Description:            "SSH access for Forge",
+		Protocol:               "tcp",
+		FromPort:               22,
+		SecurityGroupRulePeers: *sshPeers,
+	}}
+	for _, rule := range s.scope.AdditionalIngressRules() {
+		if rule.SecurityGroupRulePeers.IsEmpty() {
+			rule.SecurityGroupRulePeers = *sshPeers
+		}
+		rules = append(rules, rule)
+	}
+
+	var permissions []permission
+	for i, rule := range rules {
+		rulePermissions, err := fromRule(rule)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid ingress rule %d", i)
+		}
+		permissions = append(permissions, rulePermissions...)
+	}
+	return permissions, nil
+}
+
+// desiredEgress returns the egress rules of the spec, or the AWS default allow-all rule.
+func (s *Service) desiredEgress() ([]permission, error) {
+	rules := s.scope.EgressRules()
+	if len(rules) == 0 {
+		rules = []infrav1.SecurityGroupRule{{
+			Protocol:               protocolAll,
+			SecurityGroupRulePeers: infrav1.SecurityGroupRulePeers{CIDRBlocks: []string{"0.0.0.0/0"}},
+		}}
+	}
+
+	var permissions []permission
+	for i, rule := range rules {
+		rulePermissions, err := fromRule(rule)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid egress rule %d", i)
+		}
+		permissions = append(permissions, rulePermissions...)
+	}
+	return permissions, nil
+}
+
+// sshPeers returns the peers allowed to connect over SSH.
+// When none are configured, private networks allow the VPC, the controller connects to their private IP.
+// Other networks allow the egress IP of the controller, which is detected once and stored in the status.
+func (s *Service) sshPeers(ctx context.Context) (*infrav1.SecurityGroupRulePeers, error) {
+	if peers := s.scope.SSHIngress(); !peers.IsEmpty() {
+		return peers, nil
+	}
+	if s.scope.PrivateEgress() != "" {
+		vpcID := s.scope.VPCID()
+		if vpcID == nil {
+			return nil, errors.New("VPC ID is required to allow SSH from the VPC")
+		}
+		vpc, err := s.Client.FindVPCByIDOrName(ctx, vpcID, nil)
+		if err != nil {
+			return nil, err
+		}
+		if vpc == nil {
+			return nil, errors.Errorf("VPC %s not found", *vpcID)
+		}
+		return &infrav1.SecurityGroupRulePeers{CIDRBlocks: []string{aws.StringValue(vpc.CidrBlock)}}, nil
+	}
+
+	if ip := s.scope.EgressIP(); ip != nil {
+		return &infrav1.SecurityGroupRulePeers{CIDRBlocks: []string{*ip + "/32"}}, nil
+	}
+
+	ip, err := s.Client.DetectEgressIP(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to detect the controller egress IP for the SSH rule, set spec.network.sshIngress instead")
+	}
+	s.scope.SetEgressIP(ip)
+	return &infrav1.SecurityGroupRulePeers{CIDRBlocks: []string{ip + "/32"}}, nil
+}
+
 // Delete ensures the Security Group is deleted if managed by the system.
 func (s *Service) Delete(ctx context.Context) error {
 	s.Log.V(1).Info("Deleting Security Group resources")
 
-	if *s.scope.InstanceState() != v1alpha1.InstanceStatusTerminated {
+	if *s.scope.InstanceState() != infrav1.InstanceStatusTerminated {
 		return awserrors.ErrInstanceNotTerminated
 	}
 
@@ -76,7 +266,7 @@ func (s *Service) Delete(ctx context.Context) error {
 
 	// Check if the Security Group is managed by the controller
 	s.Log.V(1).Info("Checking if Security Group is managed by Forge", "SecurityGroupID", sgID)
-	isManaged, err := s.Client.IsManagedSecurityGroup(*sgID)
+	isManaged, err := s.Client.IsManagedSecurityGroup(ctx, *sgID)
 	if err != nil {
 		return errors.Wrap(err, "failed to check if Security Group is managed")
 	}
@@ -88,7 +278,7 @@ func (s *Service) Delete(ctx context.Context) error {
 
 	// Delete the Security Group
 	s.Log.Info("Deleting Security Group", "SecurityGroupID", *sgID)
-	err = s.Client.DeleteSecurityGroup(sgID)
+	err = s.Client.DeleteSecurityGroup(ctx, sgID)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,348 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package securitygroup
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	"github.com/forge-build/forge-provider-aws/pkg/aws/fake"
+	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
+)
+
+const allowAll = "-1|0|0|cidr|0.0.0.0/0"
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild)
+		errors  map[string]error
+		wantErr string
+		// wantIngress and wantEgress are the keys of the rules of the security group of the build.
+		wantIngress []string
+		wantEgress  []string
+		verify      func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild)
+	}{
+		{
+			name:        "managed security group allows SSH from the egress IP of the controller",
+			wantIngress: []string{"tcp|22|22|cidr|" + fake.DefaultEgressIP + "/32"},
+			wantEgress:  []string{allowAll},
+			verify: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				sg := c.SecurityGroup(aws.StringValue(awsBuild.Spec.Network.SecurityGroupID))
+				if name := aws.StringValue(sg.GroupName); name != "build-forge" {
+					t.Errorf("security group name = %s, want build-forge", name)
+				}
+				if ip := aws.StringValue(awsBuild.Status.EgressIP); ip != fake.DefaultEgressIP {
+					t.Errorf("egress IP = %s, want the detected egress IP %s to be stored", ip, fake.DefaultEgressIP)
+				}
+				if awsBuild.Spec.Network.SSHIngress != nil {
+					t.Errorf("SSH ingress = %+v, want the spec to be left unset", awsBuild.Spec.Network.SSHIngress)
+				}
+			},
+		},
+		{
+			name: "egress IP of the controller is detected once",
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				awsBuild.Status.EgressIP = aws.String("198.51.100.7")
+			},
+			errors:      map[string]error{"DetectEgressIP": errors.New("no route to the internet")},
+			wantIngress: []string{"tcp|22|22|cidr|198.51.100.7/32"},
+			wantEgress:  []string{allowAll},
+		},
+		{
+			name: "private network allows SSH from the CIDR block of the VPC without detecting the egress IP",
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				awsBuild.Spec.Network.Private = &infrav1.PrivateNetworkSpec{}
+				// The rule follows the VPC, not the spec
+				awsBuild.Spec.Network.VPCCIDR = "172.16.0.0/16"
+			},
+			errors:      map[string]error{"DetectEgressIP": errors.New("no route to the internet")},
+			wantIngress: []string{"tcp|22|22|cidr|10.0.0.0/16"},
+			wantEgress:  []string{allowAll},
+		},
+		{
+			name: "rules of the managed security group converge to the spec",
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				sgID := managedSecurityGroup(t, c, awsBuild)
+				if err := c.AuthorizeSecurityGroupIngress(context.Background(), aws.StringValue(sgID), []*ec2.IpPermission{{
+					IpProtocol: aws.String("tcp"),
+					FromPort:   aws.Int64(22),
+					ToPort:     aws.Int64(22),
+					IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+				}}); err != nil {
+					t.Fatal(err)
+				}
+				awsBuild.Spec.Network.SecurityGroupID = sgID
+				awsBuild.Spec.Network.SSHIngress = &infrav1.SecurityGroupRulePeers{CIDRBlocks: []string{"198.51.100.0/24"}}
+				awsBuild.Spec.Network.AdditionalIngressRules = []infrav1.SecurityGroupRule{
+					{Description: "WinRM", FromPort: 5986},
+					{Protocol: "udp", FromPort: 3000, ToPort: aws.Int64(3010), SecurityGroupRulePeers: infrav1.SecurityGroupRulePeers{CIDRBlocks: []string{"192.0.2.0/24"}}},
+				}
+				awsBuild.Spec.Network.EgressRules = []infrav1.SecurityGroupRule{
+					{FromPort: 443, SecurityGroupRulePeers: infrav1.SecurityGroupRulePeers{CIDRBlocks: []string{"0.0.0.0/0"}}},
+				}
+			},
+			errors: map[string]error{"DetectEgressIP": errors.New("no route to the internet")},
+			wantIngress: []string{
+				"tcp|22|22|cidr|198.51.100.0/24",
+				"tcp|5986|5986|cidr|198.51.100.0/24",
+				"udp|3000|3010|cidr|192.0.2.0/24",
+			},
+			wantEgress: []string{"tcp|443|443|cidr|0.0.0.0/0"},
+		},
+		{
+			name: "security group of the user is used without changing its rules",
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				sgID := defaultSecurityGroup(t, c, awsBuild)
+				if err := c.AuthorizeSecurityGroupIngress(context.Background(), aws.StringValue(sgID), []*ec2.IpPermission{{
+					IpProtocol: aws.String("tcp"),
+					FromPort:   aws.Int64(22),
+					ToPort:     aws.Int64(22),
+					IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+				}}); err != nil {
+					t.Fatal(err)
+				}
+				awsBuild.Spec.Network.SecurityGroupID = sgID
+				awsBuild.Spec.Network.SSHIngress = &infrav1.SecurityGroupRulePeers{CIDRBlocks: []string{"198.51.100.0/24"}}
+			},
+			wantIngress: []string{"tcp|22|22|cidr|0.0.0.0/0"},
+		},
+		{
+			name: "additional security groups are resolved by ID and tags",
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				defaultID := defaultSecurityGroup(t, c, awsBuild)
+				sg, err := c.CreateSecurityGroup(context.Background(), awsBuild.Spec.Network.VPCID, aws.String("extra"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				awsBuild.Spec.Network.AdditionalSecurityGroups = []infrav1.SecurityGroupReference{
+					{ID: defaultID},
+					{Tags: map[string]string{"Name": "extra"}},
+					{ID: sg.GroupId},
+				}
+				t.Cleanup(func() {
+					want := []string{aws.StringValue(defaultID), aws.StringValue(sg.GroupId)}
+					if ids := awsBuild.Status.AdditionalSecurityGroupIDs; !reflect.DeepEqual(ids, want) {
+						t.Errorf("additional security group IDs = %v, want %v", ids, want)
+					}
+				})
+			},
+			wantIngress: []string{"tcp|22|22|cidr|" + fake.DefaultEgressIP + "/32"},
+			wantEgress:  []string{allowAll},
+		},
+		{
+			name: "additional security group matching nothing is an error",
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				awsBuild.Spec.Network.AdditionalSecurityGroups = []infrav1.SecurityGroupReference{{Tags: map[string]string{"Name": "missing"}}}
+			},
+			wantErr: "matches additional Security Group 0",
+		},
+		{
+			name:    "undetected egress IP of the controller is an error",
+			errors:  map[string]error{"DetectEgressIP": errors.New("no route to the internet")},
+			wantErr: "set spec.network.sshIngress instead",
+		},
+		{
+			name: "VPC is required to create the security group",
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				awsBuild.Spec.Network.VPCID = nil
+			},
+			wantErr: "VPC ID is required",
+		},
+		{
+			name:    "security group creation error is returned",
+			errors:  map[string]error{"CreateSecurityGroup": awserr.New("SecurityGroupLimitExceeded", "The maximum number of security groups has been reached.", nil)},
+			wantErr: "SecurityGroupLimitExceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := fake.New()
+			awsBuild := newAWSBuild(t, c)
+			if tt.setup != nil {
+				tt.setup(t, c, awsBuild)
+			}
+			for method, err := range tt.errors {
+				c.Errors[method] = err
+			}
+
+			err := New(fake.NewScope(t, c, nil, awsBuild)).Reconcile(ctx)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Reconcile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			sg := c.SecurityGroup(aws.StringValue(awsBuild.Spec.Network.SecurityGroupID))
+			if sg == nil {
+				t.Fatalf("security group %q not found", aws.StringValue(awsBuild.Spec.Network.SecurityGroupID))
+			}
+			if ingress := ruleKeys(sg.IpPermissions); !reflect.DeepEqual(ingress, tt.wantIngress) {
+				t.Errorf("ingress rules = %v, want %v", ingress, tt.wantIngress)
+			}
+			if egress := ruleKeys(sg.IpPermissionsEgress); !reflect.DeepEqual(egress, tt.wantEgress) {
+				t.Errorf("egress rules = %v, want %v", egress, tt.wantEgress)
+			}
+			if tt.verify != nil {
+				tt.verify(t, c, awsBuild)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup returns the security group of the build, if any.
+		setup         func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) *string
+		instanceState infrav1.InstanceStatus
+		errors        map[string]error
+		wantErr       error
+		// wantDeleted checks the security group of the build is deleted.
+		wantDeleted bool
+	}{
+		{
+			name:          "security group is kept until the instance is terminated",
+			setup:         managedSecurityGroup,
+			instanceState: infrav1.InstanceStatusShuttingDown,
+			wantErr:       awserrors.ErrInstanceNotTerminated,
+		},
+		{
+			name: "build without security group has nothing to delete",
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) *string {
+				return nil
+			},
+			instanceState: infrav1.InstanceStatusTerminated,
+		},
+		{
+			name:          "managed security group is deleted",
+			setup:         managedSecurityGroup,
+			instanceState: infrav1.InstanceStatusTerminated,
+			wantDeleted:   true,
+		},
+		{
+			name:          "security group of the user is kept",
+			setup:         defaultSecurityGroup,
+			instanceState: infrav1.InstanceStatusTerminated,
+		},
+		{
+			name:          "security group deletion error is returned",
+			setup:         managedSecurityGroup,
+			instanceState: infrav1.InstanceStatusTerminated,
+			errors:        map[string]error{"DeleteSecurityGroup": awserr.New("DependencyViolation", "resource has a dependent object", nil)},
+			wantErr:       awserr.New("DependencyViolation", "resource has a dependent object", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := fake.New()
+			awsBuild := newAWSBuild(t, c)
+			awsBuild.Spec.Network.SecurityGroupID = tt.setup(t, c, awsBuild)
+			awsBuild.Status.InstanceStatus = &tt.instanceState
+			for method, err := range tt.errors {
+				c.Errors[method] = err
+			}
+
+			err := New(fake.NewScope(t, c, nil, awsBuild)).Delete(ctx)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("Delete() error = %v", err)
+			case tt.wantErr != nil && (err == nil || !strings.Contains(err.Error(), tt.wantErr.Error())):
+				t.Fatalf("Delete() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if sgID := awsBuild.Spec.Network.SecurityGroupID; sgID != nil {
+				if deleted := c.SecurityGroup(*sgID) == nil; deleted != tt.wantDeleted {
+					t.Errorf("security group deleted = %t, want %t", deleted, tt.wantDeleted)
+				}
+			}
+		})
+	}
+}
+
+// newAWSBuild returns an AWSBuild with a managed VPC.
+func newAWSBuild(t *testing.T, c *fake.AWSClient) *infrav1.AWSBuild {
+	t.Helper()
+	awsBuild := &infrav1.AWSBuild{
+		ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "default"},
+		Spec: infrav1.AWSBuildSpec{
+			Region:       fake.DefaultRegion,
+			InstanceType: "t3.micro",
+		},
+	}
+
+	vpc, err := c.CreateVPC(context.Background(), fake.NewScope(t, c, nil, awsBuild).VPCSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	awsBuild.Spec.Network.VPCID = vpc.VpcId
+	return awsBuild
+}
+
+// managedSecurityGroup creates the security group of the build like the reconciler does.
+func managedSecurityGroup(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) *string {
+	t.Helper()
+	sg, err := c.CreateSecurityGroup(context.Background(), awsBuild.Spec.Network.VPCID, aws.String("build-forge"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sg.GroupId
+}
+
+// defaultSecurityGroup returns the default security group of the VPC, which is not managed by Forge.
+func defaultSecurityGroup(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) *string {
+	t.Helper()
+	sgs, err := c.FindSecurityGroupsInVPC(context.Background(), aws.StringValue(awsBuild.Spec.Network.VPCID), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sg := range sgs {
+		if aws.StringValue(sg.GroupName) == "default" {
+			return sg.GroupId
+		}
+	}
+	t.Fatal("default security group not found")
+	return nil
+}
+
+// ruleKeys returns the sorted keys of the rules, nil when there are none.
+func ruleKeys(ipPermissions []*ec2.IpPermission) []string {
+	var keys []string
+	for _, p := range fromIPPermissions(ipPermissions) {
+		keys = append(keys, p.key())
+	}
+	sort.Strings(keys)
+	return keys
+}
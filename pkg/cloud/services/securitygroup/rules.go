@@ -0,0 +1,181 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package securitygroup
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+const (
+	protocolAll = "-1"
+
+	peerCIDR          = "cidr"
+	peerIPv6CIDR      = "ipv6"
+	peerPrefixList    = "pl"
+	peerSecurityGroup = "sg"
+)
+
+// permission is a security group rule with a single peer, which makes rules comparable.
+type permission struct {
+	protocol    string
+	fromPort    int64
+	toPort      int64
+	peerType    string
+	peer        string
+	description string
+}
+
+// key identifies the permission regardless of its description.
+func (p permission) key() string {
+	return fmt.Sprintf("%s|%d|%d|%s|%s", p.protocol, p.fromPort, p.toPort, p.peerType, p.peer)
+}
+
+// ipPermission converts the permission back into an EC2 IP permission.
+func (p permission) ipPermission() *ec2.IpPermission {
+	perm := &ec2.IpPermission{
+		IpProtocol: aws.String(p.protocol),
+	}
+	if p.protocol != protocolAll {
+		perm.FromPort = aws.Int64(p.fromPort)
+		perm.ToPort = aws.Int64(p.toPort)
+	}
+
+	var description *string
+	if p.description != "" {
+		description = aws.String(p.description)
+	}
+
+	switch p.peerType {
+	case peerCIDR:
+		perm.IpRanges = []*ec2.IpRange{{CidrIp: aws.String(p.peer), Description: description}}
+	case peerIPv6CIDR:
+		perm.Ipv6Ranges = []*ec2.Ipv6Range{{CidrIpv6: aws.String(p.peer), Description: description}}
+	case peerPrefixList:
+		perm.PrefixListIds = []*ec2.PrefixListId{{PrefixListId: aws.String(p.peer), Description: description}}
+	case peerSecurityGroup:
+		perm.UserIdGroupPairs = []*ec2.UserIdGroupPair{{GroupId: aws.String(p.peer), Description: description}}
+	}
+	return perm
+}
+
+// fromIPPermissions flattens the IP permissions of a security group into single peer permissions.
+func fromIPPermissions(ipPermissions []*ec2.IpPermission) []permission {
+	var permissions []permission
+	for _, ipPermission := range ipPermissions {
+		base := permission{protocol: aws.StringValue(ipPermission.IpProtocol)}
+		if base.protocol != protocolAll {
+			base.fromPort = aws.Int64Value(ipPermission.FromPort)
+			base.toPort = aws.Int64Value(ipPermission.ToPort)
+		}
+
+		for _, r := range ipPermission.IpRanges {
+			permissions = append(permissions, base.withPeer(peerCIDR, aws.StringValue(r.CidrIp), aws.StringValue(r.Description)))
+		}
+		for _, r := range ipPermission.Ipv6Ranges {
+			permissions = append(permissions, base.withPeer(peerIPv6CIDR, aws.StringValue(r.CidrIpv6), aws.StringValue(r.Description)))
+		}
+		for _, pl := range ipPermission.PrefixListIds {
+			permissions = append(permissions, base.withPeer(peerPrefixList, aws.StringValue(pl.PrefixListId), aws.StringValue(pl.Description)))
+		}
+		for _, pair := range ipPermission.UserIdGroupPairs {
+			permissions = append(permissions, base.withPeer(peerSecurityGroup, aws.StringValue(pair.GroupId), aws.StringValue(pair.Description)))
+		}
+	}
+	return permissions
+}
+
+// fromRule validates a rule of the spec and flattens it into single peer permissions.
+func fromRule(rule infrav1.SecurityGroupRule) ([]permission, error) {
+	base := permission{
+		protocol:    strings.ToLower(rule.Protocol),
+		description: rule.Description,
+	}
+	if base.protocol == "" {
+		base.protocol = "tcp"
+	}
+	if base.protocol != protocolAll {
+		base.fromPort = rule.FromPort
+		base.toPort = rule.FromPort
+		if rule.ToPort != nil {
+			base.toPort = *rule.ToPort
+		}
+		if base.fromPort > base.toPort {
+			return nil, errors.Errorf("invalid port range %d-%d", base.fromPort, base.toPort)
+		}
+	}
+
+	var permissions []permission
+	for _, cidr := range rule.CIDRBlocks {
+		ip, _, err := net.ParseCIDR(cidr)
+		if err != nil || ip.To4() == nil {
+			return nil, errors.Errorf("invalid IPv4 CIDR block %q", cidr)
+		}
+		permissions = append(permissions, base.withPeer(peerCIDR, cidr, base.description))
+	}
+	for _, cidr := range rule.IPv6CIDRBlocks {
+		ip, _, err := net.ParseCIDR(cidr)
+		if err != nil || ip.To4() != nil {
+			return nil, errors.Errorf("invalid IPv6 CIDR block %q", cidr)
+		}
+		permissions = append(permissions, base.withPeer(peerIPv6CIDR, cidr, base.description))
+	}
+	for _, id := range rule.PrefixListIDs {
+		permissions = append(permissions, base.withPeer(peerPrefixList, id, base.description))
+	}
+	for _, id := range rule.SecurityGroupIDs {
+		permissions = append(permissions, base.withPeer(peerSecurityGroup, id, base.description))
+	}
+	return permissions, nil
+}
+
+func (p permission) withPeer(peerType, peer, description string) permission {
+	p.peerType = peerType
+	p.peer = peer
+	p.description = description
+	return p
+}
+
+// diffPermissions returns the desired permissions that are missing and the current permissions that are not desired.
+func diffPermissions(current, desired []permission) (toAdd, toRemove []*ec2.IpPermission) {
+	currentKeys := make(map[string]bool, len(current))
+	for _, p := range current {
+		currentKeys[p.key()] = true
+	}
+	desiredKeys := make(map[string]bool, len(desired))
+	for _, p := range desired {
+		if desiredKeys[p.key()] {
+			continue
+		}
+		desiredKeys[p.key()] = true
+		if !currentKeys[p.key()] {
+			toAdd = append(toAdd, p.ipPermission())
+		}
+	}
+	for _, p := range current {
+		if !desiredKeys[p.key()] {
+			toRemove = append(toRemove, p.withPeer(p.peerType, p.peer, "").ipPermission())
+		}
+	}
+	return toAdd, toRemove
+}
@@ -17,7 +17,10 @@ limitations under the License.
 package securitygroup
 
 import (
+	"context"
+
 	"github.com/aws/aws-sdk-go/service/ec2"
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
 	"github.com/forge-build/forge-provider-aws/pkg/cloud"
 	"github.com/go-logr/logr"
 )
@@ -25,10 +28,17 @@ import (
 const ServiceName = "firewall-reconciler"
 
 type securityGroupInterface interface {
-	CreateSecurityGroup(vpcID, sgName *string) (*ec2.CreateSecurityGroupOutput, error)
-	AuthorizeSecurityGroupIngress(sgID string) error
-	IsManagedSecurityGroup(sgID string) (bool, error)
-	DeleteSecurityGroup(sgID *string) error
+	FindVPCByIDOrName(ctx context.Context, vpcID, vpcName *string) (*ec2.Vpc, error)
+	DetectEgressIP(ctx context.Context) (string, error)
+	CreateSecurityGroup(ctx context.Context, vpcID, sgName *string) (*ec2.CreateSecurityGroupOutput, error)
+	FindSecurityGroupByID(ctx context.Context, sgID string) (*ec2.SecurityGroup, error)
+	FindSecurityGroupsInVPC(ctx context.Context, vpcID string, sgIDs []string, tags map[string]string) ([]*ec2.SecurityGroup, error)
+	AuthorizeSecurityGroupIngress(ctx context.Context, sgID string, permissions []*ec2.IpPermission) error
+	RevokeSecurityGroupIngress(ctx context.Context, sgID string, permissions []*ec2.IpPermission) error
+	AuthorizeSecurityGroupEgress(ctx context.Context, sgID string, permissions []*ec2.IpPermission) error
+	RevokeSecurityGroupEgress(ctx context.Context, sgID string, permissions []*ec2.IpPermission) error
+	IsManagedSecurityGroup(ctx context.Context, sgID string) (bool, error)
+	DeleteSecurityGroup(ctx context.Context, sgID *string) error
 }
 
 type Scope interface {
@@ -38,6 +48,14 @@ type Scope interface {
 	SecurityGroupName() *string
 	SecurityGroupID() *string
 	SetSecurityGroupID(id *string)
+	AdditionalSecurityGroups() []infrav1.SecurityGroupReference
+	SetAdditionalSecurityGroupIDs(ids []string)
+	SSHIngress() *infrav1.SecurityGroupRulePeers
+	PrivateEgress() infrav1.PrivateEgressMode
+	EgressIP() *string
+	SetEgressIP(ip string)
+	AdditionalIngressRules() []infrav1.SecurityGroupRule
+	EgressRules() []infrav1.SecurityGroupRule
 }
 
 // Service implements networks reconciler.
@@ -20,6 +20,7 @@ import (
 	"context"
 
 	"github.com/aws/aws-sdk-go/aws"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
 	"github.com/pkg/errors"
 )
 
@@ -36,21 +37,87 @@ func (s *Service) Reconcile(ctx context.Context) error {
 			return errors.Wrap(err, "failed to find user-specified subnet")
 		}
 		s.scope.SetSubnet(subnet.SubnetId)
-		return nil
+
+		// Only the subnets created by Forge are routed through its route table and moved across zones
+		isManaged, err := s.Client.IsManagedSubnet(ctx, *subnetID)
+		if err != nil {
+			return errors.Wrap(err, "failed to check if subnet is managed")
+		}
+		zone := s.scope.AvailabilityZone()
+		if !isManaged || zone == nil || *zone == aws.StringValue(subnet.AvailabilityZone) {
+			s.scope.SetAvailabilityZone(subnet.AvailabilityZone)
+			if isManaged {
+				return s.associateRouteTable(ctx, *subnetID)
+			}
+			return nil
+		}
+
+		// The instance moved to another zone, the subnet is recreated there
+		s.Log.Info("Replacing subnet in another availability zone", "SubnetID", *subnetID, "AvailabilityZone", *zone)
+		err = s.Client.DeleteSubnet(ctx, subnetID)
+		if err != nil {
+			return errors.Wrap(err, "failed to delete subnet")
+		}
+		s.scope.SetSubnet(nil)
+	}
+
+	zone, err := s.availabilityZone(ctx)
+	if err != nil {
+		return err
 	}
 
 	// Create a new subnet
-	s.Log.Info("No existing subnet found, creating a new subnet")
-	newSubnet, err := s.Client.CreateSubnet(ctx, *s.scope.VPCName(), s.scope.VPCID())
+	s.Log.Info("No existing subnet found, creating a new subnet", "AvailabilityZone", zone)
+	newSubnet, err := s.Client.CreateSubnet(ctx, awsforge.CreateSubnetParams{
+		VPCName:          *s.scope.VPCName(),
+		VPCID:            s.scope.VPCID(),
+		CIDRBlock:        s.scope.SubnetCIDR(),
+		PrefixLength:     s.scope.SubnetPrefixLength(),
+		AvailabilityZone: zone,
+	})
 	if err != nil {
 		return errors.Wrap(err, "failed to create subnet")
 	}
 	s.scope.SetSubnet(newSubnet.SubnetId)
+	s.scope.SetAvailabilityZone(newSubnet.AvailabilityZone)
+
+	err = s.associateRouteTable(ctx, aws.StringValue(newSubnet.SubnetId))
+	if err != nil {
+		return err
+	}
 
 	s.Log.Info("Successfully reconciled subnet", "SubnetID", aws.StringValue(newSubnet.SubnetId))
 	return nil
 }
 
+// availabilityZone returns the zone of the subnet to create.
+// It is the zone picked for the instance, or the first preferred zone offering the instance type.
+func (s *Service) availabilityZone(ctx context.Context) (string, error) {
+	if zone := s.scope.AvailabilityZone(); zone != nil {
+		return *zone, nil
+	}
+
+	zones, err := s.Client.FindAvailabilityZones(ctx, s.scope.InstanceType(), s.scope.AvailabilityZones())
+	if err != nil {
+		return "", errors.Wrap(err, "failed to select availability zone")
+	}
+	return zones[0], nil
+}
+
+// associateRouteTable associates the subnet with the route table created by the networks reconciler, if any.
+func (s *Service) associateRouteTable(ctx context.Context, subnetID string) error {
+	routeTableID := s.scope.RouteTableID()
+	if routeTableID == nil {
+		return nil
+	}
+
+	err := s.Client.AssociateRouteTable(ctx, *routeTableID, subnetID)
+	if err != nil {
+		return errors.Wrap(err, "failed to associate route table with subnet")
+	}
+	return nil
+}
+
 // Delete ensures the AWS Subnet is deleted if managed by the system.
 func (s *Service) Delete(ctx context.Context) error {
 	s.Log.V(1).Info("Deleting AWS Subnet resources")
@@ -0,0 +1,295 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subnet
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+	"github.com/forge-build/forge-provider-aws/pkg/aws/fake"
+)
+
+const instanceType = "t3.micro"
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name string
+		// offerings are the zones offering the instance type, all the zones when empty.
+		offerings []string
+		// setup creates the resources existing before the reconcile in the managed VPC of the build.
+		setup   func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild)
+		errors  map[string]error
+		wantErr string
+		// wantZone is the zone of the subnet of the build.
+		wantZone string
+		// wantRouted checks the subnet is associated with the route table of the build.
+		wantRouted bool
+		verify     func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild)
+	}{
+		{
+			name:       "subnet is created in the first zone offering the instance type",
+			offerings:  []string{"us-east-1b", "us-east-1c"},
+			wantZone:   "us-east-1b",
+			wantRouted: true,
+		},
+		{
+			name:      "subnet is created in the first preferred zone offering the instance type",
+			offerings: []string{"us-east-1b", "us-east-1c"},
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				awsBuild.Spec.AvailabilityZones = []string{"us-east-1a", "us-east-1c", "us-east-1b"}
+			},
+			wantZone:   "us-east-1c",
+			wantRouted: true,
+		},
+		{
+			name: "subnet is created with the requested CIDR block",
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				awsBuild.Spec.Network.SubnetCIDR = aws.String("10.0.42.0/24")
+			},
+			wantZone:   "us-east-1a",
+			wantRouted: true,
+			verify: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				if cidr := aws.StringValue(c.Subnet(aws.StringValue(awsBuild.Spec.Network.SubnetID)).CidrBlock); cidr != "10.0.42.0/24" {
+					t.Errorf("subnet CIDR = %s, want 10.0.42.0/24", cidr)
+				}
+			},
+		},
+		{
+			name: "subnet of the user is used in its zone without changing its routes",
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				awsBuild.Spec.Network.SubnetID = userSubnet(t, c, awsBuild, "us-east-1c")
+				awsBuild.Status.AvailabilityZone = aws.String("us-east-1a")
+			},
+			wantZone: "us-east-1c",
+		},
+		{
+			name: "managed subnet is recreated in the zone the instance moved to",
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				subnet, err := c.CreateSubnet(context.Background(), awsforge.CreateSubnetParams{
+					VPCName:          "build-forge-vpc",
+					VPCID:            awsBuild.Spec.Network.VPCID,
+					AvailabilityZone: "us-east-1a",
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				awsBuild.Spec.Network.SubnetID = subnet.SubnetId
+				awsBuild.Status.AvailabilityZone = aws.String("us-east-1b")
+				t.Cleanup(func() {
+					if c.Subnet(aws.StringValue(subnet.SubnetId)) != nil {
+						t.Errorf("subnet %s in the previous zone was not deleted", aws.StringValue(subnet.SubnetId))
+					}
+				})
+			},
+			wantZone:   "us-east-1b",
+			wantRouted: true,
+		},
+		{
+			name:      "instance type not offered in the preferred zones is an error",
+			offerings: []string{"us-east-1b"},
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) {
+				awsBuild.Spec.AvailabilityZones = []string{"us-east-1a"}
+			},
+			wantErr: "failed to select availability zone",
+		},
+		{
+			name:    "subnet creation error is returned",
+			errors:  map[string]error{"CreateSubnet": awserr.New("SubnetLimitExceeded", "The maximum number of subnets has been reached.", nil)},
+			wantErr: "SubnetLimitExceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := fake.New()
+			if len(tt.offerings) > 0 {
+				c.Offerings[instanceType] = tt.offerings
+			}
+			awsBuild := newAWSBuild(t, c)
+			if tt.setup != nil {
+				tt.setup(t, c, awsBuild)
+			}
+			for method, err := range tt.errors {
+				c.Errors[method] = err
+			}
+
+			err := New(fake.NewScope(t, c, nil, awsBuild)).Reconcile(ctx)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Reconcile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			subnetID := aws.StringValue(awsBuild.Spec.Network.SubnetID)
+			subnet := c.Subnet(subnetID)
+			if subnet == nil {
+				t.Fatalf("subnet %q not found", subnetID)
+			}
+			if zone := aws.StringValue(subnet.AvailabilityZone); zone != tt.wantZone {
+				t.Errorf("subnet zone = %s, want %s", zone, tt.wantZone)
+			}
+			if zone := aws.StringValue(awsBuild.Status.AvailabilityZone); zone != tt.wantZone {
+				t.Errorf("availability zone = %s, want %s", zone, tt.wantZone)
+			}
+			if routed := isRouted(c, awsBuild); routed != tt.wantRouted {
+				t.Errorf("subnet routed through the route table of the build = %t, want %t", routed, tt.wantRouted)
+			}
+			if tt.verify != nil {
+				tt.verify(t, c, awsBuild)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup returns the subnet of the build, if any.
+		setup   func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) *string
+		errors  map[string]error
+		wantErr string
+		// wantDeleted checks the subnet of the build is deleted.
+		wantDeleted bool
+	}{
+		{
+			name: "build without subnet has nothing to delete",
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) *string {
+				return nil
+			},
+		},
+		{
+			name:        "managed subnet is deleted",
+			setup:       managedSubnet,
+			wantDeleted: true,
+		},
+		{
+			name: "subnet of the user is kept",
+			setup: func(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) *string {
+				return userSubnet(t, c, awsBuild, "")
+			},
+		},
+		{
+			name:    "subnet deletion error is returned",
+			setup:   managedSubnet,
+			errors:  map[string]error{"DeleteSubnet": awserr.New("DependencyViolation", "The subnet has dependencies and cannot be deleted.", nil)},
+			wantErr: "DependencyViolation",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := fake.New()
+			awsBuild := newAWSBuild(t, c)
+			awsBuild.Spec.Network.SubnetID = tt.setup(t, c, awsBuild)
+			for method, err := range tt.errors {
+				c.Errors[method] = err
+			}
+
+			err := New(fake.NewScope(t, c, nil, awsBuild)).Delete(ctx)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Delete() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+
+			if subnetID := awsBuild.Spec.Network.SubnetID; subnetID != nil {
+				if deleted := c.Subnet(*subnetID) == nil; deleted != tt.wantDeleted {
+					t.Errorf("subnet deleted = %t, want %t", deleted, tt.wantDeleted)
+				}
+			}
+		})
+	}
+}
+
+// newAWSBuild returns an AWSBuild with a managed VPC and the route table of its subnet.
+func newAWSBuild(t *testing.T, c *fake.AWSClient) *infrav1.AWSBuild {
+	t.Helper()
+	awsBuild := &infrav1.AWSBuild{
+		ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "default"},
+		Spec: infrav1.AWSBuildSpec{
+			Region:       fake.DefaultRegion,
+			InstanceType: instanceType,
+		},
+	}
+
+	ctx := context.Background()
+	buildScope := fake.NewScope(t, c, nil, awsBuild)
+	vpc, err := c.CreateVPC(ctx, buildScope.VPCSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	routeTable, err := c.CreateOrGetRouteTable(ctx, aws.StringValue(vpc.VpcId), "build-rt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	awsBuild.Spec.Network.VPCID = vpc.VpcId
+	awsBuild.Status.RouteTableID = routeTable.RouteTableId
+	return awsBuild
+}
+
+// managedSubnet creates a subnet of the build like the reconciler does.
+func managedSubnet(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild) *string {
+	t.Helper()
+	subnet, err := c.CreateSubnet(context.Background(), awsforge.CreateSubnetParams{
+		VPCName: "build-forge-vpc",
+		VPCID:   awsBuild.Spec.Network.VPCID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return subnet.SubnetId
+}
+
+// userSubnet creates a subnet in the zone that is not managed by Forge.
+func userSubnet(t *testing.T, c *fake.AWSClient, awsBuild *infrav1.AWSBuild, zone string) *string {
+	t.Helper()
+	subnetID, err := c.AddSubnet(aws.StringValue(awsBuild.Spec.Network.VPCID), "10.0.200.0/24", zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aws.String(subnetID)
+}
+
+// isRouted checks if the subnet of the build is associated with the route table of the build.
+func isRouted(c *fake.AWSClient, awsBuild *infrav1.AWSBuild) bool {
+	for _, routeTable := range c.RouteTables(aws.StringValue(awsBuild.Spec.Network.VPCID)) {
+		if aws.StringValue(routeTable.RouteTableId) != aws.StringValue(awsBuild.Status.RouteTableID) {
+			continue
+		}
+		for _, association := range routeTable.Associations {
+			if aws.StringValue(association.SubnetId) == aws.StringValue(awsBuild.Spec.Network.SubnetID) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
 	"github.com/forge-build/forge-provider-aws/pkg/cloud"
 	"github.com/go-logr/logr"
 )
@@ -27,10 +28,12 @@ import (
 const ServiceName = "subnets-reconciler"
 
 type subnetsInterface interface {
-	CreateSubnet(ctx context.Context, vpcName string, vpcID *string) (*ec2.Subnet, error)
+	CreateSubnet(ctx context.Context, params awsforge.CreateSubnetParams) (*ec2.Subnet, error)
 	DeleteSubnet(ctx context.Context, subnetID *string) error
 	IsManagedSubnet(ctx context.Context, subnetID string) (bool, error)
 	FindSubnetByID(ctx context.Context, subnetID string) (*ec2.Subnet, error)
+	AssociateRouteTable(ctx context.Context, routeTableID, subnetID string) error
+	FindAvailabilityZones(ctx context.Context, instanceType string, preferred []string) ([]string, error)
 }
 
 type client interface {
@@ -43,6 +46,12 @@ type Scope interface {
 	VPCSpec() *ec2.CreateVpcInput
 	VPCID() *string
 	VPCName() *string
+	SubnetCIDR() string
+	SubnetPrefixLength() int
+	RouteTableID() *string
+	AvailabilityZones() []string
+	AvailabilityZone() *string
+	SetAvailabilityZone(zone *string)
 }
 
 // Service implements networks reconciler.
@@ -22,10 +22,12 @@ import (
 	"strings"
 	"time"
 
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
 	"github.com/forge-build/forge-provider-aws/pkg/cloud"
 	"github.com/forge-build/forge-provider-aws/pkg/cloud/scope"
 	awserrors "github.com/forge-build/forge-provider-aws/pkg/cloud/services/errors"
 	"github.com/forge-build/forge-provider-aws/pkg/cloud/services/images"
+	"github.com/forge-build/forge-provider-aws/pkg/cloud/services/instanceprofile"
 	"github.com/forge-build/forge-provider-aws/pkg/cloud/services/instances"
 	"github.com/forge-build/forge-provider-aws/pkg/cloud/services/networks"
 	"github.com/forge-build/forge-provider-aws/pkg/cloud/services/securitygroup"
@@ -54,6 +56,9 @@ import (
 
 const ControllerName = "awsbuild-controller"
 
+// quotaRequeueAfter is how long a build waits for the quotas of its account to free up.
+const quotaRequeueAfter = 5 * time.Minute
+
 var rawLog *logr.Logger
 
 // AWSBuildReconciler reconciles a AWSBuild object
@@ -61,15 +66,28 @@ type AWSBuildReconciler struct {
 	client.Client
 	log      logr.Logger
 	recorder record.EventRecorder
+	sessions *awsforge.SessionCache
+	// ec2Endpoint overrides the EC2 endpoint of the clients of all the builds when set.
+	ec2Endpoint string
+}
+
+// Options configures the AWS clients of the AWSBuild controller.
+type Options struct {
+	// RateLimits limit the AWS calls of all the builds.
+	RateLimits awsforge.RateLimitOptions
+	// EC2Endpoint overrides the EC2 endpoint of every region, e.g., with a local stand-in of the EC2 API.
+	EC2Endpoint string
 }
 
 // Add creates a new AWSBuild controller and adds it to the Manager.
-func Add(ctx context.Context, mgr ctrl.Manager, numWorkers int, log *logr.Logger) error {
+func Add(ctx context.Context, mgr ctrl.Manager, numWorkers int, options Options, log *logr.Logger) error {
 	// Create the reconciler instance
 	reconciler := &AWSBuildReconciler{
-		Client:   mgr.GetClient(),
-		recorder: mgr.GetEventRecorderFor(ControllerName),
-		log:      log.WithName(ControllerName),
+		Client:      mgr.GetClient(),
+		recorder:    mgr.GetEventRecorderFor(ControllerName),
+		log:         log.WithName(ControllerName),
+		sessions:    awsforge.NewSessionCache(options.RateLimits),
+		ec2Endpoint: options.EC2Endpoint,
 	}
 
 	// Set up the controller with custom predicates
@@ -93,6 +111,8 @@ func Add(ctx context.Context, mgr ctrl.Manager, numWorkers int, log *logr.Logger
 // +kubebuilder:rbac:groups=infrastructure.forge.build,resources=awsbuilds/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=infrastructure.forge.build,resources=awsbuilds/finalizers,verbs=update
 // +kubebuilder:rbac:groups=forge.build,resources=builds,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=infrastructure.forge.build,resources=awsidentities,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 func (r *AWSBuildReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
 	r.log.V(1).Info("Reconciling")
@@ -124,11 +144,19 @@ func (r *AWSBuildReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_
 		return ctrl.Result{}, nil
 	}
 
+	// The identity is checked before any AWS client is created with it
+	clientParams, err := r.clientParams(ctx, awsBuild)
+	if err != nil {
+		r.recordEvent(awsBuild, "Warning", "InvalidIdentity", err.Error())
+		return ctrl.Result{}, err
+	}
+
 	buildScope, err := scope.NewAWSBuildScope(ctx, scope.AWSBuildScopeParams{
-		Client:   r.Client,
-		Build:    build,
-		AWSBuild: awsBuild,
-		Log:      rawLog,
+		Client:       r.Client,
+		Build:        build,
+		AWSBuild:     awsBuild,
+		ClientParams: clientParams,
+		Log:          rawLog,
 	})
 	if err != nil {
 		return ctrl.Result{}, errors.Errorf("failed to create scope: %+v", err)
@@ -161,6 +189,7 @@ func (r *AWSBuildReconciler) reconcileDelete(ctx context.Context, buildScope *sc
 	reconcilers := []cloud.Reconciler{
 		instances.New(buildScope),
 		securitygroup.New(buildScope),
+		instanceprofile.New(buildScope),
 		subnet.New(buildScope),
 		networks.New(buildScope),
 	}
@@ -171,9 +200,12 @@ func (r *AWSBuildReconciler) reconcileDelete(ctx context.Context, buildScope *sc
 				r.log.V(1).Info("Instance is not terminated yet")
 				return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
 			}
-			r.log.Error(err, "Reconcile error")
-			r.recordEvent(buildScope.AWSBuild, "Warning", "Cleaning Up Failed", fmt.Sprintf("Reconcile error - %v ", err))
-			return ctrl.Result{}, err
+			if awserrors.IsNetworkNotDeleted(err) {
+				r.log.V(1).Info("Private network resources are not deleted yet")
+				return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+			}
+			// Resources must be cleaned up even when the build failed, so terminal errors are retried too
+			return r.handleError(ctx, buildScope, err, "Cleaning Up Failed", false)
 		}
 	}
 
@@ -184,10 +216,19 @@ func (r *AWSBuildReconciler) reconcileDelete(ctx context.Context, buildScope *sc
 }
 
 func (r *AWSBuildReconciler) reconcileNormal(ctx context.Context, buildScope *scope.AWSBuildScope) (ctrl.Result, error) {
+	// A failed build is not retried, only its resources are cleaned up
+	if buildScope.HasFailed() {
+		if !buildScope.IsCleanedUP() {
+			return r.reconcileDelete(ctx, buildScope)
+		}
+		return ctrl.Result{}, nil
+	}
+
 	reconcilers := []cloud.Reconciler{
 		networks.New(buildScope),
 		subnet.New(buildScope),
 		securitygroup.New(buildScope),
+		instanceprofile.New(buildScope),
 		instances.New(buildScope),
 		images.New(buildScope),
 	}
@@ -202,9 +243,11 @@ func (r *AWSBuildReconciler) reconcileNormal(ctx context.Context, buildScope *sc
 	if !buildScope.IsReady() {
 		for _, reconciler := range reconcilers {
 			if err := reconciler.Reconcile(ctx); err != nil {
-				r.log.Error(err, "Reconcile error")
-				r.recordEvent(buildScope.AWSBuild, "Warning", "Building Failed", fmt.Sprintf("Reconcile error - %v ", err))
-				return ctrl.Result{}, err
+				if awserrors.IsNetworkNotReady(err) {
+					r.log.V(1).Info("Network resources are not ready yet")
+					return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+				}
+				return r.handleError(ctx, buildScope, err, "Building Failed", true)
 			}
 		}
 		controllerutil.AddFinalizer(buildScope.AWSBuild, infrav1.BuildFinalizer)
@@ -243,6 +286,37 @@ func (r *AWSBuildReconciler) reconcileNormal(ctx context.Context, buildScope *sc
 	return ctrl.Result{}, nil
 }
 
+// handleError requeues the build according to the class of the AWS error that failed its reconcile.
+// Terminal errors fail the build when fail is set, and are retried after quotaRequeueAfter otherwise.
+func (r *AWSBuildReconciler) handleError(ctx context.Context, buildScope *scope.AWSBuildScope, err error, reason string, fail bool) (ctrl.Result, error) {
+	if awserrors.IsThrottling(err) {
+		r.log.V(1).Info("AWS API calls are throttled, retrying with backoff", "error", err.Error())
+		return ctrl.Result{Requeue: true}, nil
+	}
+	if awserrors.IsRequestCanceled(err) && ctx.Err() != nil {
+		r.log.Info("AWS API calls were aborted, the controller is shutting down")
+		return ctrl.Result{}, nil
+	}
+
+	switch awserrors.Classify(err) {
+	case awserrors.ClassTerminal:
+		if fail {
+			buildScope.SetFailure(awserrors.Reason(err), err.Error())
+			r.recordEvent(buildScope.AWSBuild, "Warning", reason, fmt.Sprintf("Build failed for good - %v ", err))
+			return ctrl.Result{}, nil
+		}
+		r.recordEvent(buildScope.AWSBuild, "Warning", reason, fmt.Sprintf("Reconcile error, retrying in %s - %v ", quotaRequeueAfter, err))
+		return ctrl.Result{RequeueAfter: quotaRequeueAfter}, nil
+	case awserrors.ClassQuota:
+		r.recordEvent(buildScope.AWSBuild, "Warning", "QuotaExceeded", fmt.Sprintf("AWS quota exceeded, retrying in %s - %v ", quotaRequeueAfter, err))
+		return ctrl.Result{RequeueAfter: quotaRequeueAfter}, nil
+	}
+
+	r.log.Error(err, "Reconcile error")
+	r.recordEvent(buildScope.AWSBuild, "Warning", reason, fmt.Sprintf("Reconcile error - %v ", err))
+	return ctrl.Result{}, err
+}
+
 func (r *AWSBuildReconciler) GetSSHKey(ctx context.Context, buildScope *scope.AWSBuildScope) (key scope.SSHKey, err error) {
 	if buildScope.AWSBuild.Spec.SSHCredentialsRef != nil {
 		secret, err := forgeutil.GetSecretFromSecretReference(ctx, r.Client, *buildScope.AWSBuild.Spec.SSHCredentialsRef)
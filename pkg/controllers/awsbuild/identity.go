@@ -0,0 +1,166 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package awsbuild
+
+import (
+	"context"
+	"slices"
+
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clientParams returns the region, the credentials and the endpoints of the AWS client of the build.
+// They come from the AWSIdentity referenced by the build, once its namespace is allowed, or from CredentialsRef
+// in the namespace of the build. The credentials of the controller are only used through an AWSIdentity of type Controller.
+// The endpoints of the build replace the endpoints of the identity. Builds may only send the credentials
+// of their own Secret, or of identities allowing it, to endpoints they choose.
+func (r *AWSBuildReconciler) clientParams(ctx context.Context, awsBuild *infrav1.AWSBuild) (awsforge.ClientParams, error) {
+	params := awsforge.ClientParams{
+		Region:      awsBuild.Spec.Region,
+		Sessions:    r.sessions,
+		EC2Endpoint: r.ec2Endpoint,
+	}
+
+	if awsBuild.Spec.CredentialsRef == nil && awsBuild.Spec.IdentityRef == nil {
+		return awsforge.ClientParams{}, errors.New("credentialsRef or identityRef is required, the credentials of the controller are only used through an AWSIdentity of type Controller")
+	}
+
+	allowEndpointOverrides := awsBuild.Spec.CredentialsRef != nil
+	allowAssumeRole := awsBuild.Spec.CredentialsRef != nil
+
+	// The Secrets of other namespaces, e.g., the ones of the identities, can't be referenced by builds
+	if ref := awsBuild.Spec.CredentialsRef; ref != nil {
+		if ref.Namespace != "" && ref.Namespace != awsBuild.Namespace {
+			return awsforge.ClientParams{}, errors.Errorf("credentialsRef must be in namespace %s of the AWSBuild", awsBuild.Namespace)
+		}
+		params.CredentialsRef = &corev1.SecretReference{Name: ref.Name, Namespace: awsBuild.Namespace}
+	}
+
+	if ref := awsBuild.Spec.IdentityRef; ref != nil {
+		if awsBuild.Spec.CredentialsRef != nil {
+			return awsforge.ClientParams{}, errors.New("credentialsRef and identityRef are mutually exclusive")
+		}
+
+		identity := &infrav1.AWSIdentity{}
+		if err := r.Get(ctx, client.ObjectKey{Name: ref.Name}, identity); err != nil {
+			return awsforge.ClientParams{}, errors.Wrapf(err, "failed to get AWSIdentity %s", ref.Name)
+		}
+
+		allowed, err := r.isNamespaceAllowed(ctx, identity.Spec.AllowedNamespaces, awsBuild.Namespace)
+		if err != nil {
+			return awsforge.ClientParams{}, err
+		}
+		if !allowed {
+			return awsforge.ClientParams{}, errors.Errorf("AWSIdentity %s does not allow namespace %s", ref.Name, awsBuild.Namespace)
+		}
+
+		switch identity.Spec.Type {
+		case infrav1.AWSIdentityTypeStatic:
+			if identity.Spec.SecretRef == nil || identity.Spec.SecretRef.Namespace == "" {
+				return awsforge.ClientParams{}, errors.Errorf("AWSIdentity %s of type Static requires secretRef with a namespace", ref.Name)
+			}
+			params.CredentialsRef = identity.Spec.SecretRef
+		case infrav1.AWSIdentityTypeAssumeRole:
+			if identity.Spec.AssumeRole == nil {
+				return awsforge.ClientParams{}, errors.Errorf("AWSIdentity %s of type AssumeRole requires assumeRole", ref.Name)
+			}
+			params.AssumeRoles = append(params.AssumeRoles, *identity.Spec.AssumeRole)
+		case infrav1.AWSIdentityTypeController:
+		default:
+			return awsforge.ClientParams{}, errors.Errorf("AWSIdentity %s has unsupported type %q", ref.Name, identity.Spec.Type)
+		}
+
+		allowEndpointOverrides = identity.Spec.AllowEndpointOverrides
+		allowAssumeRole = identity.Spec.AllowAssumeRole
+		if endpoints := identity.Spec.Endpoints; endpoints != nil {
+			if endpoints.CABundleRef != nil && endpoints.CABundleRef.Namespace == "" {
+				return awsforge.ClientParams{}, errors.Errorf("AWSIdentity %s requires caBundleRef with a namespace", ref.Name)
+			}
+			params.Endpoints = endpoints
+		}
+	}
+
+	if awsBuild.Spec.AssumeRole != nil {
+		if !allowAssumeRole {
+			return awsforge.ClientParams{}, errors.New("spec.assumeRole requires credentialsRef or an AWSIdentity allowing to assume roles")
+		}
+		params.AssumeRoles = append(params.AssumeRoles, *awsBuild.Spec.AssumeRole)
+	}
+
+	switch endpoints := awsBuild.Spec.Endpoints; {
+	case endpoints == nil:
+	case overridesEndpoints(endpoints):
+		if !allowEndpointOverrides {
+			return awsforge.ClientParams{}, errors.New("spec.endpoints can only set services, caBundleRef and proxyURL with credentialsRef or an AWSIdentity allowing endpoint overrides")
+		}
+		params.Endpoints = endpoints.DeepCopy()
+		if ref := params.Endpoints.CABundleRef; ref != nil {
+			if ref.Namespace != "" && ref.Namespace != awsBuild.Namespace {
+				return awsforge.ClientParams{}, errors.Errorf("caBundleRef must be in namespace %s of the AWSBuild", awsBuild.Namespace)
+			}
+			ref.Namespace = awsBuild.Namespace
+		}
+	default:
+		// FIPS and dual-stack endpoints are endpoints of AWS, any credentials can be sent to them
+		merged := &infrav1.EndpointsSpec{}
+		if params.Endpoints != nil {
+			merged = params.Endpoints.DeepCopy()
+		}
+		merged.UseFIPS = endpoints.UseFIPS
+		merged.UseDualStack = endpoints.UseDualStack
+		params.Endpoints = merged
+	}
+	return params, nil
+}
+
+// overridesEndpoints checks if the endpoints send the AWS calls elsewhere than to the endpoints of AWS.
+func overridesEndpoints(endpoints *infrav1.EndpointsSpec) bool {
+	return len(endpoints.Services) > 0 || endpoints.CABundleRef != nil || endpoints.ProxyURL != ""
+}
+
+// isNamespaceAllowed checks if the namespace is in the list or matches the selector of the allowed namespaces.
+func (r *AWSBuildReconciler) isNamespaceAllowed(ctx context.Context, allowed *infrav1.AllowedNamespaces, namespace string) (bool, error) {
+	if allowed == nil {
+		return false, nil
+	}
+	if len(allowed.NamespaceList) == 0 && allowed.Selector == nil {
+		return true, nil
+	}
+	if slices.Contains(allowed.NamespaceList, namespace) {
+		return true, nil
+	}
+	if allowed.Selector == nil {
+		return false, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(allowed.Selector)
+	if err != nil {
+		return false, errors.Wrap(err, "invalid allowed namespaces selector")
+	}
+
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return false, errors.Wrapf(err, "failed to get namespace %s", namespace)
+	}
+	return selector.Matches(labels.Set(ns.Labels)), nil
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2024 The Forge contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package awsbuild
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/forge-build/forge-provider-aws/pkg/api/v1alpha1"
+	awsforge "github.com/forge-build/forge-provider-aws/pkg/aws"
+)
+
+const (
+	testNamespace = "builds"
+	testRoleARN   = "arn:aws:iam::123456789012:role/forge-builder"
+)
+
+func TestClientParams(t *testing.T) {
+	tests := []struct {
+		name string
+		// identity is referenced by the build when set.
+		identity *infrav1.AWSIdentitySpec
+		setup    func(awsBuild *infrav1.AWSBuild)
+		wantErr  string
+		verify   func(t *testing.T, params awsforge.ClientParams)
+	}{
+		{
+			name:    "build without credentialsRef or identityRef is rejected",
+			wantErr: "credentialsRef or identityRef is required",
+		},
+		{
+			name: "credentialsRef is used in the namespace of the build",
+			setup: func(awsBuild *infrav1.AWSBuild) {
+				awsBuild.Spec.CredentialsRef = &corev1.SecretReference{Name: "aws-credentials"}
+			},
+			verify: func(t *testing.T, params awsforge.ClientParams) {
+				want := &corev1.SecretReference{Name: "aws-credentials", Namespace: testNamespace}
+				if !reflect.DeepEqual(params.CredentialsRef, want) {
+					t.Errorf("credentialsRef = %+v, want %+v", params.CredentialsRef, want)
+				}
+			},
+		},
+		{
+			name: "credentialsRef of another namespace is rejected",
+			setup: func(awsBuild *infrav1.AWSBuild) {
+				awsBuild.Spec.CredentialsRef = &corev1.SecretReference{Name: "aws-credentials", Namespace: "forge-system"}
+			},
+			wantErr: "credentialsRef must be in namespace builds",
+		},
+		{
+			name:     "identity without allowed namespaces allows no namespace",
+			identity: &infrav1.AWSIdentitySpec{Type: infrav1.AWSIdentityTypeController},
+			wantErr:  "does not allow namespace builds",
+		},
+		{
+			name: "identity with empty allowed namespaces allows all namespaces",
+			identity: &infrav1.AWSIdentitySpec{
+				Type:              infrav1.AWSIdentityTypeController,
+				AllowedNamespaces: &infrav1.AllowedNamespaces{},
+			},
+			verify: func(t *testing.T, params awsforge.ClientParams) {
+				if params.CredentialsRef != nil || len(params.AssumeRoles) > 0 {
+					t.Errorf("params = %+v, want the credentials of the controller", params)
+				}
+			},
+		},
+		{
+			name: "identity allows the namespaces of its list",
+			identity: &infrav1.AWSIdentitySpec{
+				Type:              infrav1.AWSIdentityTypeAssumeRole,
+				AssumeRole:        &infrav1.AssumeRoleSpec{RoleARN: testRoleARN},
+				AllowedNamespaces: &infrav1.AllowedNamespaces{NamespaceList: []string{"other", testNamespace}},
+			},
+			verify: func(t *testing.T, params awsforge.ClientParams) {
+				want := []infrav1.AssumeRoleSpec{{RoleARN: testRoleARN}}
+				if !reflect.DeepEqual(params.AssumeRoles, want) {
+					t.Errorf("assumed roles = %+v, want %+v", params.AssumeRoles, want)
+				}
+			},
+		},
+		{
+			name: "identity allows the namespaces matching its selector",
+			identity: &infrav1.AWSIdentitySpec{
+				Type: infrav1.AWSIdentityTypeController,
+				AllowedNamespaces: &infrav1.AllowedNamespaces{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"forge.build/aws-builds": "allowed"}},
+				},
+			},
+		},
+		{
+			name: "identity denies the namespaces neither in its list nor matching its selector",
+			identity: &infrav1.AWSIdentitySpec{
+				Type: infrav1.AWSIdentityTypeController,
+				AllowedNamespaces: &infrav1.AllowedNamespaces{
+					NamespaceList: []string{"other"},
+					Selector:      &metav1.LabelSelector{MatchLabels: map[string]string{"forge.build/aws-builds": "denied"}},
+				},
+			},
+			wantErr: "does not allow namespace builds",
+		},
+		{
+			name: "credentialsRef and identityRef are rejected together",
+			identity: &infrav1.AWSIdentitySpec{
+				Type:              infrav1.AWSIdentityTypeController,
+				AllowedNamespaces: &infrav1.AllowedNamespaces{},
+			},
+			setup: func(awsBuild *infrav1.AWSBuild) {
+				awsBuild.Spec.CredentialsRef = &corev1.SecretReference{Name: "aws-credentials"}
+			},
+			wantErr: "mutually exclusive",
+		},
+		{
+			name: "assumeRole of the build is assumed with credentialsRef",
+			setup: func(awsBuild *infrav1.AWSBuild) {
+				awsBuild.Spec.CredentialsRef = &corev1.SecretReference{Name: "aws-credentials"}
+				awsBuild.Spec.AssumeRole = &infrav1.AssumeRoleSpec{RoleARN: testRoleARN}
+			},
+			verify: func(t *testing.T, params awsforge.ClientParams) {
+				want := []infrav1.AssumeRoleSpec{{RoleARN: testRoleARN}}
+				if !reflect.DeepEqual(params.AssumeRoles, want) {
+					t.Errorf("assumed roles = %+v, want %+v", params.AssumeRoles, want)
+				}
+			},
+		},
+		{
+			name: "assumeRole of the build is rejected with an identity not allowing it",
+			identity: &infrav1.AWSIdentitySpec{
+				Type:              infrav1.AWSIdentityTypeController,
+				AllowedNamespaces: &infrav1.AllowedNamespaces{},
+			},
+			setup: func(awsBuild *infrav1.AWSBuild) {
+				awsBuild.Spec.AssumeRole = &infrav1.AssumeRoleSpec{RoleARN: testRoleARN}
+			},
+			wantErr: "spec.assumeRole requires credentialsRef or an AWSIdentity allowing to assume roles",
+		},
+		{
+			name: "assumeRole of the build is assumed after the role of an identity allowing it",
+			identity: &infrav1.AWSIdentitySpec{
+				Type:              infrav1.AWSIdentityTypeAssumeRole,
+				AssumeRole:        &infrav1.AssumeRoleSpec{RoleARN: "arn:aws:iam::123456789012:role/forge"},
+				AllowAssumeRole:   true,
+				AllowedNamespaces: &infrav1.AllowedNamespaces{},
+			},
+			setup: func(awsBuild *infrav1.AWSBuild) {
+				awsBuild.Spec.AssumeRole = &infrav1.AssumeRoleSpec{RoleARN: testRoleARN}
+			},
+			verify: func(t *testing.T, params awsforge.ClientParams) {
+				want := []infrav1.AssumeRoleSpec{{RoleARN: "arn:aws:iam::123456789012:role/forge"}, {RoleARN: testRoleARN}}
+				if !reflect.DeepEqual(params.AssumeRoles, want) {
+					t.Errorf("assumed roles = %+v, want %+v", params.AssumeRoles, want)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			if err := infrav1.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+
+			objects := []runtime.Object{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   testNamespace,
+				Labels: map[string]string{"forge.build/aws-builds": "allowed"},
+			}}}
+			awsBuild := &infrav1.AWSBuild{
+				ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: testNamespace},
+				Spec:       infrav1.AWSBuildSpec{Region: "us-east-1"},
+			}
+			if tt.identity != nil {
+				objects = append(objects, &infrav1.AWSIdentity{ObjectMeta: metav1.ObjectMeta{Name: "identity"}, Spec: *tt.identity})
+				awsBuild.Spec.IdentityRef = &infrav1.AWSIdentityReference{Name: "identity"}
+			}
+			if tt.setup != nil {
+				tt.setup(awsBuild)
+			}
+
+			r := &AWSBuildReconciler{Client: crfake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()}
+			params, err := r.clientParams(context.Background(), awsBuild)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("clientParams() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("clientParams() error = %v", err)
+			}
+			if params.Region != "us-east-1" {
+				t.Errorf("region = %s, want us-east-1", params.Region)
+			}
+			if tt.verify != nil {
+				tt.verify(t, params)
+			}
+		})
+	}
+}